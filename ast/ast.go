@@ -24,6 +24,7 @@ type Expression interface {
 type BlockStatement struct {
 	Token      token.Token // the { token
 	Statements []Statement
+	Rbrace     token.Token // the } token
 }
 
 type ExpressionStatement struct {
@@ -52,9 +53,16 @@ type IfExpression struct {
 }
 
 type FunctionLiteral struct {
-	Token      token.Token // The 'fn' token
-	Parameters []*Identifier
-	Body       *BlockStatement
+	Token       token.Token // The 'fn' token
+	Parameters  []*Identifier
+	Body        *BlockStatement
+	IsGenerator bool   // set when the body contains a yield
+	Name        string // the name the literal is bound to by a let statement, if any
+
+	// The annotations of the parameters, nil for a parameter without one
+	// and nil altogether if no parameter has one, and of the result.
+	ParameterTypes []Type
+	ReturnType     Type
 }
 
 type CallExpression struct {
@@ -76,11 +84,18 @@ type Program struct {
 type Identifier struct {
 	Token token.Token // the token.IDENT token
 	Value string
+
+	// Set by the resolver: the number of scopes between the identifier and
+	// its declaration, and the declaration's index within that scope.
+	Resolved bool
+	Depth    int
+	Slot     int
 }
 
 type LetStatement struct {
 	Token token.Token // the token.LET token
 	Name  *Identifier
+	Type  Type // the annotation, or nil
 	Value Expression
 }
 
@@ -114,6 +129,92 @@ type HashLiteral struct {
 	Pairs map[Expression]Expression
 }
 
+type EnumVariant struct {
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+type EnumStatement struct {
+	Token    token.Token // the 'enum' token
+	Name     *Identifier
+	Variants []*EnumVariant
+}
+
+type MatchArm struct {
+	Variant  *Identifier // the variant name, or _ for the catch-all arm
+	Bindings []*Identifier
+	Body     *BlockStatement
+}
+
+type MatchExpression struct {
+	Token   token.Token // the 'match' token
+	Subject Expression
+	Arms    []*MatchArm
+}
+
+type ImportStatement struct {
+	Token token.Token // the 'import' token
+	Path  *StringLiteral
+	Alias *Identifier
+}
+
+type ExportStatement struct {
+	Token     token.Token // the 'export' token
+	Statement *LetStatement
+}
+
+type MemberExpression struct {
+	Token    token.Token // the '.' token
+	Object   Expression
+	Property *Identifier
+}
+
+type YieldExpression struct {
+	Token token.Token // the 'yield' token
+	Value Expression
+}
+
+type SpawnExpression struct {
+	Token token.Token // the 'spawn' token
+	Call  Expression  // a call, or a function to call without arguments
+}
+
+// SelectCase is one case of a select expression: receive(channel) with an
+// optional binding for the value received, send(channel, value), or the
+// default case _.
+type SelectCase struct {
+	Token   token.Token // the receive, send or _ identifier
+	Channel Expression
+	Value   Expression
+	Binding *Identifier
+	Body    *BlockStatement
+}
+
+type SelectExpression struct {
+	Token token.Token // the 'select' token
+	Cases []*SelectCase
+}
+
+type ForExpression struct {
+	Token    token.Token // the 'for' token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+type MethodCallExpression struct {
+	Token     token.Token // the '.' token
+	Receiver  Expression
+	Method    *Identifier
+	Arguments []Expression
+}
+
+type MacroLiteral struct {
+	Token      token.Token // the 'macro' token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
 func (p *Program) TokenLiteral() string {
 	if len(p.Statements) > 0 {
 		return p.Statements[0].TokenLiteral()
@@ -137,6 +238,9 @@ func (ls *LetStatement) String() string {
 
 	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.String())
+	if ls.Type != nil {
+		out.WriteString(": " + ls.Type.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -223,7 +327,11 @@ func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range fl.Parameters {
+	for i, p := range fl.Parameters {
+		if fl.ParameterTypes != nil && fl.ParameterTypes[i] != nil {
+			params = append(params, p.String()+": "+fl.ParameterTypes[i].String())
+			continue
+		}
 		params = append(params, p.String())
 	}
 
@@ -279,6 +387,175 @@ func (ie *IndexExpression) String() string {
 	return out.String()
 }
 
+func (ev *EnumVariant) String() string {
+	if len(ev.Fields) == 0 {
+		return ev.Name.String()
+	}
+
+	fields := []string{}
+	for _, f := range ev.Fields {
+		fields = append(fields, f.String())
+	}
+
+	return ev.Name.String() + "(" + strings.Join(fields, ", ") + ")"
+}
+
+func (es *EnumStatement) String() string {
+	var out bytes.Buffer
+
+	variants := []string{}
+	for _, v := range es.Variants {
+		variants = append(variants, v.String())
+	}
+
+	out.WriteString(es.TokenLiteral() + " ")
+	out.WriteString(es.Name.String())
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(variants, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+func (ma *MatchArm) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ma.Variant.String())
+	if len(ma.Bindings) > 0 {
+		bindings := []string{}
+		for _, b := range ma.Bindings {
+			bindings = append(bindings, b.String())
+		}
+		out.WriteString("(" + strings.Join(bindings, ", ") + ")")
+	}
+	out.WriteString(" => ")
+	out.WriteString(ma.Body.String())
+
+	return out.String()
+}
+
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := []string{}
+	for _, a := range me.Arms {
+		arms = append(arms, a.String())
+	}
+
+	out.WriteString("match")
+	out.WriteString(me.Subject.String())
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+func (is *ImportStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(is.TokenLiteral() + " ")
+	out.WriteString("\"" + is.Path.Value + "\"")
+	out.WriteString(" as ")
+	out.WriteString(is.Alias.String())
+	out.WriteString(";")
+
+	return out.String()
+}
+
+func (es *ExportStatement) String() string {
+	return es.TokenLiteral() + " " + es.Statement.String()
+}
+
+func (me *MemberExpression) String() string {
+	return "(" + me.Object.String() + "." + me.Property.String() + ")"
+}
+
+func (ye *YieldExpression) String() string {
+	return ye.TokenLiteral() + " " + ye.Value.String()
+}
+
+func (se *SpawnExpression) String() string {
+	return se.TokenLiteral() + " " + se.Call.String()
+}
+
+func (sc *SelectCase) String() string {
+	var out bytes.Buffer
+
+	switch sc.Token.Literal {
+	case "receive":
+		out.WriteString("receive(" + sc.Channel.String() + ")")
+		if sc.Binding != nil {
+			out.WriteString(" as " + sc.Binding.String())
+		}
+	case "send":
+		out.WriteString("send(" + sc.Channel.String() + ", " + sc.Value.String() + ")")
+	default:
+		out.WriteString(sc.Token.Literal)
+	}
+	out.WriteString(" => ")
+	out.WriteString(sc.Body.String())
+
+	return out.String()
+}
+
+func (se *SelectExpression) String() string {
+	cases := []string{}
+	for _, c := range se.Cases {
+		cases = append(cases, c.String())
+	}
+	return "select { " + strings.Join(cases, ", ") + " }"
+}
+
+func (fe *ForExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for")
+	out.WriteString("(")
+	out.WriteString(fe.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fe.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fe.Body.String())
+
+	return out.String()
+}
+
+func (mc *MethodCallExpression) String() string {
+	var out bytes.Buffer
+
+	args := []string{}
+	for _, a := range mc.Arguments {
+		args = append(args, a.String())
+	}
+
+	out.WriteString(mc.Receiver.String())
+	out.WriteString(".")
+	out.WriteString(mc.Method.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(")")
+
+	return out.String()
+}
+
+func (ml *MacroLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(ml.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(ml.Body.String())
+
+	return out.String()
+}
+
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
 
@@ -344,3 +621,36 @@ func (hl *HashLiteral) String() string {
 
 	return out.String()
 }
+
+func (es *EnumStatement) statementNode()       {}
+func (es *EnumStatement) TokenLiteral() string { return es.Token.Literal }
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+
+func (es *ExportStatement) statementNode()       {}
+func (es *ExportStatement) TokenLiteral() string { return es.Token.Literal }
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+
+func (ye *YieldExpression) expressionNode()      {}
+func (ye *YieldExpression) TokenLiteral() string { return ye.Token.Literal }
+
+func (se *SpawnExpression) expressionNode()      {}
+func (se *SpawnExpression) TokenLiteral() string { return se.Token.Literal }
+
+func (se *SelectExpression) expressionNode()      {}
+func (se *SelectExpression) TokenLiteral() string { return se.Token.Literal }
+
+func (fe *ForExpression) expressionNode()      {}
+func (fe *ForExpression) TokenLiteral() string { return fe.Token.Literal }
+
+func (mc *MethodCallExpression) expressionNode()      {}
+func (mc *MethodCallExpression) TokenLiteral() string { return mc.Token.Literal }
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
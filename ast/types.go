@@ -0,0 +1,71 @@
+package ast
+
+import (
+	"WeekTwo/token"
+	"strings"
+)
+
+// Type is a type annotation, written after the name in a let statement or
+// after a parameter, or after the parameters of a function literal. The
+// evaluator ignores annotations unless it runs in strict mode.
+type Type interface {
+	Node
+	typeNode()
+}
+
+// NamedType is a type written as a name: int, bool, string, null, any or
+// the name of an enum.
+type NamedType struct {
+	Token token.Token // the token.IDENT token
+	Name  string
+}
+
+// ArrayType is [Element], the type of arrays of Element.
+type ArrayType struct {
+	Token   token.Token // the '[' token
+	Element Type
+}
+
+// HashType is {Key: Value}, the type of hashes from Key to Value.
+type HashType struct {
+	Token token.Token // the '{' token
+	Key   Type
+	Value Type
+}
+
+// FunctionType is fn(Parameters) -> Return. Return is nil if it was left
+// out.
+type FunctionType struct {
+	Token      token.Token // the 'fn' token
+	Parameters []Type
+	Return     Type
+}
+
+func (nt *NamedType) typeNode()            {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) String() string       { return nt.Name }
+
+func (at *ArrayType) typeNode()            {}
+func (at *ArrayType) TokenLiteral() string { return at.Token.Literal }
+func (at *ArrayType) String() string       { return "[" + at.Element.String() + "]" }
+
+func (ht *HashType) typeNode()            {}
+func (ht *HashType) TokenLiteral() string { return ht.Token.Literal }
+func (ht *HashType) String() string {
+	return "{" + ht.Key.String() + ": " + ht.Value.String() + "}"
+}
+
+func (ft *FunctionType) typeNode()            {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) String() string {
+	params := make([]string, len(ft.Parameters))
+	for i, p := range ft.Parameters {
+		params[i] = p.String()
+	}
+
+	s := "fn(" + strings.Join(params, ", ") + ")"
+	if ft.Return != nil {
+		s += " -> " + ft.Return.String()
+	}
+	return s
+}
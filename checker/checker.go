@@ -0,0 +1,792 @@
+// Package checker infers the types of a program, Hindley-Milner style, and
+// reports the operations that would fail at run time because of them, such
+// as adding an integer to a string.
+//
+// Checking is gradual. Annotations such as
+//
+//	let add = fn(a: int, b: int) -> int { a + b };
+//
+// state types outright, unannotated code gets the types its uses imply,
+// and whatever cannot be typed, such as an if expression whose branches
+// differ, is any, which is compatible with everything.
+package checker
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/lexer"
+	"WeekTwo/parser"
+	"WeekTwo/token"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Error is a type error at the position of Token.
+type Error struct {
+	Message string
+	Token   token.Token
+}
+
+func (e Error) String() string {
+	return fmt.Sprintf("%d:%d: %s", e.Token.Line, e.Token.Column, e.Message)
+}
+
+// scope mirrors an environment of the evaluator: the program, a function
+// call, a for loop iteration, a match arm or a select case.
+type scope struct {
+	parent   *scope
+	bindings map[string]*scheme
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{parent: parent, bindings: make(map[string]*scheme)}
+}
+
+// function is what the checker knows about the function whose body it is
+// in: the declared result type, or nil if there is none, and the type of
+// the results seen so far.
+type function struct {
+	declared  Type
+	result    Type
+	generator bool
+}
+
+// Checker checks programs. The global scope is kept between calls to
+// Check, so that a REPL can check one line at a time.
+type Checker struct {
+	globals  *scope
+	current  *scope
+	enums    map[string]*Enum
+	function *function
+	level    int // how many generic let bindings enclose the current node
+	nextID   int
+	trail    []*Variable // the variables bound by the current unification
+	types    map[ast.Expression]Type
+	errors   []Error
+}
+
+func New() *Checker {
+	return &Checker{globals: newScope(nil), enums: make(map[string]*Enum)}
+}
+
+// Source checks src and returns the errors found, sorted by position. It
+// returns an error if src does not parse.
+func Source(src []byte) ([]Error, error) {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+
+	if errors := p.ErrorDetails(); len(errors) != 0 {
+		messages := make([]string, len(errors))
+		for i, err := range errors {
+			messages[i] = fmt.Sprintf("%d:%d: %s", err.Token.Line, err.Token.Column, err.Message)
+		}
+		return nil, fmt.Errorf("%s", strings.Join(messages, "\n"))
+	}
+
+	return Check(program), nil
+}
+
+// Check checks program with a new Checker.
+func Check(program *ast.Program) []Error {
+	return New().Check(program)
+}
+
+// Check checks program and returns the errors found, sorted by position.
+func (c *Checker) Check(program *ast.Program) []Error {
+	c.current = c.globals
+	c.function = nil
+	c.types = make(map[ast.Expression]Type)
+	c.errors = nil
+
+	c.statements(program.Statements)
+
+	sort.SliceStable(c.errors, func(i, j int) bool {
+		a, b := c.errors[i].Token, c.errors[j].Token
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return c.errors
+}
+
+// TypeOf returns the type inferred for exp by the last call to Check, or
+// nil if exp was not checked.
+func (c *Checker) TypeOf(exp ast.Expression) Type {
+	t, ok := c.types[exp]
+	if !ok {
+		return nil
+	}
+	return resolve(t)
+}
+
+func (c *Checker) report(tok token.Token, format string, a ...interface{}) {
+	c.errors = append(c.errors, Error{Message: fmt.Sprintf(format, a...), Token: tok})
+}
+
+func (c *Checker) fresh() *Variable {
+	c.nextID++
+	return &Variable{id: c.nextID, level: c.level}
+}
+
+func (c *Checker) lookup(name string) (*scheme, bool) {
+	for s := c.current; s != nil; s = s.parent {
+		if binding, ok := s.bindings[name]; ok {
+			return binding, true
+		}
+	}
+	return nil, false
+}
+
+// statements checks stmts and returns the type of the last one, which is
+// the value of a block.
+func (c *Checker) statements(stmts []ast.Statement) Type {
+	var result Type = Null
+	for _, stmt := range stmts {
+		result = c.statement(stmt)
+	}
+	return result
+}
+
+func (c *Checker) statement(stmt ast.Statement) Type {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.let(stmt)
+	case *ast.ExportStatement:
+		c.let(stmt.Statement)
+	case *ast.ReturnStatement:
+		t := c.expression(stmt.ReturnValue)
+		c.returned(stmt.Token, t)
+		// Nothing after a return runs, so the block can have any type.
+		return c.fresh()
+	case *ast.ExpressionStatement:
+		return c.expression(stmt.Expression)
+	case *ast.EnumStatement:
+		c.enum(stmt)
+	case *ast.ImportStatement:
+		c.current.bindings[stmt.Alias.Value] = &scheme{t: AnyType}
+	}
+	return Null
+}
+
+// let binds the name of stmt. Functions are generic: each use of the name
+// gets a fresh copy of the parts of their type that are still unknown.
+func (c *Checker) let(stmt *ast.LetStatement) {
+	name := stmt.Name.Value
+
+	var declared Type
+	if stmt.Type != nil {
+		declared = c.annotation(stmt.Type)
+	}
+
+	_, generic := stmt.Value.(*ast.FunctionLiteral)
+	if generic {
+		c.level++
+	}
+
+	// The name is in scope in its own value, so that functions can call
+	// themselves.
+	self := declared
+	if self == nil {
+		self = c.fresh()
+	}
+	c.current.bindings[name] = &scheme{t: self}
+	t := c.expression(stmt.Value)
+
+	if generic {
+		c.level--
+	}
+
+	if declared != nil {
+		if !c.unify(declared, t) {
+			c.report(stmt.Name.Token, "cannot assign %s to %s of type %s", t, name, declared)
+		}
+		return
+	}
+
+	if !c.unify(self, t) {
+		self = AnyType
+	}
+	if generic {
+		c.current.bindings[name] = c.generalize(self)
+	} else {
+		c.current.bindings[name] = &scheme{t: self}
+	}
+}
+
+func (c *Checker) enum(stmt *ast.EnumStatement) {
+	enum := &Enum{Name: stmt.Name.Value}
+	c.enums[enum.Name] = enum
+	c.current.bindings[enum.Name] = &scheme{t: AnyType}
+
+	for _, variant := range stmt.Variants {
+		if len(variant.Fields) == 0 {
+			c.current.bindings[variant.Name.Value] = &scheme{t: enum}
+			continue
+		}
+		fields := make([]Type, len(variant.Fields))
+		for i := range fields {
+			fields[i] = AnyType
+		}
+		c.current.bindings[variant.Name.Value] = &scheme{t: &Function{Parameters: fields, Return: enum}}
+	}
+}
+
+// returned records that the current function returns a value of type t.
+func (c *Checker) returned(tok token.Token, t Type) {
+	fn := c.function
+	if fn == nil || fn.generator {
+		return
+	}
+	if fn.declared == nil {
+		fn.result = c.join(fn.result, t)
+		return
+	}
+	if !c.unify(fn.declared, t) {
+		c.report(tok, "cannot return %s from a function returning %s", t, fn.declared)
+	}
+}
+
+// annotation returns the type an annotation stands for.
+func (c *Checker) annotation(t ast.Type) Type {
+	switch t := t.(type) {
+	case *ast.NamedType:
+		switch t.Name {
+		case "int":
+			return Int
+		case "bool":
+			return Bool
+		case "string":
+			return String
+		case "null":
+			return Null
+		case "any":
+			return AnyType
+		}
+		if enum, ok := c.enums[t.Name]; ok {
+			return enum
+		}
+		c.report(t.Token, "unknown type %s", t.Name)
+		return AnyType
+	case *ast.ArrayType:
+		return &Array{Element: c.annotation(t.Element)}
+	case *ast.HashType:
+		return &Hash{Key: c.annotation(t.Key), Value: c.annotation(t.Value)}
+	case *ast.FunctionType:
+		params := make([]Type, len(t.Parameters))
+		for i, param := range t.Parameters {
+			params[i] = c.annotation(param)
+		}
+		var result Type = AnyType
+		if t.Return != nil {
+			result = c.annotation(t.Return)
+		}
+		return &Function{Parameters: params, Return: result}
+	}
+	return AnyType
+}
+
+func (c *Checker) expression(exp ast.Expression) Type {
+	t := c.infer(exp)
+	c.types[exp] = t
+	return t
+}
+
+func (c *Checker) infer(exp ast.Expression) Type {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.Boolean:
+		return Bool
+	case *ast.StringLiteral:
+		return String
+	case *ast.Identifier:
+		if binding, ok := c.lookup(exp.Value); ok {
+			return c.instantiate(binding)
+		}
+		if builtin, ok := builtins[exp.Value]; ok {
+			return builtin(c)
+		}
+		return AnyType
+	case *ast.PrefixExpression:
+		return c.prefix(exp)
+	case *ast.InfixExpression:
+		return c.infix(exp)
+	case *ast.IfExpression:
+		c.expression(exp.Condition)
+		consequence := c.statements(exp.Consequence.Statements)
+		if exp.Alternative == nil {
+			return c.join(consequence, Null)
+		}
+		return c.join(consequence, c.statements(exp.Alternative.Statements))
+	case *ast.FunctionLiteral:
+		return c.functionLiteral(exp)
+	case *ast.CallExpression:
+		return c.call(exp)
+	case *ast.IndexExpression:
+		return c.index(exp)
+	case *ast.ArrayLiteral:
+		var element Type = c.fresh()
+		for _, el := range exp.Elements {
+			element = c.join(element, c.expression(el))
+		}
+		return &Array{Element: element}
+	case *ast.HashLiteral:
+		keys := make([]ast.Expression, 0, len(exp.Pairs))
+		for key := range exp.Pairs {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+		var key, value Type = c.fresh(), c.fresh()
+		for _, k := range keys {
+			key = c.join(key, c.expression(k))
+			value = c.join(value, c.expression(exp.Pairs[k]))
+		}
+		return &Hash{Key: key, Value: value}
+	case *ast.MatchExpression:
+		c.expression(exp.Subject)
+		var result Type = c.fresh()
+		for _, arm := range exp.Arms {
+			c.enclosed(arm.Bindings, func() {
+				result = c.join(result, c.statements(arm.Body.Statements))
+			})
+		}
+		return result
+	case *ast.SelectExpression:
+		var result Type = c.fresh()
+		for _, sc := range exp.Cases {
+			if sc.Channel != nil {
+				c.expression(sc.Channel)
+			}
+			if sc.Value != nil {
+				c.expression(sc.Value)
+			}
+			var bindings []*ast.Identifier
+			if sc.Binding != nil {
+				bindings = append(bindings, sc.Binding)
+			}
+			c.enclosed(bindings, func() {
+				result = c.join(result, c.statements(sc.Body.Statements))
+			})
+		}
+		return result
+	case *ast.ForExpression:
+		c.expression(exp.Iterable)
+		c.enclosed([]*ast.Identifier{exp.Variable}, func() {
+			c.statements(exp.Body.Statements)
+		})
+	case *ast.MemberExpression:
+		c.expression(exp.Object)
+	case *ast.MethodCallExpression:
+		c.expression(exp.Receiver)
+		for _, arg := range exp.Arguments {
+			c.expression(arg)
+		}
+	case *ast.YieldExpression:
+		if exp.Value != nil {
+			c.expression(exp.Value)
+		}
+	case *ast.SpawnExpression:
+		c.expression(exp.Call)
+	}
+	// Macros, modules, tasks and the values sent into generators are not
+	// typed.
+	return AnyType
+}
+
+// enclosed runs check in a new scope in which bindings are of type any.
+func (c *Checker) enclosed(bindings []*ast.Identifier, check func()) {
+	outer := c.current
+	c.current = newScope(outer)
+	for _, binding := range bindings {
+		c.current.bindings[binding.Value] = &scheme{t: AnyType}
+	}
+	check()
+	c.current = outer
+}
+
+func (c *Checker) functionLiteral(fl *ast.FunctionLiteral) Type {
+	outer, outerFunction := c.current, c.function
+	c.current = newScope(outer)
+	defer func() { c.current, c.function = outer, outerFunction }()
+
+	params := make([]Type, len(fl.Parameters))
+	for i, param := range fl.Parameters {
+		if fl.ParameterTypes != nil && fl.ParameterTypes[i] != nil {
+			params[i] = c.annotation(fl.ParameterTypes[i])
+		} else {
+			params[i] = c.fresh()
+		}
+		c.current.bindings[param.Value] = &scheme{t: params[i]}
+	}
+
+	fn := &function{result: c.fresh(), generator: fl.IsGenerator}
+	if fl.ReturnType != nil {
+		fn.declared = c.annotation(fl.ReturnType)
+		fn.result = fn.declared
+	}
+	c.function = fn
+
+	body := c.statements(fl.Body.Statements)
+	c.returned(resultToken(fl.Body), body)
+
+	if fn.generator {
+		return &Function{Parameters: params, Return: AnyType}
+	}
+	return &Function{Parameters: params, Return: fn.result}
+}
+
+// resultToken returns the position to report a wrong result of block at.
+func resultToken(block *ast.BlockStatement) token.Token {
+	if len(block.Statements) == 0 {
+		return block.Rbrace
+	}
+	if stmt, ok := block.Statements[len(block.Statements)-1].(*ast.ExpressionStatement); ok {
+		return stmt.Token
+	}
+	return block.Rbrace
+}
+
+func (c *Checker) prefix(exp *ast.PrefixExpression) Type {
+	right := c.expression(exp.Right)
+	if exp.Operator == "!" {
+		return Bool
+	}
+	if isHash(right) {
+		return AnyType
+	}
+	if !c.unify(right, Int) {
+		c.report(exp.Token, "unknown operator: %s%s", exp.Operator, objectType(right))
+		return AnyType
+	}
+	return Int
+}
+
+// infix follows the evaluator: + adds integers or concatenates strings,
+// the other arithmetic and comparison operators take integers, == and !=
+// compare anything, and hashes on either side can overload every operator.
+func (c *Checker) infix(exp *ast.InfixExpression) Type {
+	left := c.expression(exp.Left)
+	right := c.expression(exp.Right)
+
+	comparison := exp.Operator == "<" || exp.Operator == ">"
+	switch {
+	case exp.Operator == "==" || exp.Operator == "!=":
+		return Bool
+	case isHash(left) || isHash(right):
+		return AnyType
+	case isAny(left) || isAny(right):
+		if comparison {
+			return Bool
+		}
+		if exp.Operator == "+" {
+			return AnyType
+		}
+		return Int
+	}
+
+	if !c.unify(left, right) {
+		if objectType(left) != objectType(right) {
+			c.report(exp.Token, "type mismatch: %s %s %s", objectType(left), exp.Operator, objectType(right))
+		} else {
+			c.report(exp.Token, "unknown operator: %s %s %s", objectType(left), exp.Operator, objectType(right))
+		}
+		return AnyType
+	}
+
+	t := prune(left)
+	if exp.Operator != "+" {
+		c.unify(t, Int)
+		t = prune(t)
+	}
+	if _, ok := t.(*Variable); !ok && t != Int && (exp.Operator != "+" || t != String) {
+		c.report(exp.Token, "unknown operator: %s %s %s", objectType(t), exp.Operator, objectType(t))
+		return AnyType
+	}
+
+	if comparison {
+		return Bool
+	}
+	return t
+}
+
+func (c *Checker) call(exp *ast.CallExpression) Type {
+	name := "function"
+	if ident, ok := exp.Function.(*ast.Identifier); ok {
+		name = ident.Value
+		// The arguments of quote are not evaluated.
+		if _, bound := c.lookup(name); !bound && name == "quote" {
+			return AnyType
+		}
+	}
+
+	f := c.expression(exp.Function)
+	args := make([]Type, len(exp.Arguments))
+	for i, arg := range exp.Arguments {
+		args[i] = c.expression(arg)
+	}
+
+	switch f := prune(f).(type) {
+	case *Any:
+		return AnyType
+	case *Variable:
+		result := c.fresh()
+		if !c.unify(f, &Function{Parameters: args, Return: result}) {
+			return AnyType
+		}
+		return result
+	case *Function:
+		if len(args) != len(f.Parameters) {
+			c.report(startToken(exp.Function), "wrong number of arguments to %s. got=%d, want=%d", name, len(args), len(f.Parameters))
+			return f.Return
+		}
+		for i, param := range f.Parameters {
+			if !c.unify(param, args[i]) {
+				c.report(startToken(exp.Arguments[i]), "cannot use %s as %s in argument %d to %s", args[i], param, i+1, name)
+			}
+		}
+		return f.Return
+	default:
+		c.report(startToken(exp.Function), "cannot call %s", f)
+		return AnyType
+	}
+}
+
+func (c *Checker) index(exp *ast.IndexExpression) Type {
+	left := c.expression(exp.Left)
+	index := c.expression(exp.Index)
+
+	switch left := prune(left).(type) {
+	case *Array:
+		if !c.unify(index, Int) {
+			c.report(exp.Token, "cannot index %s with %s", left, index)
+			return AnyType
+		}
+		return left.Element
+	case *Hash:
+		if !c.unify(index, left.Key) {
+			return AnyType
+		}
+		return left.Value
+	case *Any, *Variable:
+		return AnyType
+	default:
+		c.report(exp.Token, "index operator not supported: %s", objectType(left))
+		return AnyType
+	}
+}
+
+// startToken returns the first token of exp, where errors about it are
+// reported.
+func startToken(exp ast.Expression) token.Token {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		return exp.Token
+	case *ast.IntegerLiteral:
+		return exp.Token
+	case *ast.StringLiteral:
+		return exp.Token
+	case *ast.Boolean:
+		return exp.Token
+	case *ast.ArrayLiteral:
+		return exp.Token
+	case *ast.HashLiteral:
+		return exp.Token
+	case *ast.FunctionLiteral:
+		return exp.Token
+	case *ast.PrefixExpression:
+		return exp.Token
+	case *ast.IfExpression:
+		return exp.Token
+	case *ast.MatchExpression:
+		return exp.Token
+	case *ast.InfixExpression:
+		return startToken(exp.Left)
+	case *ast.CallExpression:
+		return startToken(exp.Function)
+	case *ast.IndexExpression:
+		return startToken(exp.Left)
+	case *ast.MemberExpression:
+		return startToken(exp.Object)
+	case *ast.MethodCallExpression:
+		return startToken(exp.Receiver)
+	}
+	return token.Token{}
+}
+
+func isAny(t Type) bool {
+	_, ok := prune(t).(*Any)
+	return ok
+}
+
+func isHash(t Type) bool {
+	_, ok := prune(t).(*Hash)
+	return ok
+}
+
+// unify makes a and b the same type by deciding what their unknown parts
+// are, and reports whether it could. If it could not, a and b are left as
+// they were.
+func (c *Checker) unify(a, b Type) bool {
+	mark := len(c.trail)
+	ok := c.unifies(a, b)
+	if !ok {
+		for _, v := range c.trail[mark:] {
+			v.instance = nil
+		}
+	}
+	c.trail = c.trail[:mark]
+	return ok
+}
+
+func (c *Checker) unifies(a, b Type) bool {
+	a, b = prune(a), prune(b)
+	if a == b {
+		return true
+	}
+	if v, ok := a.(*Variable); ok {
+		return c.bind(v, b)
+	}
+	if v, ok := b.(*Variable); ok {
+		return c.bind(v, a)
+	}
+	if isAny(a) || isAny(b) {
+		return true
+	}
+
+	switch a := a.(type) {
+	case *Basic:
+		b, ok := b.(*Basic)
+		return ok && a.Name == b.Name
+	case *Enum:
+		b, ok := b.(*Enum)
+		return ok && a.Name == b.Name
+	case *Array:
+		b, ok := b.(*Array)
+		return ok && c.unifies(a.Element, b.Element)
+	case *Hash:
+		b, ok := b.(*Hash)
+		return ok && c.unifies(a.Key, b.Key) && c.unifies(a.Value, b.Value)
+	case *Function:
+		b, ok := b.(*Function)
+		if !ok || len(a.Parameters) != len(b.Parameters) {
+			return false
+		}
+		for i := range a.Parameters {
+			if !c.unifies(a.Parameters[i], b.Parameters[i]) {
+				return false
+			}
+		}
+		return c.unifies(a.Return, b.Return)
+	}
+	return false
+}
+
+func (c *Checker) bind(v *Variable, t Type) bool {
+	if occurs(v, t) {
+		return false
+	}
+	// The variables of t are now as general as v at most.
+	c.walk(t, func(w *Variable) {
+		w.level = min(w.level, v.level)
+	})
+	v.instance = t
+	c.trail = append(c.trail, v)
+	return true
+}
+
+// join returns the type of a value that is either an a or a b: their
+// unification if they unify and any otherwise.
+func (c *Checker) join(a, b Type) Type {
+	if c.unify(a, b) {
+		return a
+	}
+	return AnyType
+}
+
+// walk calls f for every unknown variable in t.
+func (c *Checker) walk(t Type, f func(v *Variable)) {
+	switch t := prune(t).(type) {
+	case *Variable:
+		f(t)
+	case *Array:
+		c.walk(t.Element, f)
+	case *Hash:
+		c.walk(t.Key, f)
+		c.walk(t.Value, f)
+	case *Function:
+		for _, param := range t.Parameters {
+			c.walk(param, f)
+		}
+		c.walk(t.Return, f)
+	}
+}
+
+// generalize makes the variables of t that were created inside the
+// current generic let binding the variables of a scheme.
+func (c *Checker) generalize(t Type) *scheme {
+	s := &scheme{t: t}
+	seen := make(map[*Variable]bool)
+	c.walk(t, func(v *Variable) {
+		if v.level > c.level && !seen[v] {
+			seen[v] = true
+			s.vars = append(s.vars, v)
+		}
+	})
+	return s
+}
+
+// instantiate returns the type of s with fresh variables in place of its
+// generic ones.
+func (c *Checker) instantiate(s *scheme) Type {
+	if len(s.vars) == 0 {
+		return s.t
+	}
+	fresh := make(map[*Variable]Type, len(s.vars))
+	for _, v := range s.vars {
+		fresh[v] = c.fresh()
+	}
+
+	var copy func(t Type) Type
+	copy = func(t Type) Type {
+		switch t := prune(t).(type) {
+		case *Variable:
+			if f, ok := fresh[t]; ok {
+				return f
+			}
+			return t
+		case *Array:
+			return &Array{Element: copy(t.Element)}
+		case *Hash:
+			return &Hash{Key: copy(t.Key), Value: copy(t.Value)}
+		case *Function:
+			params := make([]Type, len(t.Parameters))
+			for i, param := range t.Parameters {
+				params[i] = copy(param)
+			}
+			return &Function{Parameters: params, Return: copy(t.Return)}
+		default:
+			return t
+		}
+	}
+	return copy(s.t)
+}
+
+// builtins gives the types of the builtin functions that have useful
+// ones. The others take and return any.
+var builtins = map[string]func(c *Checker) Type{
+	"len": func(c *Checker) Type {
+		return &Function{Parameters: []Type{AnyType}, Return: Int}
+	},
+	"push": func(c *Checker) Type {
+		element := c.fresh()
+		return &Function{Parameters: []Type{&Array{Element: element}, element}, Return: &Array{Element: element}}
+	},
+	"readline": func(c *Checker) Type {
+		return &Function{Parameters: []Type{}, Return: String}
+	},
+	"readall": func(c *Checker) Type {
+		return &Function{Parameters: []Type{}, Return: String}
+	},
+}
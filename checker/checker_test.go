@@ -0,0 +1,140 @@
+package checker
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/lexer"
+	"WeekTwo/parser"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("%q: parser errors %q", input, p.Errors())
+	}
+	return program
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`1 + 2; "a" + "b"; 1 < 2; 1 == "a"; [1] != 2`, nil},
+		{`1 + "a"; "a" - "b"; true + false; -"a"`, []string{
+			"1:3: type mismatch: INTEGER + STRING",
+			"1:14: unknown operator: STRING - STRING",
+			"1:26: unknown operator: BOOLEAN + BOOLEAN",
+			"1:35: unknown operator: -STRING",
+		}},
+		{`let f = fn(x) { x * 2 }; f("a")`, []string{`1:28: cannot use string as int in argument 1 to f`}},
+		{`let f = fn(a, b) { a }; f(1)`, []string{"1:25: wrong number of arguments to f. got=1, want=2"}},
+		{`let x = 5; x(1)`, []string{"1:12: cannot call int"}},
+		{`let x: int = "s"; let y: [string] = ["a"]; let z: {string: int} = {"a": 1}`, []string{
+			"1:5: cannot assign string to x of type int",
+		}},
+		{`let f = fn(a: int, b: string) -> bool { a }`, []string{"1:41: cannot return int from a function returning bool"}},
+		{`let f = fn() -> int { return "a"; }; let g = fn() -> null { }`, []string{
+			"1:23: cannot return string from a function returning int",
+		}},
+		{`let f = fn(x: Nope) { x }`, []string{"1:15: unknown type Nope"}},
+		{`enum Shape { Circle(r), Square }; let s: Shape = Circle(1); let t: Shape = 1`, []string{
+			"1:65: cannot assign int to t of type Shape",
+		}},
+		{`let h = {"__add__": fn(a, b) { a }}; h + 1; let u = fn(x) { x }; u(1) + u("a")`, []string{
+			"1:71: type mismatch: INTEGER + STRING",
+		}},
+		{`let v = {"__neg__": fn(a) { a }}; 1 * v; -v`, nil},
+		{`let c = if (true) { 1 } else { "a" }; c + 1; c - "b"`, nil},
+		{`[1, 2][0] + 1; [1, 2]["a"]; 5[0]`, []string{
+			`1:22: cannot index [int] with string`,
+			"1:30: index operator not supported: INTEGER",
+		}},
+		{`let f = fn(x: any) -> int { x }; f("a") + 1`, nil},
+		{`let fact = fn(n) { if (n < 2) { return 1; } n * fact(n - 1) }; fact(true)`, []string{
+			"1:69: cannot use bool as int in argument 1 to fact",
+		}},
+	}
+
+	for _, tt := range tests {
+		errors := Check(parse(t, tt.input))
+		if len(errors) != len(tt.expected) {
+			t.Errorf("%q: wrong number of errors. expected=%q, got=%v", tt.input, tt.expected, errors)
+			continue
+		}
+		for i, expected := range tt.expected {
+			if errors[i].String() != expected {
+				t.Errorf("%q: errors[%d] wrong. expected=%q, got=%q", tt.input, i, expected, errors[i])
+			}
+		}
+	}
+}
+
+func TestInference(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`5`, "int"},
+		{`"a" + "b"`, "string"},
+		{`1 < 2`, "bool"},
+		{`[1, 2]`, "[int]"},
+		{`[]`, "[a]"},
+		{`[1, "a"]`, "[any]"},
+		{`{"a": true}`, "{string: bool}"},
+		{`fn(x) { x }`, "fn(a) -> a"},
+		{`fn(a, b) { a + b }`, "fn(a, a) -> a"},
+		{`fn(x, y) { x - y }`, "fn(int, int) -> int"},
+		{`fn(f, x) { f(f(x)) }`, "fn(fn(a) -> a, a) -> a"},
+		{`fn(a: int, b) -> string { b }`, "fn(int, string) -> string"},
+		{`let id = fn(x) { x }; [id(1), id(2)]`, "[int]"},
+		{`let id = fn(x) { x }; id("a")`, "string"},
+		{`let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact`, "fn(int) -> int"},
+		{`if (true) { 1 }`, "any"},
+		{`fn(xs) { push(xs, 1) }`, "fn([int]) -> [int]"},
+		{`len("abc")`, "int"},
+		{`enum Color { Red, Green }; Red`, "Color"},
+		{`puts(1)`, "any"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		c := New()
+		if errors := c.Check(program); len(errors) != 0 {
+			t.Errorf("%q: unexpected errors %v", tt.input, errors)
+			continue
+		}
+
+		last := program.Statements[len(program.Statements)-1].(*ast.ExpressionStatement)
+		if got := c.TypeOf(last.Expression); got == nil || got.String() != tt.expected {
+			t.Errorf("%q: wrong type. expected=%q, got=%v", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestGlobalsAreKept(t *testing.T) {
+	c := New()
+	if errors := c.Check(parse(t, `let double = fn(x) { x * 2 };`)); len(errors) != 0 {
+		t.Fatalf("unexpected errors %v", errors)
+	}
+
+	errors := c.Check(parse(t, `double("a")`))
+	if len(errors) != 1 || errors[0].Message != "cannot use string as int in argument 1 to double" {
+		t.Errorf("wrong errors. got=%v", errors)
+	}
+}
+
+func TestSource(t *testing.T) {
+	errors, err := Source([]byte("let x: string = 1 + 2;"))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(errors) != 1 || errors[0].String() != "1:5: cannot assign int to x of type string" {
+		t.Errorf("wrong errors. got=%v", errors)
+	}
+
+	if _, err := Source([]byte("let x: = 1;")); err == nil || err.Error() != "1:8: expected a type, got =" {
+		t.Errorf("expected a parse error. got=%v", err)
+	}
+}
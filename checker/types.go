@@ -0,0 +1,179 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type is the static type of an expression.
+type Type interface {
+	String() string
+}
+
+// Basic is one of the types int, bool, string and null.
+type Basic struct {
+	Name string
+}
+
+var (
+	Int    = &Basic{Name: "int"}
+	Bool   = &Basic{Name: "bool"}
+	String = &Basic{Name: "string"}
+	Null   = &Basic{Name: "null"}
+)
+
+// Any is the type of values the checker knows nothing about. It is
+// compatible with every type, which is what makes the checking gradual.
+type Any struct{}
+
+var AnyType = &Any{}
+
+type Array struct {
+	Element Type
+}
+
+type Hash struct {
+	Key   Type
+	Value Type
+}
+
+type Function struct {
+	Parameters []Type
+	Return     Type
+}
+
+// Enum is the type of the values of the enum called Name.
+type Enum struct {
+	Name string
+}
+
+// Variable is a type that has not been inferred yet. Once unification
+// decides what it is, instance is set.
+type Variable struct {
+	id       int
+	level    int
+	instance Type
+}
+
+func (b *Basic) String() string    { return b.Name }
+func (a *Any) String() string      { return "any" }
+func (a *Array) String() string    { return typeString(a, map[*Variable]string{}) }
+func (h *Hash) String() string     { return typeString(h, map[*Variable]string{}) }
+func (f *Function) String() string { return typeString(f, map[*Variable]string{}) }
+func (e *Enum) String() string     { return e.Name }
+func (v *Variable) String() string { return typeString(v, map[*Variable]string{}) }
+
+// typeString writes t with its unknown parts named a, b, c and so on in
+// the order they appear.
+func typeString(t Type, names map[*Variable]string) string {
+	switch t := prune(t).(type) {
+	case *Variable:
+		if _, ok := names[t]; !ok {
+			names[t] = variableName(len(names))
+		}
+		return names[t]
+	case *Array:
+		return "[" + typeString(t.Element, names) + "]"
+	case *Hash:
+		return "{" + typeString(t.Key, names) + ": " + typeString(t.Value, names) + "}"
+	case *Function:
+		params := make([]string, len(t.Parameters))
+		for i, param := range t.Parameters {
+			params[i] = typeString(param, names)
+		}
+		return "fn(" + strings.Join(params, ", ") + ") -> " + typeString(t.Return, names)
+	default:
+		return t.String()
+	}
+}
+
+func variableName(i int) string {
+	name := string(rune('a' + i%26))
+	if i >= 26 {
+		name += fmt.Sprint(i / 26)
+	}
+	return name
+}
+
+// objectType returns the name the evaluator uses for values of type t, so
+// that the checker reports the same errors the evaluator would.
+func objectType(t Type) string {
+	switch t := prune(t).(type) {
+	case *Basic:
+		switch t {
+		case Int:
+			return "INTEGER"
+		case Bool:
+			return "BOOLEAN"
+		case String:
+			return "STRING"
+		}
+		return "NULL"
+	case *Array:
+		return "ARRAY"
+	case *Hash:
+		return "HASH"
+	case *Function:
+		return "FUNCTION"
+	case *Enum:
+		return "ENUM_VALUE"
+	}
+	return t.String()
+}
+
+// prune follows instantiated variables to the type they stand for.
+func prune(t Type) Type {
+	for {
+		v, ok := t.(*Variable)
+		if !ok || v.instance == nil {
+			return t
+		}
+		t = v.instance
+	}
+}
+
+// resolve returns t with every instantiated variable replaced by its
+// instance.
+func resolve(t Type) Type {
+	switch t := prune(t).(type) {
+	case *Array:
+		return &Array{Element: resolve(t.Element)}
+	case *Hash:
+		return &Hash{Key: resolve(t.Key), Value: resolve(t.Value)}
+	case *Function:
+		params := make([]Type, len(t.Parameters))
+		for i, param := range t.Parameters {
+			params[i] = resolve(param)
+		}
+		return &Function{Parameters: params, Return: resolve(t.Return)}
+	default:
+		return t
+	}
+}
+
+// occurs reports whether v appears in t.
+func occurs(v *Variable, t Type) bool {
+	switch t := prune(t).(type) {
+	case *Variable:
+		return t == v
+	case *Array:
+		return occurs(v, t.Element)
+	case *Hash:
+		return occurs(v, t.Key) || occurs(v, t.Value)
+	case *Function:
+		for _, param := range t.Parameters {
+			if occurs(v, param) {
+				return true
+			}
+		}
+		return occurs(v, t.Return)
+	}
+	return false
+}
+
+// scheme is the type of a let-bound function, which is generic in vars:
+// each use of the binding gets its own copy of them.
+type scheme struct {
+	vars []*Variable
+	t    Type
+}
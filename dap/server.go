@@ -0,0 +1,473 @@
+package dap
+
+import (
+	"WeekTwo/debugger"
+	"WeekTwo/interpreter"
+	"WeekTwo/object"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// threadID is the only thread the server reports. Tasks started with spawn
+// are not shown as threads of their own.
+const threadID = 1
+
+// Server serves the Debug Adapter Protocol for one debugging session: it
+// runs the program given by a launch request once the client has sent its
+// configuration, and pauses it at breakpoints and while stepping.
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	writeMu sync.Mutex
+	seq     int
+
+	dbg     *debugger.Debugger
+	launch  *LaunchArguments
+	started bool
+
+	// The fields below describe the current stop and are guarded by mu.
+	// The evaluation is blocked on resume while stop is set.
+	mu           sync.Mutex
+	stop         *debugger.Stop
+	resume       chan debugger.Action
+	references   [][]debugger.Variable
+	entry        bool // whether the next stop is the one on entry
+	disconnected bool
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	s := &Server{
+		in:     bufio.NewReader(in),
+		out:    out,
+		resume: make(chan debugger.Action),
+	}
+	s.dbg = debugger.New(s.pause)
+	return s
+}
+
+// Serve handles requests until the client disconnects or in is exhausted.
+func (s *Server) Serve() error {
+	for {
+		msg, err := ReadMessage(s.in)
+		if err != nil {
+			s.disconnect()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if msg.Type != "request" {
+			continue
+		}
+
+		body, err := s.handle(msg)
+		s.respond(msg, body, err)
+
+		// The program resumes after the response, so that the client sees
+		// the response before the program's next event.
+		if action, ok := resumeActions[msg.Command]; ok && err == nil {
+			s.resume <- action
+			continue
+		}
+
+		switch msg.Command {
+		case "initialize":
+			s.send("initialized", nil)
+		case "configurationDone":
+			if err == nil {
+				s.start()
+			}
+		case "disconnect":
+			s.disconnect()
+			return nil
+		}
+	}
+}
+
+func (s *Server) handle(msg *Message) (any, error) {
+	switch msg.Command {
+	case "initialize":
+		return Capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsFunctionBreakpoints:      true,
+			SupportsEvaluateForHovers:        true,
+		}, nil
+	case "launch":
+		var args LaunchArguments
+		if err := decode(msg, &args); err != nil {
+			return nil, err
+		}
+		if args.Program == "" {
+			return nil, errors.New("launch requires a program")
+		}
+		s.launch = &args
+		return nil, nil
+	case "setBreakpoints":
+		return s.setBreakpoints(msg)
+	case "setFunctionBreakpoints":
+		var args SetFunctionBreakpointsArguments
+		if err := decode(msg, &args); err != nil {
+			return nil, err
+		}
+		breakpoints := make([]Breakpoint, len(args.Breakpoints))
+		s.dbg.ClearFunctionBreakpoints()
+		for i, b := range args.Breakpoints {
+			s.dbg.SetFunctionBreakpoint(b.Name)
+			breakpoints[i] = Breakpoint{Verified: true}
+		}
+		return map[string]any{"breakpoints": breakpoints}, nil
+	case "configurationDone":
+		if s.launch == nil {
+			return nil, errors.New("no program was launched")
+		}
+		return nil, nil
+	case "threads":
+		return map[string]any{"threads": []Thread{{ID: threadID, Name: "main"}}}, nil
+	case "continue":
+		return map[string]any{"allThreadsContinued": true}, s.unpause()
+	case "next", "stepIn", "stepOut":
+		return nil, s.unpause()
+	case "stackTrace":
+		return s.stackTrace()
+	case "scopes":
+		return s.scopes(msg)
+	case "variables":
+		return s.variables(msg)
+	case "evaluate":
+		return s.evaluate(msg)
+	case "disconnect", "terminate":
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unsupported request %q", msg.Command)
+}
+
+func decode(msg *Message, args any) error {
+	if len(msg.Arguments) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(msg.Arguments, args); err != nil {
+		return fmt.Errorf("invalid arguments to %s: %s", msg.Command, err)
+	}
+	return nil
+}
+
+func (s *Server) setBreakpoints(msg *Message) (any, error) {
+	var args SetBreakpointsArguments
+	if err := decode(msg, &args); err != nil {
+		return nil, err
+	}
+
+	file := cleanPath(args.Source.Path)
+	s.dbg.ClearBreakpoints(file)
+
+	breakpoints := make([]Breakpoint, len(args.Breakpoints))
+	for i, b := range args.Breakpoints {
+		s.dbg.SetBreakpoint(file, b.Line)
+		breakpoints[i] = Breakpoint{Verified: true, Line: b.Line}
+	}
+
+	return map[string]any{"breakpoints": breakpoints}, nil
+}
+
+// cleanPath returns path in the form the interpreter records the files of
+// the programs and modules it runs.
+func cleanPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// start runs the launched program on its own goroutine.
+func (s *Server) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+
+	launch := *s.launch
+	program := cleanPath(launch.Program)
+
+	interp := interpreter.New(filepath.Dir(program))
+	interp.SetIO(strings.NewReader(""), &output{s, "stdout"}, &output{s, "stderr"})
+	if !launch.NoDebug {
+		interp.SetTracer(s.dbg)
+		if launch.StopOnEntry {
+			s.entry = true
+			s.dbg.Step()
+		}
+	}
+
+	go func() {
+		exitCode := 0
+		if _, err := interp.RunFile(program); err != nil {
+			exitCode = 1
+			message := err.Error()
+			if runtimeErr, ok := err.(*interpreter.RuntimeError); ok {
+				message = runtimeErr.Traceback()
+			}
+			s.send("output", OutputEvent{Category: "stderr", Output: message + "\n"})
+		}
+
+		s.send("exited", ExitedEvent{ExitCode: exitCode})
+		s.send("terminated", nil)
+	}()
+}
+
+// pause is called on the evaluation's goroutine when it stops, and blocks
+// until the client resumes it.
+func (s *Server) pause(stop *debugger.Stop) debugger.Action {
+	s.mu.Lock()
+	if s.disconnected {
+		s.mu.Unlock()
+		return debugger.Continue
+	}
+	s.stop = stop
+	s.references = nil
+	entry := s.entry
+	s.entry = false
+	s.mu.Unlock()
+
+	reason := stop.Reason
+	if entry {
+		reason = "entry"
+	}
+	s.send("stopped", StoppedEvent{Reason: reason, ThreadID: threadID, AllThreadsStopped: true})
+
+	return <-s.resume
+}
+
+var resumeActions = map[string]debugger.Action{
+	"continue": debugger.Continue,
+	"next":     debugger.StepOver,
+	"stepIn":   debugger.StepIn,
+	"stepOut":  debugger.StepOut,
+}
+
+// unpause forgets the current stop before the program is resumed.
+func (s *Server) unpause() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.paused(); err != nil {
+		return err
+	}
+	s.stop = nil
+	s.references = nil
+	return nil
+}
+
+func (s *Server) disconnect() {
+	s.mu.Lock()
+	s.disconnected = true
+	paused := s.stop != nil
+	s.stop = nil
+	s.mu.Unlock()
+
+	if paused {
+		s.resume <- debugger.Continue
+	}
+}
+
+// paused returns the current stop, or an error if the program is running.
+func (s *Server) paused() (*debugger.Stop, error) {
+	if s.stop == nil {
+		return nil, errors.New("the program is not paused")
+	}
+	return s.stop, nil
+}
+
+func (s *Server) stackTrace() (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stop, err := s.paused()
+	if err != nil {
+		return nil, err
+	}
+
+	frames := make([]StackFrame, len(stop.Stack))
+	for i, f := range stop.Stack {
+		frames[i] = StackFrame{ID: i + 1, Name: f.Function, Line: f.Line, Column: f.Column}
+		if f.File != "" {
+			frames[i].Source = &Source{Name: filepath.Base(f.File), Path: f.File}
+		}
+	}
+
+	return map[string]any{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+// frame returns the paused frame with id, which is its index in the stack
+// counting from 1.
+func (s *Server) frame(id int) (debugger.Frame, error) {
+	stop, err := s.paused()
+	if err != nil {
+		return debugger.Frame{}, err
+	}
+	if id < 1 || id > len(stop.Stack) {
+		return debugger.Frame{}, fmt.Errorf("no frame with id %d", id)
+	}
+	return stop.Stack[id-1], nil
+}
+
+func (s *Server) scopes(msg *Message) (any, error) {
+	var args ScopesArguments
+	if err := decode(msg, &args); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	frame, err := s.frame(args.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []Scope{}
+	for _, scope := range debugger.Scopes(frame.Env) {
+		name := strings.ToUpper(scope.Name[:1]) + scope.Name[1:]
+		scopes = append(scopes, Scope{
+			Name:               name,
+			VariablesReference: s.reference(scope.Variables),
+			Expensive:          scope.Name == "global",
+		})
+	}
+
+	return map[string]any{"scopes": scopes}, nil
+}
+
+// reference records variables for a later variables request. References
+// are valid until the program resumes.
+func (s *Server) reference(variables []debugger.Variable) int {
+	s.references = append(s.references, variables)
+	return len(s.references)
+}
+
+func (s *Server) variables(msg *Message) (any, error) {
+	var args VariablesArguments
+	if err := decode(msg, &args); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.paused(); err != nil {
+		return nil, err
+	}
+	if args.VariablesReference < 1 || args.VariablesReference > len(s.references) {
+		return nil, fmt.Errorf("no variables with reference %d", args.VariablesReference)
+	}
+
+	variables := []Variable{}
+	for _, v := range s.references[args.VariablesReference-1] {
+		variables = append(variables, Variable{
+			Name:               v.Name,
+			Value:              v.Value.Inspect(),
+			Type:               string(v.Value.Type()),
+			VariablesReference: s.children(v.Value),
+		})
+	}
+
+	return map[string]any{"variables": variables}, nil
+}
+
+// children returns a reference to the elements of an array or the pairs of
+// a hash, and 0 for other values.
+func (s *Server) children(value object.Object) int {
+	var variables []debugger.Variable
+
+	switch value := value.(type) {
+	case *object.Array:
+		for i, el := range value.Elements {
+			variables = append(variables, debugger.Variable{Name: fmt.Sprintf("[%d]", i), Value: el})
+		}
+	case *object.Hash:
+		for _, pair := range value.Pairs {
+			variables = append(variables, debugger.Variable{Name: pair.Key.Inspect(), Value: pair.Value})
+		}
+		sort.Slice(variables, func(i, j int) bool { return variables[i].Name < variables[j].Name })
+	default:
+		return 0
+	}
+
+	if len(variables) == 0 {
+		return 0
+	}
+	return s.reference(variables)
+}
+
+func (s *Server) evaluate(msg *Message) (any, error) {
+	var args EvaluateArguments
+	if err := decode(msg, &args); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	frame, err := s.frame(args.FrameID)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	result := s.dbg.Evaluate(args.Expression, frame.Env)
+	if result.Type() == object.ERROR_OBJ {
+		return nil, errors.New(strings.TrimPrefix(result.Inspect(), "ERROR: "))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return map[string]any{
+		"result":             result.Inspect(),
+		"type":               string(result.Type()),
+		"variablesReference": s.children(result),
+	}, nil
+}
+
+func (s *Server) respond(request *Message, body any, err error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.seq++
+	response := &Response{
+		Seq:        s.seq,
+		Type:       "response",
+		RequestSeq: request.Seq,
+		Success:    err == nil,
+		Command:    request.Command,
+		Body:       body,
+	}
+	if err != nil {
+		response.Message = err.Error()
+	}
+	WriteMessage(s.out, response)
+}
+
+func (s *Server) send(event string, body any) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.seq++
+	WriteMessage(s.out, &Event{Seq: s.seq, Type: "event", Event: event, Body: body})
+}
+
+// output sends what the program writes to a stream as output events.
+type output struct {
+	server   *Server
+	category string
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.server.send("output", OutputEvent{Category: o.category, Output: string(p)})
+	return len(p), nil
+}
@@ -0,0 +1,324 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client is a scripted DAP client connected to a Server. It reads the
+// server's messages as they arrive, so that the server never blocks on
+// writing while the client writes a request.
+type client struct {
+	t        *testing.T
+	received chan *Message
+	out      io.Writer
+	seq      int
+	pending  []*Message
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	go func() {
+		NewServer(serverIn, serverOut).Serve()
+		serverOut.Close()
+	}()
+	t.Cleanup(func() { clientOut.Close() })
+
+	received := make(chan *Message, 100)
+	go func() {
+		defer close(received)
+		in := bufio.NewReader(clientIn)
+		for {
+			msg, err := ReadMessage(in)
+			if err != nil {
+				return
+			}
+			received <- msg
+		}
+	}()
+
+	return &client{t: t, received: received, out: clientOut}
+}
+
+func (c *client) next() *Message {
+	c.t.Helper()
+
+	if len(c.pending) > 0 {
+		msg := c.pending[0]
+		c.pending = c.pending[1:]
+		return msg
+	}
+
+	select {
+	case msg, ok := <-c.received:
+		if !ok {
+			c.t.Fatalf("connection closed")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timed out waiting for a message")
+	}
+	return nil
+}
+
+// request sends a request and returns its response, keeping the events
+// that arrive in the meantime for event.
+func (c *client) request(command string, arguments any, body any) *Message {
+	c.t.Helper()
+
+	c.seq++
+	if err := WriteMessage(c.out, &Request{Seq: c.seq, Type: "request", Command: command, Arguments: arguments}); err != nil {
+		c.t.Fatalf("could not send %s: %s", command, err)
+	}
+
+	var skipped []*Message
+	for {
+		msg := c.next()
+		if msg.Type == "response" && msg.RequestSeq == c.seq {
+			c.pending = append(skipped, c.pending...)
+			if body != nil && msg.Success {
+				if err := json.Unmarshal(msg.Body, body); err != nil {
+					c.t.Fatalf("could not decode %s response: %s", command, err)
+				}
+			}
+			return msg
+		}
+		skipped = append(skipped, msg)
+	}
+}
+
+func (c *client) success(command string, arguments any, body any) {
+	c.t.Helper()
+	if response := c.request(command, arguments, body); !response.Success {
+		c.t.Fatalf("%s failed: %s", command, response.Message)
+	}
+}
+
+// event returns the next event called name, and the output of the output
+// events before it.
+func (c *client) event(name string, body any) string {
+	c.t.Helper()
+
+	var output strings.Builder
+	for {
+		msg := c.next()
+		if msg.Type != "event" {
+			continue
+		}
+		if msg.Event == name {
+			if body != nil {
+				json.Unmarshal(msg.Body, body)
+			}
+			return output.String()
+		}
+		if msg.Event == "output" {
+			var event OutputEvent
+			json.Unmarshal(msg.Body, &event)
+			output.WriteString(event.Output)
+		}
+	}
+}
+
+type stackTrace struct {
+	StackFrames []StackFrame `json:"stackFrames"`
+}
+
+func (c *client) stack() []StackFrame {
+	c.t.Helper()
+	var body stackTrace
+	c.success("stackTrace", StackTraceArguments{ThreadID: threadID}, &body)
+	return body.StackFrames
+}
+
+func (c *client) variables(reference int) map[string]Variable {
+	c.t.Helper()
+	var body struct {
+		Variables []Variable `json:"variables"`
+	}
+	c.success("variables", VariablesArguments{VariablesReference: reference}, &body)
+
+	variables := make(map[string]Variable)
+	for _, v := range body.Variables {
+		variables[v.Name] = v
+	}
+	return variables
+}
+
+func (c *client) scopes(frame int) []Scope {
+	c.t.Helper()
+	var body struct {
+		Scopes []Scope `json:"scopes"`
+	}
+	c.success("scopes", ScopesArguments{FrameID: frame}, &body)
+	return body.Scopes
+}
+
+func writeProgram(t *testing.T, source string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.mk")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const program = `let double = fn(n) {
+  let d = n * 2;
+  d
+};
+let xs = [1, 2];
+let total = double(xs[0]) + double(xs[1]);
+puts(total);
+`
+
+func TestDebugSession(t *testing.T) {
+	path := writeProgram(t, program)
+	c := newClient(t)
+
+	var capabilities Capabilities
+	c.success("initialize", map[string]any{"adapterID": "monkey"}, &capabilities)
+	if !capabilities.SupportsConfigurationDoneRequest {
+		t.Errorf("configurationDone not supported")
+	}
+	c.event("initialized", nil)
+
+	c.success("launch", LaunchArguments{Program: path}, nil)
+
+	var breakpoints struct {
+		Breakpoints []Breakpoint `json:"breakpoints"`
+	}
+	c.success("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Path: path},
+		Breakpoints: []SourceBreakpoint{{Line: 2}},
+	}, &breakpoints)
+	if len(breakpoints.Breakpoints) != 1 || !breakpoints.Breakpoints[0].Verified {
+		t.Errorf("breakpoint not verified. got=%+v", breakpoints.Breakpoints)
+	}
+
+	c.success("configurationDone", nil, nil)
+
+	var stopped StoppedEvent
+	c.event("stopped", &stopped)
+	if stopped.Reason != "breakpoint" {
+		t.Errorf("wrong stop reason. got=%q", stopped.Reason)
+	}
+
+	frames := c.stack()
+	if len(frames) != 2 || frames[0].Name != "double" || frames[0].Line != 2 || frames[1].Name != "<program>" || frames[1].Line != 6 {
+		t.Fatalf("wrong stack. got=%+v", frames)
+	}
+	if frames[0].Source == nil || frames[0].Source.Path != path {
+		t.Errorf("wrong source. got=%+v", frames[0].Source)
+	}
+
+	scopes := c.scopes(frames[0].ID)
+	if len(scopes) != 2 || scopes[0].Name != "Local" || scopes[1].Name != "Global" {
+		t.Fatalf("wrong scopes. got=%+v", scopes)
+	}
+	locals := c.variables(scopes[0].VariablesReference)
+	if len(locals) != 1 || locals["n"].Value != "1" || locals["n"].Type != "INTEGER" {
+		t.Errorf("wrong locals. got=%+v", locals)
+	}
+
+	c.success("next", map[string]any{"threadId": threadID}, nil)
+	c.event("stopped", &stopped)
+	if stopped.Reason != "step" {
+		t.Errorf("wrong stop reason. got=%q", stopped.Reason)
+	}
+	if frames := c.stack(); frames[0].Line != 3 {
+		t.Errorf("next stopped at line %d, want 3", frames[0].Line)
+	}
+
+	var evaluated struct {
+		Result string `json:"result"`
+	}
+	c.success("evaluate", EvaluateArguments{Expression: "d + 1", FrameID: 1}, &evaluated)
+	if evaluated.Result != "3" {
+		t.Errorf("wrong evaluation. got=%q", evaluated.Result)
+	}
+	if response := c.request("evaluate", EvaluateArguments{Expression: "missing", FrameID: 1}, nil); response.Success {
+		t.Errorf("evaluating an unknown identifier succeeded")
+	}
+
+	c.success("continue", map[string]any{"threadId": threadID}, nil)
+	c.event("stopped", nil)
+	locals = c.variables(c.scopes(1)[0].VariablesReference)
+	if locals["n"].Value != "2" {
+		t.Errorf("second call has wrong locals. got=%+v", locals)
+	}
+
+	c.success("stepOut", map[string]any{"threadId": threadID}, nil)
+	c.event("stopped", nil)
+	frames = c.stack()
+	if len(frames) != 1 || frames[0].Line != 7 {
+		t.Fatalf("stepOut stopped at %+v, want line 7 of the program", frames)
+	}
+
+	globals := c.variables(c.scopes(1)[0].VariablesReference)
+	if globals["total"].Value != "6" {
+		t.Errorf("wrong total. got=%+v", globals["total"])
+	}
+	xs := globals["xs"]
+	if xs.VariablesReference == 0 {
+		t.Fatalf("array has no children")
+	}
+	elements := c.variables(xs.VariablesReference)
+	if elements["[0]"].Value != "1" || elements["[1]"].Value != "2" {
+		t.Errorf("wrong elements. got=%+v", elements)
+	}
+
+	c.success("continue", map[string]any{"threadId": threadID}, nil)
+
+	var exited ExitedEvent
+	output := c.event("exited", &exited)
+	if output != "6\n" {
+		t.Errorf("wrong output. got=%q", output)
+	}
+	if exited.ExitCode != 0 {
+		t.Errorf("wrong exit code. got=%d", exited.ExitCode)
+	}
+	c.event("terminated", nil)
+
+	if response := c.request("continue", map[string]any{"threadId": threadID}, nil); response.Success {
+		t.Errorf("continue succeeded after the program ended")
+	}
+	c.success("disconnect", nil, nil)
+}
+
+func TestStopOnEntryAndRuntimeErrors(t *testing.T) {
+	path := writeProgram(t, "let x = 1;\nx + true;\n")
+	c := newClient(t)
+
+	c.success("initialize", nil, nil)
+	c.success("launch", LaunchArguments{Program: path, StopOnEntry: true}, nil)
+	c.success("configurationDone", nil, nil)
+
+	var stopped StoppedEvent
+	c.event("stopped", &stopped)
+	if stopped.Reason != "entry" {
+		t.Errorf("wrong stop reason. got=%q", stopped.Reason)
+	}
+	if frames := c.stack(); frames[0].Line != 1 {
+		t.Errorf("stopped at line %d, want 1", frames[0].Line)
+	}
+
+	c.success("continue", nil, nil)
+
+	var exited ExitedEvent
+	output := c.event("exited", &exited)
+	if exited.ExitCode != 1 {
+		t.Errorf("wrong exit code. got=%d", exited.ExitCode)
+	}
+	if !strings.Contains(output, "type mismatch: INTEGER + BOOLEAN") {
+		t.Errorf("error not reported. got=%q", output)
+	}
+	c.success("disconnect", nil, nil)
+}
@@ -0,0 +1,416 @@
+package debugger
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/evaluator"
+	"WeekTwo/lexer"
+	"WeekTwo/object"
+	"WeekTwo/parser"
+	"WeekTwo/token"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Action tells a paused evaluation how to continue.
+type Action int
+
+const (
+	// Continue runs until the next breakpoint.
+	Continue Action = iota
+	// StepIn pauses at the next statement, entering function calls.
+	StepIn
+	// StepOver pauses at the next statement of the current function or of
+	// a function it returns to.
+	StepOver
+	// StepOut pauses at the next statement of the function that called the
+	// current one.
+	StepOut
+)
+
+// Frame is a function call that is active while the evaluation is paused.
+// Line and Column are the position of the statement the frame is at.
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+	Column   int
+	Env      *object.Environment
+}
+
+func (f Frame) String() string {
+	location := fmt.Sprintf("line %d, column %d", f.Line, f.Column)
+	if f.File != "" {
+		location = fmt.Sprintf("%s:%d:%d", f.File, f.Line, f.Column)
+	}
+	return fmt.Sprintf("%s at %s", f.Function, location)
+}
+
+// Stop describes a paused evaluation.
+type Stop struct {
+	Reason    string // "breakpoint" or "step"
+	Statement ast.Statement
+	// Stack holds the active frames, innermost first. The last frame is
+	// the top level of the program.
+	Stack []Frame
+}
+
+// Breakpoint pauses the evaluation before the statements that start on
+// Line of File. File is "" for code that was not loaded from a file.
+type Breakpoint struct {
+	File string
+	Line int
+}
+
+func (b Breakpoint) String() string {
+	if b.File == "" {
+		return fmt.Sprintf("line %d", b.Line)
+	}
+	return fmt.Sprintf("%s:%d", b.File, b.Line)
+}
+
+// Debugger is an object.Tracer that pauses the evaluation at breakpoints
+// and while stepping, and calls OnStop with the evaluation paused. The
+// evaluation resumes as OnStop returns, in the way its Action says.
+//
+// OnStop runs on the goroutine of the paused evaluation and may inspect it
+// through Scopes and Evaluate. Statements evaluated in the meantime, for
+// example by tasks that keep running, are not traced.
+type Debugger struct {
+	OnStop func(stop *Stop) Action
+
+	mu          sync.Mutex
+	breakpoints map[Breakpoint]bool
+	functions   map[string]bool
+	watches     []string
+	root        Frame
+	stack       []Frame
+	action      Action
+	stepDepth   int
+	paused      bool
+	entered     string // the function a function breakpoint was hit for
+}
+
+const topLevel = "<program>"
+
+// New returns a debugger that runs until a breakpoint is hit. Install it
+// with Attach.
+func New(onStop func(stop *Stop) Action) *Debugger {
+	return &Debugger{
+		OnStop:      onStop,
+		breakpoints: make(map[Breakpoint]bool),
+		functions:   make(map[string]bool),
+		root:        Frame{Function: topLevel},
+	}
+}
+
+// Attach makes d trace evaluations in env and the environments enclosed
+// in it.
+func (d *Debugger) Attach(env *object.Environment) {
+	env.SetTracer(d)
+}
+
+// Eval evaluates node in env, which d should be attached to. Stepping ends
+// with the evaluation, so that the next one runs to its first breakpoint.
+func (d *Debugger) Eval(node ast.Node, env *object.Environment) object.Object {
+	evaluated := evaluator.Eval(node, env)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.action = Continue
+	d.stack = d.stack[:0]
+
+	return evaluated
+}
+
+func (d *Debugger) SetBreakpoint(file string, line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[Breakpoint{File: file, Line: line}] = true
+}
+
+func (d *Debugger) ClearBreakpoint(file string, line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints, Breakpoint{File: file, Line: line})
+}
+
+// ClearBreakpoints removes the line breakpoints of file.
+func (d *Debugger) ClearBreakpoints(file string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for b := range d.breakpoints {
+		if b.File == file {
+			delete(d.breakpoints, b)
+		}
+	}
+}
+
+// Breakpoints returns the line breakpoints sorted by file and line.
+func (d *Debugger) Breakpoints() []Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	breakpoints := make([]Breakpoint, 0, len(d.breakpoints))
+	for b := range d.breakpoints {
+		breakpoints = append(breakpoints, b)
+	}
+	sort.Slice(breakpoints, func(i, j int) bool {
+		if breakpoints[i].File != breakpoints[j].File {
+			return breakpoints[i].File < breakpoints[j].File
+		}
+		return breakpoints[i].Line < breakpoints[j].Line
+	})
+	return breakpoints
+}
+
+// SetFunctionBreakpoint pauses the evaluation at the first statement of
+// every call of the function bound to name.
+func (d *Debugger) SetFunctionBreakpoint(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.functions[name] = true
+}
+
+func (d *Debugger) ClearFunctionBreakpoint(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.functions, name)
+}
+
+func (d *Debugger) ClearFunctionBreakpoints() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.functions = make(map[string]bool)
+}
+
+// Step makes the evaluation pause at the next statement, as if it had
+// been resumed with StepIn.
+func (d *Debugger) Step() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.action = StepIn
+}
+
+// Watch adds an expression that Watches evaluates at every stop.
+func (d *Debugger) Watch(expression string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.watches = append(d.watches, expression)
+}
+
+// Unwatch removes the watch expression at index, and reports whether there
+// was one.
+func (d *Debugger) Unwatch(index int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if index < 0 || index >= len(d.watches) {
+		return false
+	}
+	d.watches = append(d.watches[:index], d.watches[index+1:]...)
+	return true
+}
+
+// Watch is the value of a watch expression in the paused frame.
+type Watch struct {
+	Expression string
+	Value      object.Object
+}
+
+// Watches evaluates the watch expressions in env.
+func (d *Debugger) Watches(env *object.Environment) []Watch {
+	d.mu.Lock()
+	expressions := append([]string(nil), d.watches...)
+	d.mu.Unlock()
+
+	watches := make([]Watch, len(expressions))
+	for i, expression := range expressions {
+		watches[i] = Watch{Expression: expression, Value: d.Evaluate(expression, env)}
+	}
+	return watches
+}
+
+// Evaluate evaluates source in a scope enclosed in env, so that it sees
+// the variables of a paused frame without adding to them. Parse errors are
+// returned as error objects.
+func (d *Debugger) Evaluate(source string, env *object.Environment) object.Object {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return &object.Error{Message: strings.Join(p.Errors(), "; ")}
+	}
+
+	evaluated := evaluator.Eval(program, object.NewEnclosedEnvironment(env))
+	if evaluated == nil {
+		return evaluator.NULL
+	}
+	return evaluated
+}
+
+// Variable is a binding shown while the evaluation is paused.
+type Variable struct {
+	Name  string
+	Value object.Object
+}
+
+// Scope holds the bindings of one environment.
+type Scope struct {
+	Name      string // "local", "closure" or "global"
+	Variables []Variable
+}
+
+// Scopes walks env and the environments it is enclosed in, innermost
+// first, skipping the enclosing ones that bind nothing. The innermost scope
+// is called "local" and the outermost "global". Variables are sorted by
+// name.
+func Scopes(env *object.Environment) []Scope {
+	scopes := []Scope{}
+
+	for e := env; e != nil; e = e.Outer() {
+		bindings := e.Bindings()
+		if len(bindings) == 0 && e != env {
+			continue
+		}
+
+		variables := make([]Variable, 0, len(bindings))
+		for name, value := range bindings {
+			variables = append(variables, Variable{Name: name, Value: value})
+		}
+		sort.Slice(variables, func(i, j int) bool { return variables[i].Name < variables[j].Name })
+
+		scopes = append(scopes, Scope{Name: "closure", Variables: variables})
+	}
+
+	scopes[0].Name = "local"
+	scopes[len(scopes)-1].Name = "global"
+
+	return scopes
+}
+
+// Stack returns the active frames, innermost first.
+func (d *Debugger) Stack() []Frame {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.frames()
+}
+
+func (d *Debugger) frames() []Frame {
+	frames := make([]Frame, 0, len(d.stack)+1)
+	for i := len(d.stack) - 1; i >= 0; i-- {
+		frames = append(frames, d.stack[i])
+	}
+	return append(frames, d.root)
+}
+
+func (d *Debugger) current() *Frame {
+	if len(d.stack) == 0 {
+		return &d.root
+	}
+	return &d.stack[len(d.stack)-1]
+}
+
+func (d *Debugger) EnterCall(fn *object.Function, env *object.Environment) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.paused {
+		return
+	}
+
+	name := fn.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	d.stack = append(d.stack, Frame{Function: name, File: env.File(), Env: env})
+
+	if d.functions[fn.Name] {
+		d.entered = fn.Name
+	}
+}
+
+func (d *Debugger) LeaveCall() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.paused || len(d.stack) == 0 {
+		return
+	}
+	d.stack = d.stack[:len(d.stack)-1]
+}
+
+func (d *Debugger) Statement(stmt ast.Statement, env *object.Environment) {
+	d.mu.Lock()
+	if d.paused {
+		d.mu.Unlock()
+		return
+	}
+
+	tok := statementToken(stmt)
+	frame := d.current()
+	frame.Line, frame.Column, frame.Env = tok.Line, tok.Column, env
+	if frame == &d.root {
+		frame.File = env.File()
+	}
+
+	reason := d.stopReason(frame)
+	if reason == "" || d.OnStop == nil {
+		d.mu.Unlock()
+		return
+	}
+
+	stop := &Stop{Reason: reason, Statement: stmt, Stack: d.frames()}
+	d.paused = true
+	d.mu.Unlock()
+
+	action := d.OnStop(stop)
+
+	d.mu.Lock()
+	d.paused = false
+	d.action = action
+	d.stepDepth = len(d.stack)
+	d.mu.Unlock()
+}
+
+func (d *Debugger) stopReason(frame *Frame) string {
+	if d.entered != "" {
+		d.entered = ""
+		return "breakpoint"
+	}
+	if d.breakpoints[Breakpoint{File: frame.File, Line: frame.Line}] {
+		return "breakpoint"
+	}
+
+	switch d.action {
+	case StepIn:
+		return "step"
+	case StepOver:
+		if len(d.stack) <= d.stepDepth {
+			return "step"
+		}
+	case StepOut:
+		if len(d.stack) < d.stepDepth {
+			return "step"
+		}
+	}
+	return ""
+}
+
+// statementToken returns the token a statement starts at.
+func statementToken(stmt ast.Statement) token.Token {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		return stmt.Token
+	case *ast.LetStatement:
+		return stmt.Token
+	case *ast.ReturnStatement:
+		return stmt.Token
+	case *ast.BlockStatement:
+		return stmt.Token
+	case *ast.EnumStatement:
+		return stmt.Token
+	case *ast.ImportStatement:
+		return stmt.Token
+	case *ast.ExportStatement:
+		return stmt.Token
+	}
+	return token.Token{}
+}
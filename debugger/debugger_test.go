@@ -0,0 +1,164 @@
+package debugger
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/lexer"
+	"WeekTwo/object"
+	"WeekTwo/parser"
+	"fmt"
+	"testing"
+)
+
+const program = `let double = fn(n) {
+  let d = n * 2;
+  d
+};
+let sum = fn(a, b) {
+  let x = double(a);
+  let y = double(b);
+  x + y
+};
+let total = sum(1, 2);
+total`
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+// run evaluates program, resuming each stop with the next of actions, and
+// returns where it stopped as "function:line".
+func run(t *testing.T, setup func(d *Debugger), actions ...Action) []string {
+	t.Helper()
+
+	var stops []string
+	d := New(func(stop *Stop) Action {
+		frame := stop.Stack[0]
+		stops = append(stops, fmt.Sprintf("%s:%d", frame.Function, frame.Line))
+		if len(stops) > len(actions) {
+			return Continue
+		}
+		return actions[len(stops)-1]
+	})
+	setup(d)
+
+	env := object.NewEnvironment()
+	d.Attach(env)
+	evaluated := d.Eval(parse(t, program), env)
+	if evaluated.Inspect() != "6" {
+		t.Fatalf("wrong result. got=%s", evaluated.Inspect())
+	}
+
+	return stops
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(d *Debugger)
+		actions  []Action
+		expected []string
+	}{
+		{
+			"breakpoint",
+			func(d *Debugger) { d.SetBreakpoint("", 2) },
+			nil,
+			[]string{"double:2", "double:2"},
+		},
+		{
+			"function breakpoint",
+			func(d *Debugger) { d.SetFunctionBreakpoint("sum") },
+			nil,
+			[]string{"sum:6"},
+		},
+		{
+			"step over",
+			func(d *Debugger) { d.SetBreakpoint("", 6) },
+			[]Action{StepOver, StepOver, StepOver},
+			[]string{"sum:6", "sum:7", "sum:8", "<program>:11"},
+		},
+		{
+			"step in",
+			func(d *Debugger) { d.SetBreakpoint("", 6) },
+			[]Action{StepIn, StepIn, StepIn, Continue},
+			[]string{"sum:6", "double:2", "double:3", "sum:7"},
+		},
+		{
+			"step out",
+			func(d *Debugger) { d.SetBreakpoint("", 2) },
+			[]Action{StepOut, Continue},
+			[]string{"double:2", "sum:7", "double:2"},
+		},
+		{
+			"step from the start",
+			func(d *Debugger) { d.Step() },
+			[]Action{StepOver, StepOver, StepOver},
+			[]string{"<program>:1", "<program>:5", "<program>:10", "<program>:11"},
+		},
+	}
+
+	for _, tt := range tests {
+		stops := run(t, tt.setup, tt.actions...)
+		if fmt.Sprint(stops) != fmt.Sprint(tt.expected) {
+			t.Errorf("%s: wrong stops. got=%v, want=%v", tt.name, stops, tt.expected)
+		}
+	}
+}
+
+func TestInspectingPausedFrames(t *testing.T) {
+	var stack []Frame
+	var scopes []Scope
+	var watches []Watch
+	var evaluated object.Object
+
+	d := New(nil)
+	d.OnStop = func(stop *Stop) Action {
+		stack = stop.Stack
+		scopes = Scopes(stop.Stack[0].Env)
+		watches = d.Watches(stop.Stack[0].Env)
+		evaluated = d.Evaluate("let z = n + 10; z", stop.Stack[0].Env)
+		return Continue
+	}
+	d.SetBreakpoint("", 3)
+	d.Watch("d * 10")
+	d.Watch("missing")
+
+	env := object.NewEnvironment()
+	d.Attach(env)
+	d.Eval(parse(t, `let double = fn(n) {
+  let d = n * 2;
+  d
+};
+double(4)`), env)
+
+	if len(stack) != 2 || stack[0].String() != "double at line 3, column 3" || stack[1].String() != "<program> at line 5, column 1" {
+		t.Errorf("wrong stack. got=%v", stack)
+	}
+
+	if len(scopes) != 2 || scopes[0].Name != "local" || scopes[1].Name != "global" {
+		t.Fatalf("wrong scopes. got=%+v", scopes)
+	}
+	locals := ""
+	for _, v := range scopes[0].Variables {
+		locals += v.Name + "=" + v.Value.Inspect() + " "
+	}
+	if locals != "d=8 n=4 " {
+		t.Errorf("wrong locals. got=%s", locals)
+	}
+
+	if len(watches) != 2 || watches[0].Value.Inspect() != "80" || watches[1].Value.Inspect() != "ERROR: identifier not found: missing" {
+		t.Errorf("wrong watches. got=%+v", watches)
+	}
+
+	if evaluated.Inspect() != "14" {
+		t.Errorf("wrong evaluation. got=%s", evaluated.Inspect())
+	}
+	if _, ok := stack[0].Env.Get("z"); ok {
+		t.Errorf("evaluation leaked a binding into the paused frame")
+	}
+}
@@ -3,114 +3,484 @@ package evaluator
 import (
 	"WeekTwo/object"
 	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
 )
 
 var builtins = map[string]*object.Builtin{
-	"len": {
-		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+	"len": limited(func(limiter object.Limiter, args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		switch arg := args[0].(type) {
+		case *object.String:
+			return &object.Integer{Value: int64(len(arg.Value))}
+		case *object.Array:
+			return &object.Integer{Value: int64(len(arg.Elements))}
+		case *object.Hash:
+			return &object.Integer{Value: int64(len(arg.Pairs))}
+		case *object.Channel:
+			return &object.Integer{Value: int64(len(arg.C))}
+		}
+
+		it, ok := iteratorOf(args[0], limiter)
+		if !ok {
+			return newError("argument to `len` not supported, got %s", args[0].Type())
+		}
+
+		var length int64
+		for {
+			value, ok := nextElement(it, limiter)
+			if !ok {
+				break
+			}
+			if isError(value) {
+				return value
+			}
+			length++
+		}
+
+		return &object.Integer{Value: length}
+	}),
+
+	"first": limited(func(limiter object.Limiter, args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		it, ok := iteratorOf(args[0], limiter)
+		if !ok {
+			return newError("argument to `first` must be iterable, got %s", args[0].Type())
+		}
+
+		if value, ok := nextElement(it, limiter); ok {
+			return value
+		}
+
+		return NULL
+	}),
+
+	"last": limited(func(limiter object.Limiter, args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		if arr, ok := args[0].(*object.Array); ok {
+			length := len(arr.Elements)
+			if length > 0 {
+				return arr.Elements[length-1]
 			}
+			return NULL
+		}
+
+		it, ok := iteratorOf(args[0], limiter)
+		if !ok {
+			return newError("argument to `last` must be iterable, got %s", args[0].Type())
+		}
 
-			switch arg := args[0].(type) {
-			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
-			case *object.Array:
-				return &object.Integer{Value: int64(len(arg.Elements))}
-			default:
-				return newError("argument to `len` not supported, got %s", args[0].Type())
+		var last object.Object = NULL
+		for {
+			value, ok := nextElement(it, limiter)
+			if !ok {
+				break
 			}
-		},
-	},
+			if isError(value) {
+				return value
+			}
+			last = value
+		}
+
+		return last
+	}),
+
+	"rest": limited(func(limiter object.Limiter, args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		if arr, ok := args[0].(*object.Array); ok {
+			length := len(arr.Elements)
+			if length > 0 {
+				if err := charge(limiter, length-1); err != nil {
+					return err
+				}
+				newElements := make([]object.Object, length-1)
+				copy(newElements, arr.Elements[1:length])
+				return &object.Array{Elements: newElements}
+			}
+			return NULL
+		}
+
+		it, ok := iteratorOf(args[0], limiter)
+		if !ok {
+			return newError("argument to `rest` must be iterable, got %s", args[0].Type())
+		}
+
+		if _, ok := nextElement(it, limiter); !ok {
+			return NULL
+		}
+
+		return collectIterator(it, limiter)
+	}),
+
+	"push": limited(func(limiter object.Limiter, args ...object.Object) object.Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+
+		if args[0].Type() != object.ARRAY_OBJ {
+			return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
+		}
 
-	"first": {
+		arr := args[0].(*object.Array)
+		length := len(arr.Elements)
+
+		if err := charge(limiter, length+1); err != nil {
+			return err
+		}
+		newElements := make([]object.Object, length+1)
+		copy(newElements, arr.Elements)
+		newElements[length] = args[1]
+
+		return &object.Array{Elements: newElements}
+	}),
+	"next": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `first` must be ARRAY, got %s", args[0].Type())
+			gen, ok := args[0].(*object.Generator)
+			if !ok {
+				return newError("argument to `next` must be GENERATOR, got %s", args[0].Type())
 			}
 
-			arr := args[0].(*object.Array)
-			if len(arr.Elements) > 0 {
-				return arr.Elements[0]
+			if value, ok := gen.Next(); ok {
+				return value
 			}
 
 			return NULL
 		},
 	},
 
-	"last": {
+	"collect": limited(func(limiter object.Limiter, args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		it, ok := iteratorOf(args[0], limiter)
+		if !ok {
+			return newError("argument to `collect` must be iterable, got %s", args[0].Type())
+		}
+
+		return collectIterator(it, limiter)
+	}),
+
+	"range": {
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			if len(args) < 1 || len(args) > 3 {
+				return newError("wrong number of arguments. got=%d, want=1..3", len(args))
 			}
 
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `last` must be ARRAY, got %s", args[0].Type())
+			bounds := make([]int64, len(args))
+			for i, arg := range args {
+				integer, ok := arg.(*object.Integer)
+				if !ok {
+					return newError("arguments to `range` must be INTEGER, got %s", arg.Type())
+				}
+				bounds[i] = integer.Value
 			}
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				return arr.Elements[length-1]
+			r := &object.Range{Step: 1}
+			switch len(bounds) {
+			case 1:
+				r.End = bounds[0]
+			case 2:
+				r.Start, r.End = bounds[0], bounds[1]
+			case 3:
+				r.Start, r.End, r.Step = bounds[0], bounds[1], bounds[2]
 			}
 
-			return NULL
+			if r.Step == 0 {
+				return newError("`range` step must not be zero")
+			}
+
+			return r
 		},
 	},
 
-	"rest": {
+	"channel": limited(func(limiter object.Limiter, args ...object.Object) object.Object {
+		if len(args) > 1 {
+			return newError("wrong number of arguments. got=%d, want=0..1", len(args))
+		}
+
+		if len(args) == 0 {
+			return object.NewChannel(0)
+		}
+
+		capacity, ok := args[0].(*object.Integer)
+		if !ok || capacity.Value < 0 {
+			return newError("argument to `channel` must be a non-negative INTEGER, got %s", args[0].Inspect())
+		}
+
+		if err := charge(limiter, int(capacity.Value)); err != nil {
+			return err
+		}
+		return object.NewChannel(int(capacity.Value))
+	}),
+
+	"send": limited(func(limiter object.Limiter, args ...object.Object) object.Object {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2", len(args))
+		}
+
+		ch, ok := args[0].(*object.Channel)
+		if !ok {
+			return newError("first argument to `send` must be CHANNEL, got %s", args[0].Type())
+		}
+
+		if err := send(ch, args[1], limiter); err != nil {
+			return err
+		}
+
+		return NULL
+	}),
+
+	"receive": limited(func(limiter object.Limiter, args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		ch, ok := args[0].(*object.Channel)
+		if !ok {
+			return newError("argument to `receive` must be CHANNEL, got %s", args[0].Type())
+		}
+
+		if value, ok := receive(ch, limiter); ok {
+			return value
+		}
+
+		return NULL
+	}),
+
+	"close": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
 				return newError("wrong number of arguments. got=%d, want=1", len(args))
 			}
 
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `rest` must be ARRAY, got %s", args[0].Type())
+			if gen, ok := args[0].(*object.Generator); ok {
+				gen.Close()
+				return NULL
 			}
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
-			if length > 0 {
-				newElements := make([]object.Object, length-1)
-				copy(newElements, arr.Elements[1:length])
-				return &object.Array{Elements: newElements}
+			ch, ok := args[0].(*object.Channel)
+			if !ok {
+				return newError("argument to `close` must be CHANNEL or GENERATOR, got %s", args[0].Type())
+			}
+
+			if !ch.Close() {
+				return newError("close of closed channel")
 			}
 
 			return NULL
 		},
 	},
 
-	"push": {
+	"await": limited(func(limiter object.Limiter, args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		task, ok := args[0].(*object.Task)
+		if !ok {
+			return newError("argument to `await` must be TASK, got %s", args[0].Type())
+		}
+
+		select {
+		case <-task.Done():
+			return task.Await()
+		case <-done(limiter):
+			return limiter.Stopped()
+		}
+	}),
+}
+
+// limited returns a builtin that the evaluator calls with the limiter of
+// the call frame it is called from, so that it can stop waiting or looping
+// and charge what it allocates before it does.
+func limited(fn object.LimitedBuiltinFunction) *object.Builtin {
+	return &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
-			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=2", len(args))
-			}
+			return fn(nil, args...)
+		},
+		Limited: fn,
+	}
+}
 
-			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
-			}
+// ioBuiltin is a builtin that reads from or writes to the streams of the
+// environment it is called from.
+type ioBuiltin func(streams *object.IO, args ...object.Object) object.Object
 
-			arr := args[0].(*object.Array)
-			length := len(arr.Elements)
+var ioBuiltins = map[string]ioBuiltin{
+	"puts": func(streams *object.IO, args ...object.Object) object.Object {
+		streams.Lock()
+		defer streams.Unlock()
 
-			newElements := make([]object.Object, length+1)
-			copy(newElements, arr.Elements)
-			newElements[length] = args[1]
+		for _, arg := range args {
+			fmt.Fprintln(streams.Stdout, arg.Inspect())
+		}
 
-			return &object.Array{Elements: newElements}
-		},
+		return NULL
 	},
-	"puts": {
-		Fn: func(args ...object.Object) object.Object {
-			for _, arg := range args {
-				fmt.Println(arg.Inspect())
-			}
+	"eputs": func(streams *object.IO, args ...object.Object) object.Object {
+		streams.Lock()
+		defer streams.Unlock()
+
+		for _, arg := range args {
+			fmt.Fprintln(streams.Stderr, arg.Inspect())
+		}
 
+		return NULL
+	},
+	"readline": func(streams *object.IO, args ...object.Object) object.Object {
+		if len(args) != 0 {
+			return newError("wrong number of arguments. got=%d, want=0", len(args))
+		}
+
+		streams.Lock()
+		line, err := streams.Stdin.ReadString('\n')
+		streams.Unlock()
+		if err != nil && err != io.EOF {
+			return newError("could not read input: %s", err)
+		}
+		if err == io.EOF && line == "" {
 			return NULL
-		},
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		line = strings.TrimSuffix(line, "\r")
+		return &object.String{Value: line}
+	},
+	"readall": func(streams *object.IO, args ...object.Object) object.Object {
+		if len(args) != 0 {
+			return newError("wrong number of arguments. got=%d, want=0", len(args))
+		}
+
+		streams.Lock()
+		input, err := io.ReadAll(streams.Stdin)
+		streams.Unlock()
+		if err != nil {
+			return newError("could not read input: %s", err)
+		}
+
+		return &object.String{Value: string(input)}
 	},
 }
+
+// defaultIO serves environments that were not given streams.
+var defaultIO = object.NewIO(os.Stdin, os.Stdout, os.Stderr)
+
+func bindIOBuiltin(fn ioBuiltin, streams *object.IO) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			return fn(streams, args...)
+		},
+	}
+}
+
+func lookupIOBuiltin(name string, env *object.Environment) (*object.Builtin, bool) {
+	fn, ok := ioBuiltins[name]
+	if !ok {
+		return nil, false
+	}
+
+	streams := env.IO()
+	if streams == nil {
+		streams = defaultIO
+	}
+
+	return bindIOBuiltin(fn, streams), true
+}
+
+// collectIterator collects the elements of it into an array, charging
+// limiter for each before it is added.
+func collectIterator(it object.Iterator, limiter object.Limiter) object.Object {
+	elements := []object.Object{}
+
+	for {
+		value, ok := nextElement(it, limiter)
+		if !ok {
+			break
+		}
+		if isError(value) {
+			return value
+		}
+		if err := charge(limiter, 1); err != nil {
+			return err
+		}
+		elements = append(elements, value)
+	}
+
+	return &object.Array{Elements: elements}
+}
+
+// BuiltinNames returns the names of all builtin functions in sorted order,
+// which the compiler and the virtual machine use as builtin indices.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins)+len(ioBuiltins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	for name := range ioBuiltins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupBuiltin returns the builtin called name. Builtins that perform I/O
+// use the process's standard streams.
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	if builtin, ok := builtins[name]; ok {
+		return builtin, true
+	}
+	if fn, ok := ioBuiltins[name]; ok {
+		return bindIOBuiltin(fn, defaultIO), true
+	}
+	return nil, false
+}
+
+// builtinArities holds the least and the greatest number of arguments each
+// builtin accepts, so that calls can be checked without running them. A
+// greatest number of -1 means any number.
+var builtinArities = map[string][2]int{
+	"len":      {1, 1},
+	"first":    {1, 1},
+	"last":     {1, 1},
+	"rest":     {1, 1},
+	"push":     {2, 2},
+	"next":     {1, 1},
+	"collect":  {1, 1},
+	"range":    {1, 3},
+	"channel":  {0, 1},
+	"send":     {2, 2},
+	"receive":  {1, 1},
+	"close":    {1, 1},
+	"await":    {1, 1},
+	"puts":     {0, -1},
+	"eputs":    {0, -1},
+	"readline": {0, 0},
+	"readall":  {0, 0},
+}
+
+// BuiltinArity returns the least and the greatest number of arguments the
+// builtin called name accepts. The greatest number is -1 if there is no
+// limit.
+func BuiltinArity(name string) (least, greatest int, ok bool) {
+	arity, ok := builtinArities[name]
+	return arity[0], arity[1], ok
+}
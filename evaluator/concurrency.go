@@ -0,0 +1,116 @@
+package evaluator
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/object"
+	"reflect"
+)
+
+// evalSpawnExpression evaluates the function and arguments of a spawned
+// call in the current task and applies the function in a new one.
+func evalSpawnExpression(node *ast.SpawnExpression, env *object.Environment) object.Object {
+	var function object.Object
+	var args []object.Object
+	site := node.Token
+
+	if call, ok := node.Call.(*ast.CallExpression); ok {
+		var err object.Object
+		function, args, err = evalCall(call, env)
+		if err != nil {
+			return err
+		}
+		site = call.Token
+	} else {
+		function = Eval(node.Call, env)
+		if isError(function) {
+			return function
+		}
+	}
+
+	switch function.(type) {
+	case *object.Function, *object.Builtin:
+	default:
+		return newError("cannot spawn %s", function.Type())
+	}
+
+	// The task runs in a call frame whose limiter is the one of env, so it
+	// stays limited after the evaluation that spawned it has returned.
+	return object.NewTask(func() object.Object {
+		return applyFunction(function, args, env, site)
+	})
+}
+
+// evalSelectExpression waits until one of the channel operations of node
+// can proceed, or runs the default case if none can and there is one.
+func evalSelectExpression(node *ast.SelectExpression, env *object.Environment) object.Object {
+	cases := make([]reflect.SelectCase, len(node.Cases))
+
+	for i, c := range node.Cases {
+		if c.Channel == nil {
+			cases[i] = reflect.SelectCase{Dir: reflect.SelectDefault}
+			continue
+		}
+
+		evaluated := Eval(c.Channel, env)
+		if isError(evaluated) {
+			return evaluated
+		}
+		ch, ok := evaluated.(*object.Channel)
+		if !ok {
+			return newError("select on %s, want CHANNEL", evaluated.Type())
+		}
+
+		if c.Value == nil {
+			cases[i] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ch.C)}
+			continue
+		}
+
+		value := Eval(c.Value, env)
+		if isError(value) {
+			return value
+		}
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(ch.C), Send: reflect.ValueOf(&value).Elem()}
+	}
+
+	// The select also stops waiting once the evaluation is stopped.
+	limiter := env.Limiter()
+	if limiter != nil {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(limiter.Done())})
+	}
+
+	chosen, received, ok, sent := selectChannels(cases)
+	if !sent {
+		return newError("send on closed channel")
+	}
+	if chosen == len(node.Cases) {
+		return limiter.Stopped()
+	}
+	c := node.Cases[chosen]
+
+	if c.Binding == nil {
+		return Eval(c.Body, env)
+	}
+
+	var value object.Object = NULL
+	if ok {
+		value = received.Interface().(object.Object)
+	}
+
+	caseEnv := object.NewEnclosedEnvironment(env)
+	bind(caseEnv, c.Binding, value)
+
+	return Eval(c.Body, caseEnv)
+}
+
+// selectChannels runs reflect.Select, reporting a send on a closed channel
+// through sent instead of panicking.
+func selectChannels(cases []reflect.SelectCase) (chosen int, received reflect.Value, ok bool, sent bool) {
+	defer func() {
+		if recover() != nil {
+			sent = false
+		}
+	}()
+
+	chosen, received, ok = reflect.Select(cases)
+	return chosen, received, ok, true
+}
@@ -3,6 +3,7 @@ package evaluator
 import (
 	"WeekTwo/ast"
 	"WeekTwo/object"
+	"WeekTwo/token"
 	"fmt"
 )
 
@@ -47,12 +48,14 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range stmts {
+		trace(statement, env)
 		result = Eval(statement, env)
 
-		switch result := result.(type) {
-		case *object.ReturnValue:
-			return result.Value
-		case *object.Error:
+		if returnValue, ok := result.(*object.ReturnValue); ok {
+			return unwrapReturnValue(returnValue)
+		}
+		// Limit errors are errors too, though not *object.Error.
+		if isError(result) {
 			return result
 		}
 	}
@@ -94,6 +97,9 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
@@ -108,17 +114,26 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	}
 }
 
-func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+func evalStringInfixExpression(operator string, left, right object.Object, limiter object.Limiter) object.Object {
 	if operator != "+" {
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
+	if err := charge(limiter, len(leftVal)+len(rightVal)); err != nil {
+		return err
+	}
 	return &object.String{Value: leftVal + rightVal}
 }
 
-func evalPrefixExpression(operator string, right object.Object) object.Object {
+func evalPrefixExpression(operator string, right object.Object, env *object.Environment, site token.Token) object.Object {
+	if operator == "-" {
+		if fn, ok := lookupOperatorMethod(right, "__neg__"); ok {
+			return applyFunction(fn, []object.Object{right}, env, site)
+		}
+	}
+
 	switch operator {
 	case "!":
 		return evalBangOperatorExpression(right)
@@ -129,12 +144,95 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	}
 }
 
-func evalInfixExpression(operator string, left, right object.Object) object.Object {
+// operatorMethods names the hash entries consulted when an operator is
+// applied to a hash, e.g. {"__add__": fn(self, other) { ... }}. A hash can
+// also overload -x with {"__neg__": fn(self) { ... }}.
+var operatorMethods = map[string]string{
+	"+":  "__add__",
+	"-":  "__sub__",
+	"*":  "__mul__",
+	"/":  "__div__",
+	"<":  "__lt__",
+	">":  "__gt__",
+	"==": "__eq__",
+	"!=": "__ne__",
+}
+
+// reflectedMethods names the entries consulted on the right operand when
+// the left one does not overload the operator, so that 3 * v works as well
+// as v * 3. They are called with the right operand first: a < b becomes
+// b.__gt__(a), and a + b becomes b.__radd__(a).
+var reflectedMethods = map[string]string{
+	"+":  "__radd__",
+	"-":  "__rsub__",
+	"*":  "__rmul__",
+	"/":  "__rdiv__",
+	"<":  "__gt__",
+	">":  "__lt__",
+	"==": "__eq__",
+	"!=": "__ne__",
+}
+
+func lookupOperatorMethod(obj object.Object, name string) (object.Object, bool) {
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		return nil, false
+	}
+
+	pair, ok := hash.Pairs[(&object.String{Value: name}).HashKey()]
+	if !ok {
+		return nil, false
+	}
+
+	switch pair.Value.(type) {
+	case *object.Function, *object.Builtin:
+		return pair.Value, true
+	default:
+		return nil, false
+	}
+}
+
+func evalOverloadedInfixExpression(operator string, left, right object.Object, env *object.Environment, site token.Token) (object.Object, bool) {
+	if fn, ok := lookupOperatorMethod(left, operatorMethods[operator]); ok {
+		return applyFunction(fn, []object.Object{left, right}, env, site), true
+	}
+	if fn, ok := lookupOperatorMethod(right, reflectedMethods[operator]); ok {
+		return applyFunction(fn, []object.Object{right, left}, env, site), true
+	}
+
+	if operator == "!=" {
+		fn, ok := lookupOperatorMethod(left, operatorMethods["=="])
+		args := []object.Object{left, right}
+		if !ok {
+			fn, ok = lookupOperatorMethod(right, operatorMethods["=="])
+			args = []object.Object{right, left}
+		}
+		if ok {
+			equal := applyFunction(fn, args, env, site)
+			if isError(equal) {
+				return equal, true
+			}
+			return nativeBoolToBooleanObject(!isTruthy(equal)), true
+		}
+	}
+
+	return nil, false
+}
+
+func evalInfixExpression(operator string, left, right object.Object, env *object.Environment, site token.Token) object.Object {
+	if left.Type() == object.HASH_OBJ || right.Type() == object.HASH_OBJ {
+		if result, ok := evalOverloadedInfixExpression(operator, left, right, env, site); ok {
+			return result
+		}
+	}
+
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
-		return evalStringInfixExpression(operator, left, right)
+		return evalStringInfixExpression(operator, left, right, env.Limiter())
+	case left.Type() == object.ENUM_VALUE_OBJ && right.Type() == object.ENUM_VALUE_OBJ:
+		return evalEnumValueInfixExpression(operator, left, right)
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
@@ -146,6 +244,38 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	}
 }
 
+func evalEnumValueInfixExpression(operator string, left, right object.Object) object.Object {
+	switch operator {
+	case "==":
+		return nativeBoolToBooleanObject(enumValuesEqual(left.(*object.EnumValue), right.(*object.EnumValue)))
+	case "!=":
+		return nativeBoolToBooleanObject(!enumValuesEqual(left.(*object.EnumValue), right.(*object.EnumValue)))
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func enumValuesEqual(left, right *object.EnumValue) bool {
+	if left.Enum != right.Enum || left.Variant != right.Variant || len(left.Fields) != len(right.Fields) {
+		return false
+	}
+
+	for i, l := range left.Fields {
+		r := right.Fields[i]
+		lh, lok := l.(object.Hashable)
+		rh, rok := r.(object.Hashable)
+		if lok && rok {
+			if lh.HashKey() != rh.HashKey() {
+				return false
+			}
+		} else if l != r {
+			return false
+		}
+	}
+
+	return true
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) {
@@ -164,6 +294,7 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	var result object.Object
 
 	for _, statement := range block.Statements {
+		trace(statement, env)
 		result = Eval(statement, env)
 
 		if result != nil {
@@ -177,7 +308,25 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
+// bind binds the name declared by ident in env, in the slot the resolver
+// gave it if it was resolved.
+func bind(env *object.Environment, ident *ast.Identifier, val object.Object) {
+	if ident.Resolved {
+		env.SetSlot(ident.Slot, ident.Value, val)
+		return
+	}
+	env.Set(ident.Value, val)
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	// A binding declared conditionally may not exist yet, in which case the
+	// full lookup below finds the one from an outer scope.
+	if node.Resolved {
+		if val, ok := env.GetSlot(node.Depth, node.Slot, node.Value); ok {
+			return val
+		}
+	}
+
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
@@ -186,6 +335,10 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return builtin
 	}
 
+	if builtin, ok := lookupIOBuiltin(node.Value, env); ok {
+		return builtin
+	}
+
 	return newError("identifier not found: " + node.Value)
 }
 
@@ -203,12 +356,12 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func evalIndexExpression(left, index object.Object) object.Object {
+func evalIndexExpression(left, index object.Object, env *object.Environment, site token.Token) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
-		return evalHashIndexExpression(left, index)
+		return evalHashIndexExpression(left, index, env, site)
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
@@ -226,20 +379,25 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
-func evalHashIndexExpression(hash, index object.Object) object.Object {
+func evalHashIndexExpression(hash, index object.Object, env *object.Environment, site token.Token) object.Object {
 	hashObject := hash.(*object.Hash)
 
-	key, ok := index.(object.Hashable)
-	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+	key, hashable := index.(object.Hashable)
+	if hashable {
+		if pair, ok := hashObject.Pairs[key.HashKey()]; ok {
+			return pair.Value
+		}
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
-	if !ok {
-		return NULL
+	if fn, ok := lookupOperatorMethod(hash, "__index__"); ok {
+		return applyFunction(fn, []object.Object{hash, index}, env, site)
 	}
 
-	return pair.Value
+	if !hashable {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+
+	return NULL
 }
 
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
@@ -268,37 +426,278 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 	return &object.Hash{Pairs: pairs}
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
-	switch fn := fn.(type) {
-	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
-	case *object.Builtin:
-		return fn.Fn(args...)
-	default:
-		return newError("not a function: %s", fn.Type())
+func evalEnumStatement(node *ast.EnumStatement, env *object.Environment) {
+	enum := &object.Enum{Name: node.Name.Value, Variants: node.Variants}
+	bind(env, node.Name, enum)
+
+	for _, variant := range node.Variants {
+		bind(env, variant.Name, enumConstructor(enum, variant))
+	}
+}
+
+func enumConstructor(enum *object.Enum, variant *ast.EnumVariant) object.Object {
+	name := variant.Name.Value
+	arity := len(variant.Fields)
+
+	if arity == 0 {
+		return &object.EnumValue{Enum: enum, Variant: name}
+	}
+
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != arity {
+				return newError("wrong number of arguments to `%s`. got=%d, want=%d", name, len(args), arity)
+			}
+
+			fields := make([]object.Object, arity)
+			copy(fields, args)
+			return &object.EnumValue{Enum: enum, Variant: name, Fields: fields}
+		},
+	}
+}
+
+func evalMatchExpression(node *ast.MatchExpression, env *object.Environment) object.Object {
+	subject := Eval(node.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	value, isEnumValue := subject.(*object.EnumValue)
+
+	for _, arm := range node.Arms {
+		if arm.Variant.Value == "_" {
+			return Eval(arm.Body, env)
+		}
+
+		// The arm names a variant of the subject's own enum, whatever the
+		// name is bound to where the match is.
+		if !isEnumValue || value.Variant != arm.Variant.Value {
+			continue
+		}
+
+		if len(arm.Bindings) != len(value.Fields) {
+			return newError("wrong number of bindings for %s. got=%d, want=%d",
+				value.Variant, len(arm.Bindings), len(value.Fields))
+		}
+
+		armEnv := object.NewEnclosedEnvironment(env)
+		for i, binding := range arm.Bindings {
+			if binding.Value != "_" {
+				bind(armEnv, binding, value.Fields[i])
+			}
+		}
+
+		return Eval(arm.Body, armEnv)
 	}
+
+	return newError("no match arm for %s", subject.Inspect())
+}
+
+// MaxCallDepth bounds the number of nested, non-tail function calls so that
+// runaway recursion is reported as an error instead of overflowing the Go
+// stack.
+var MaxCallDepth = 10000
+
+func applyFunction(fn object.Object, args []object.Object, caller *object.Environment, site token.Token) object.Object {
+	depth := callDepth(caller) + 1
+	// The functions whose result type strict mode checks once the calls in
+	// tail position they made have returned.
+	var returns []*object.Function
+
+	for {
+		switch f := fn.(type) {
+		case *object.Function:
+			if f.Generator {
+				return newGenerator(f, args, caller)
+			}
+			if depth > MaxCallDepth {
+				return withFrame(newError("maximum recursion depth exceeded"), f, args, caller, site)
+			}
+			if limiter := limiterOf(caller); limiter != nil {
+				if err := limiter.EnterCall(depth); err != nil {
+					return err
+				}
+			}
+			if err := checkArguments(f, args); err != nil {
+				return withFrame(err, f, args, caller, site)
+			}
+
+			extendedEnv := extendFunctionEnv(f, args, depth, limiterOf(caller))
+
+			tracer := extendedEnv.Tracer()
+			if tracer != nil {
+				tracer.EnterCall(f, extendedEnv)
+			}
+			evaluated := evalFunctionBody(f.Body, extendedEnv)
+			if tracer != nil {
+				tracer.LeaveCall()
+			}
+			if returnValue, ok := evaluated.(*object.ReturnValue); ok {
+				evaluated = returnValue.Value
+			}
+
+			if f.ReturnType != nil && f.Env.Strict() {
+				returns = appendReturn(returns, f)
+			}
+
+			// A call in tail position replaces the current call instead of
+			// nesting inside it.
+			if call, ok := evaluated.(*tailCall); ok {
+				fn, args, caller, site = call.fn, call.args, call.caller, call.site
+				continue
+			}
+
+			return withFrame(checkReturns(returns, evaluated), f, args, caller, site)
+		case *object.Builtin:
+			if caller == nil {
+				return f.Fn(args...)
+			}
+			if f.Limited != nil {
+				return f.Limited(limiterOf(caller), args...)
+			}
+			return allocate(f.Fn(args...), caller)
+		default:
+			return newError("not a function: %s", fn.Type())
+		}
+	}
+}
+
+// withFrame records the call of fn on the stack of obj if obj is an error.
+func withFrame(obj object.Object, fn *object.Function, args []object.Object, caller *object.Environment, site token.Token) object.Object {
+	err, ok := obj.(*object.Error)
+	if !ok {
+		return obj
+	}
+
+	frame := object.StackFrame{
+		Function: fn.Name,
+		Line:     site.Line,
+		Column:   site.Column,
+		Args:     len(args),
+	}
+	if caller != nil {
+		frame.File = caller.File()
+	}
+	err.Stack = append(err.Stack, frame)
+
+	return err
+}
+
+// trace reports stmt to the tracer of env, if there is one, before it is
+// evaluated.
+func trace(stmt ast.Statement, env *object.Environment) {
+	if tracer := env.Tracer(); tracer != nil {
+		tracer.Statement(stmt, env)
+	}
+}
+
+func callDepth(env *object.Environment) int {
+	if env == nil {
+		return 0
+	}
+	return env.CallDepth()
+}
+
+func newGenerator(fn *object.Function, args []object.Object, caller *object.Environment) *object.Generator {
+	env := extendFunctionEnv(fn, args, fn.Env.CallDepth(), limiterOf(caller))
+
+	return object.NewGenerator(func(yield func(object.Object) bool) object.Object {
+		env.SetYield(yield)
+		return unwrapReturnValue(Eval(fn.Body, env))
+	})
+}
+
+func evalYieldExpression(node *ast.YieldExpression, env *object.Environment) object.Object {
+	yield := env.Yield()
+	if yield == nil {
+		return newError("yield outside of generator")
+	}
+
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	if !yield(val) {
+		return newError("generator closed")
+	}
+	return NULL
+}
+
+// iteratorOf returns an iterator over obj, if it is iterable. Receiving
+// from a channel stops with the evaluation of limiter.
+func iteratorOf(obj object.Object, limiter object.Limiter) (object.Iterator, bool) {
+	if ch, ok := obj.(*object.Channel); ok {
+		return channelIterator{ch, limiter}, true
+	}
+	iterable, ok := obj.(object.Iterable)
+	if !ok {
+		return nil, false
+	}
+	return iterable.Iterator(), true
+}
+
+func evalForExpression(node *ast.ForExpression, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	it, ok := iteratorOf(iterable, env.Limiter())
+	if !ok {
+		return newError("not iterable: %s", iterable.Type())
+	}
+
+	for {
+		value, ok := it.Next()
+		if !ok {
+			break
+		}
+		if isError(value) {
+			return value
+		}
+
+		loopEnv := object.NewEnclosedEnvironment(env)
+		bind(loopEnv, node.Variable, value)
+
+		result := Eval(node.Body, loopEnv)
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
+
+	return NULL
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
 	if returnValue, ok := obj.(*object.ReturnValue); ok {
-		return returnValue.Value
+		obj = returnValue.Value
+	}
+	if call, ok := obj.(*tailCall); ok {
+		return applyFunction(call.fn, call.args, call.caller, call.site)
 	}
 	return obj
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+func extendFunctionEnv(fn *object.Function, args []object.Object, depth int, limiter object.Limiter) *object.Environment {
+	env := object.NewCallEnvironment(fn.Env, depth, limiter)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		bind(env, param, args[paramIdx])
 	}
 
 	return env
 }
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	if limiter := env.Limiter(); limiter != nil {
+		if err := limiter.Step(); err != nil {
+			return err
+		}
+	}
 
 	switch node := node.(type) {
 	case *ast.Program:
@@ -306,17 +705,27 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{
+			Parameters:     params,
+			Body:           body,
+			Env:            env,
+			Generator:      node.IsGenerator,
+			Name:           node.Name,
+			ParameterTypes: node.ParameterTypes,
+			ReturnType:     node.ReturnType,
+		}
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
+		if node.Function.TokenLiteral() == "quote" {
+			if len(node.Arguments) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(node.Arguments))
+			}
+			return quote(node.Arguments[0], env)
 		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+		function, args, err := evalCall(node, env)
+		if err != nil {
+			return err
 		}
-		return applyFunction(function, args)
+		return applyFunction(function, args, env, node.Token)
 	case *ast.ExpressionStatement:
 		return Eval(node.Expression, env)
 	case *ast.LetStatement:
@@ -324,8 +733,38 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if node.Type != nil && env.Strict() && !matchesType(node.Type, val) {
+			return newError("type error: cannot assign %s to %s of type %s", val.Type(), node.Name.Value, node.Type)
+		}
+		bind(env, node.Name, val)
+	case *ast.EnumStatement:
+		evalEnumStatement(node, env)
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+	case *ast.ExportStatement:
+		return Eval(node.Statement, env)
+	case *ast.MemberExpression:
+		return evalMemberExpression(node, env)
+	case *ast.MethodCallExpression:
+		return evalMethodCallExpression(node, env)
+	case *ast.YieldExpression:
+		return evalYieldExpression(node, env)
+	case *ast.ForExpression:
+		return evalForExpression(node, env)
+	case *ast.SpawnExpression:
+		return evalSpawnExpression(node, env)
+	case *ast.SelectExpression:
+		return evalSelectExpression(node, env)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
 	case *ast.ReturnStatement:
+		if call, ok := node.ReturnValue.(*ast.CallExpression); ok && isTailCallable(call) {
+			val := evalTailCall(call, env)
+			if isError(val) {
+				return val
+			}
+			return &object.ReturnValue{Value: val}
+		}
 		val := Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
@@ -336,7 +775,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return evalPrefixExpression(node.Operator, right, env, node.Token)
 	case *ast.InfixExpression:
 		left := Eval(node.Left, env)
 		if isError(left) {
@@ -346,7 +785,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return evalInfixExpression(node.Operator, left, right, env, node.Token)
 	case *ast.BlockStatement:
 		return evalBlockStatement(node, env)
 	case *ast.IfExpression:
@@ -360,12 +799,18 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(index) {
 			return index
 		}
-		return evalIndexExpression(left, index)
+		return evalIndexExpression(left, index, env, node.Token)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.HashLiteral:
+		if err := charge(env.Limiter(), len(node.Pairs)); err != nil {
+			return err
+		}
 		return evalHashLiteral(node, env)
 	case *ast.ArrayLiteral:
+		if err := charge(env.Limiter(), len(node.Elements)); err != nil {
+			return err
+		}
 		elements := evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
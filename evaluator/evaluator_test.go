@@ -4,16 +4,31 @@ import (
 	"WeekTwo/lexer"
 	"WeekTwo/object"
 	"WeekTwo/parser"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
+// Evaluated, when set, is given every input testEval evaluates along with
+// its result. The parity test of the virtual machine replays them.
+var Evaluated func(input string, evaluated object.Object)
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
 	env := object.NewEnvironment()
 
-	return Eval(program, env)
+	evaluated := Eval(program, env)
+	if Evaluated != nil {
+		Evaluated(input, evaluated)
+	}
+	return evaluated
 }
 
 func testNullObject(t *testing.T, obj object.Object) bool {
@@ -167,6 +182,7 @@ func TestErrorHandling(t *testing.T) {
 		input    string
 		expected string
 	}{
+		{"10 / (5 - 5)", "division by zero"},
 		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
 		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
 		{"-true", "unknown operator: -BOOLEAN"},
@@ -309,6 +325,19 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestBuiltinArities(t *testing.T) {
+	for _, name := range BuiltinNames() {
+		if _, _, ok := BuiltinArity(name); !ok {
+			t.Errorf("builtin %s has no arity", name)
+		}
+	}
+
+	least, greatest, _ := BuiltinArity("range")
+	if least != 1 || greatest != 3 {
+		t.Errorf("wrong arity for range. got=%d..%d", least, greatest)
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 	evaluated := testEval(input)
@@ -417,3 +446,873 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestEnumMatching(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`enum Shape { Circle(r), Rect(w, h) };
+		let area = fn(s) { match (s) { Circle(r) => 3 * r * r, Rect(w, h) => w * h } };
+		area(Circle(2)) + area(Rect(3, 4))`, 24},
+		{`enum Light { Red, Green };
+		match (Green) { Red => 1, Green => 2 }`, 2},
+		{`enum Light { Red, Green };
+		match (Green) { Red => 1, _ => 3 }`, 3},
+		{`enum Pair { P(a, b) }; match (P(1, 2)) { P(_, b) => b }`, 2},
+		{`enum Shape { Circle(r) }; Circle(1) == Circle(1)`, true},
+		{`enum Shape { Circle(r) }; Circle(1) != Circle(2)`, true},
+		{`enum Light { Red, Green }; Red == Green`, false},
+		{`enum Shape { Circle(r) }; Circle(1, 2)`, "wrong number of arguments to `Circle`. got=2, want=1"},
+		{`enum Light { Red, Green }; match (Green) { Red => 1 }`, "no match arm for Green"},
+		{`enum A { X }; let a = X; enum B { X }; match (a) { X => 1 }`, 1},
+		{`enum Light { Red, Green }; let g = Green; let Green = 0; match (g) { Red => 1, Green => 2 }`, 2},
+		{`enum Light { Red, Green }; let f = fn(Red) { match (Red) { Red => 1, Green => 2 } }; f(Red)`, 1},
+		{`enum A { X(v) }; let a = X(1); enum B { X(v) }; match (X(2)) { X(v) => v }`, 2},
+		{`enum Shape { Circle(r) }; match (Circle(1)) { Circle(a, b) => 1 }`, "wrong number of bindings for Circle. got=2, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			if evaluated != nativeBoolToBooleanObject(expected) {
+				t.Errorf("object has wrong value. got=%+v, want=%t", evaluated, expected)
+			}
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestEnumValuesAsHashKeys(t *testing.T) {
+	input := `enum Shape { Circle(r), Rect(w, h) };
+	let names = {Circle(1): "small", Rect(2, 3): "rect"};
+	names[Circle(1)] + names[Rect(2, 3)]`
+
+	evaluated := testEval(input)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "smallrect" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+
+	testNullObject(t, testEval(`enum Shape { Circle(r) }; {Circle(1): 1}[Circle(2)]`))
+	// Keys are equal when the values are equal under ==.
+	testNullObject(t, testEval(`enum A { X }; let a = X; enum A { X }; {a: 1}[X]`))
+	testNullObject(t, testEval(`enum Box { B(v) }; {B([1]): 1}[B([1])]`))
+	testIntegerObject(t, testEval(`enum Box { B(v) }; let xs = [1]; {B(xs): 1}[B(xs)]`), 1)
+}
+
+func writeModules(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, source := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func testEvalFile(dir string, resolver *ModuleResolver, input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+	env.SetFile(filepath.Join(dir, "main.mk"))
+	env.SetImporter(resolver)
+
+	return Eval(program, env)
+}
+
+func TestModules(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"lib/math.mk":   `export let double = fn(x) { x * 2 }; let hidden = 1; export let ten = double(5);`,
+		"lib/uses.mk":   `import "math" as m; export let twenty = m.double(m.ten);`,
+		"vendor/ext.mk": `export let answer = 42;`,
+		"cycle/a.mk":    `import "b"; export let a = 1;`,
+		"cycle/b.mk":    `import "a"; export let b = 1;`,
+		"broken/bad.mk": `let = 1;`,
+	})
+	resolver := NewModuleResolver(filepath.Join(dir, "vendor"))
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import "lib/math" as m; m.double(m.ten)`, 20},
+		{`import "lib/math"; math.ten`, 10},
+		{`import "lib/uses" as u; u.twenty`, 20},
+		{`import "ext" as e; e.answer`, 42},
+		{`import "lib/math" as m; m.hidden`, "hidden is not exported by module lib/math"},
+		{`import "missing" as m; 1`, "module not found: missing"},
+		{`let x = 5; x.y`, "member access not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalFile(dir, resolver, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+
+	first := testEvalFile(dir, resolver, `import "lib/math" as m; m`)
+	second := testEvalFile(dir, resolver, `import "lib/math.mk" as m; m`)
+	if first != second {
+		t.Errorf("module was not cached. got=%p and %p", first, second)
+	}
+
+	evaluated := testEvalFile(dir, resolver, `import "cycle/a" as a; a`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok || !strings.HasPrefix(errObj.Message, "import cycle: ") {
+		t.Errorf("expected import cycle error. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	evaluated = testEvalFile(dir, resolver, `import "broken/bad" as b; b`)
+	errObj, ok = evaluated.(*object.Error)
+	if !ok || !strings.HasPrefix(errObj.Message, "parse errors in module broken/bad: ") {
+		t.Errorf("expected parse error. got=%T (%+v)", evaluated, evaluated)
+	}
+}
+
+func TestConcurrentImports(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"slow.mk": `receive(gate); export let x = 1;`,
+	})
+	gate := object.NewChannel(0)
+	resolver := NewModuleResolver()
+	resolver.Globals = object.NewEnvironment()
+	resolver.Globals.Set("gate", gate)
+
+	results := make(chan object.Object)
+	for i := 0; i < 2; i++ {
+		go func() {
+			env := object.NewEnvironment()
+			env.SetFile(filepath.Join(dir, "main.mk"))
+			results <- resolver.Import("slow", env)
+		}()
+	}
+	// Let both imports start before the module finishes loading.
+	time.Sleep(50 * time.Millisecond)
+	gate.Send(TRUE)
+
+	var modules []object.Object
+	for i := 0; i < 2; i++ {
+		select {
+		case module := <-results:
+			if _, ok := module.(*object.Module); !ok {
+				t.Fatalf("expected *object.Module. got=%T (%+v)", module, module)
+			}
+			modules = append(modules, module)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the module was loaded more than once")
+		}
+	}
+	if modules[0] != modules[1] {
+		t.Errorf("the imports got different modules. got=%p and %p", modules[0], modules[1])
+	}
+}
+
+func TestConcurrentImportCycle(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"a.mk": `receive(gate); import "b" as b; export let x = 1;`,
+		"b.mk": `receive(gate); import "a" as a; export let y = 2;`,
+	})
+	gate := object.NewChannel(0)
+	resolver := NewModuleResolver()
+	resolver.Globals = object.NewEnvironment()
+	resolver.Globals.Set("gate", gate)
+
+	results := make(chan object.Object)
+	for _, path := range []string{"a", "b"} {
+		go func(path string) {
+			env := object.NewEnvironment()
+			env.SetFile(filepath.Join(dir, "main.mk"))
+			results <- resolver.Import(path, env)
+		}(path)
+	}
+	// Let each task start loading its module before either imports the
+	// other's.
+	time.Sleep(50 * time.Millisecond)
+	gate.Send(TRUE)
+	gate.Send(TRUE)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case result := <-results:
+			errObj, ok := result.(*object.Error)
+			if !ok || !strings.HasPrefix(errObj.Message, "import cycle: ") {
+				t.Errorf("expected import cycle error. got=%T (%+v)", result, result)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("the imports deadlocked")
+		}
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let gen = fn() { yield 1; yield 2; yield 3; }; let g = gen(); next(g); next(g)`, 2},
+		{`let gen = fn() { yield 1; }; let g = gen(); next(g); next(g)`, nil},
+		{`let count = fn(n) { for (i in range(n)) { yield i * i } }; last(count(4))`, 9},
+		{`let count = fn(n) { for (i in range(n)) { yield i } }; len(count(100))`, 100},
+		{`let naturals = fn() { let loop = fn(n) { yield n; loop(n + 1) }; loop(1) }; first(naturals())`, 1},
+		{`let gen = fn() { yield 1; yield 1 + true; }; len(gen())`, "type mismatch: INTEGER + BOOLEAN"},
+		{`next([1])`, "argument to `next` must be GENERATOR, got ARRAY"},
+		{`let gen = fn() { yield 1; yield 2; }; let g = gen(); next(g); close(g); next(g)`, nil},
+		{`let gen = fn() { yield 1; }; let g = gen(); close(g); close(g); next(g)`, nil},
+		{`let gen = fn() { yield 1; }; let g = gen(); g.close(); len(g)`, 0},
+		{`close(1)`, "argument to `close` must be CHANNEL or GENERATOR, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestAbandonedGenerators(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 20; i++ {
+		evaluated := testEval(`let naturals = fn() { let loop = fn(n) { yield n; loop(n + 1) }; loop(1) }; first(naturals())`)
+		testIntegerObject(t, evaluated, 1)
+	}
+
+	for i := 0; i < 50 && runtime.NumGoroutine() > before; i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("abandoned generators left goroutines running. before=%d, after=%d", before, after)
+	}
+}
+
+func TestGeneratorConcurrentNext(t *testing.T) {
+	gen := testEval(`let count = fn(n) { for (i in range(n)) { yield i } }; count(100)`).(*object.Generator)
+
+	var mu sync.Mutex
+	seen := make(map[int64]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for value, ok := gen.Next(); ok; value, ok = gen.Next() {
+				mu.Lock()
+				seen[value.(*object.Integer).Value] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != 100 {
+		t.Errorf("wrong number of values. expected=100, got=%d", len(seen))
+	}
+}
+
+func TestIteratorProtocol(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`collect(range(3))`, "[0, 1, 2]"},
+		{`collect(range(1, 10, 4))`, "[1, 5, 9]"},
+		{`collect(range(3, 0, -1))`, "[3, 2, 1]"},
+		{`collect("abc")`, "[a, b, c]"},
+		{`collect({"a": 1})`, "[a]"},
+		{`let gen = fn() { yield 1; yield 2 }; collect(gen())`, "[1, 2]"},
+		{`let gen = fn() { yield 1; yield 2; yield 3 }; rest(gen())`, "[2, 3]"},
+		{`first("xyz")`, "x"},
+		{`last(range(5))`, "4"},
+		{`len(range(5))`, "5"},
+		{`len({"a": 1, "b": 2})`, "2"},
+		{`let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x * 10 } } }; f()`, "20"},
+		{`for (x in 5) { x }`, "ERROR: not iterable: INTEGER"},
+		{`range(1, 2, 0)`, "ERROR: `range` step must not be zero"},
+		{`first(1)`, "ERROR: argument to `first` must be iterable, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("no result for %q", tt.input)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMethodCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, 2].push(3).len()`, "3"},
+		{`[1, 2, 3].rest().first()`, "2"},
+		{`"hello".upper()`, "HELLO"},
+		{`"a,b,c".split(",").last()`, "c"},
+		{`{"a": 1}.keys()`, "[a]"},
+		{`range(4).collect()`, "[0, 1, 2, 3]"},
+		{`let double = fn(x) { x * 2 }; 21.double()`, "42"},
+		{`let add = fn(x, y) { x + y }; 1.add(2).add(3)`, "6"},
+		{`let len = fn(x) { 0 }; [1, 2].len()`, "2"},
+		{`5.nope()`, "ERROR: undefined method nope for INTEGER"},
+		{`"x".upper(1)`, "ERROR: wrong number of arguments. got=1, want=0"},
+		{`[1].len(2)`, "ERROR: wrong number of arguments. got=1, want=0"},
+		{`[1].push()`, "ERROR: wrong number of arguments. got=0, want=1"},
+		{`channel().send()`, "ERROR: wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("no result for %q", tt.input)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestOperatorOverloading(t *testing.T) {
+	vector := `
+	let vec = fn(x, y) {
+		{
+			"x": x,
+			"y": y,
+			"__add__": fn(self, other) { vec(self["x"] + other["x"], self["y"] + other["y"]) },
+			"__mul__": fn(self, k) { vec(self["x"] * k, self["y"] * k) },
+			"__eq__": fn(self, other) { self["x"] == other["x"] },
+			"__lt__": fn(self, other) { self["x"] < other["x"] },
+			"__index__": fn(self, i) { if (i == 0) { self["x"] } else { self["y"] } },
+			"__rmul__": fn(self, k) { self * k },
+			"__neg__": fn(self) { self * -1 }
+		}
+	};
+	`
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{vector + `let v = vec(1, 2) + vec(3, 4); v["x"] * 10 + v["y"]`, "46"},
+		{vector + `(vec(1, 2) * 3)[1]`, "6"},
+		{vector + `vec(1, 2)[0]`, "1"},
+		{vector + `vec(1, 2) == vec(1, 5)`, "true"},
+		{vector + `vec(1, 2) != vec(1, 5)`, "false"},
+		{vector + `vec(1, 2) < vec(3, 0)`, "true"},
+		{vector + `vec(1, 2)[[1]]`, "2"},
+		{vector + `vec(1, 2) - vec(1, 2)`, "ERROR: unknown operator: HASH - HASH"},
+		{vector + `(3 * vec(1, 2))[1]`, "6"},
+		{vector + `(-vec(1, 2))[0]`, "-1"},
+		{vector + `vec(1, 2) > vec(3, 0)`, "false"},
+		{vector + `{"x": 1} == vec(1, 5)`, "true"},
+		{vector + `{"x": 1} != vec(1, 5)`, "false"},
+		{vector + `0 < vec(1, 2)`, "ERROR: type mismatch: INTEGER < HASH"},
+		{vector + `3 - vec(1, 2)`, "ERROR: type mismatch: INTEGER - HASH"},
+		{`-{}`, "ERROR: unknown operator: -HASH"},
+		{`let h = {}; h == h`, "true"},
+		{`{}[[1]]`, "ERROR: unusable as hash key: ARRAY"},
+		{`{"__add__": 1} + 1`, "ERROR: type mismatch: HASH + INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("no result for %q", tt.input)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar)`, `foobar`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(4 + 4) + 8)`, `(8 + 8)`},
+		{`let foobar = 8; quote(foobar)`, `foobar`},
+		{`let foobar = 8; quote(unquote(foobar))`, `8`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(true == false))`, `false`},
+		{`quote(unquote(quote(4 + 4)))`, `(4 + 4)`},
+		{`let quotedInfixExpression = quote(4 + 4);
+		quote(unquote(4 + 4) + unquote(quotedInfixExpression))`, `(8 + (4 + 4))`},
+		{`quote(unquote([1, 2]))`, `[1, 2]`},
+		{`quote(unquote([[1], quote(2 + 3)]))`, `[[1], (2 + 3)]`},
+		{`quote(unquote({"a": [true]}))`, `{a: [true]}`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			t.Fatalf("expected *object.Quote. got=%T (%+v)", evaluated, evaluated)
+		}
+
+		if quote.Node == nil {
+			t.Fatalf("quote.Node is nil")
+		}
+
+		if quote.Node.String() != tt.expected {
+			t.Errorf("not equal. got=%q, want=%q", quote.Node.String(), tt.expected)
+		}
+	}
+}
+
+func TestUnquoteErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote(1 + true))`, "type mismatch: INTEGER + BOOLEAN"},
+		{`quote(unquote(fn(x) { x }))`, "cannot unquote FUNCTION"},
+		{`quote(unquote([1, fn() { 2 }]))`, "cannot unquote FUNCTION"},
+		{`quote(1 + unquote(if (false) { 1 }))`, "cannot unquote NULL"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("expected *object.Error for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message for %q. expected=%q, got=%q", tt.input, tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`let loop = fn(n, acc) { if (n == 0) { acc } else { loop(n - 1, acc + 1) } }; loop(300000, 0)`, 300000},
+		{`let loop = fn(n, acc) { if (n == 0) { return acc; } return loop(n - 1, acc + n); }; loop(100000, 0)`, 5000050000},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+		let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+		if (even(200000)) { 1 } else { 0 }`, 1},
+		{`let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; fact(10)`, 3628800},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestMaxRecursionDepth(t *testing.T) {
+	input := `let f = fn(n) { 1 + f(n + 1) }; f(0)`
+
+	errObj, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+
+	if errObj.Message != "maximum recursion depth exceeded" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestDeepRecursion(t *testing.T) {
+	input := `let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(5000)`
+	testIntegerObject(t, testEval(input), 5000)
+}
+
+func testEvalContext(ctx context.Context, input string, options Options) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	return EvalContext(ctx, program, env, options)
+}
+
+func TestExecutionLimits(t *testing.T) {
+	tests := []struct {
+		input   string
+		options Options
+		limit   string
+	}{
+		{`let f = fn() { f() }; f()`, Options{MaxSteps: 10000}, StepLimit},
+		{`for (x in range(1000000000)) { x }`, Options{MaxSteps: 10000}, StepLimit},
+		{`let f = fn(n) { 1 + f(n + 1) }; f(0)`, Options{MaxCallDepth: 100}, CallDepthLimit},
+		{`let f = fn(s) { f(s + s) }; f("ab")`, Options{MaxAllocations: 1 << 20}, AllocationLimit},
+		{`let f = fn(a) { f(push(a, 1)) }; f([])`, Options{MaxAllocations: 10000}, AllocationLimit},
+		// The limit is hit before the last statement, which must not run.
+		{`let f = fn(n) { 1 + f(n + 1) }; f(0); 5`, Options{MaxCallDepth: 50}, CallDepthLimit},
+		{`let a = collect(range(5000)); 5`, Options{MaxAllocations: 1000}, AllocationLimit},
+		{`let f = fn() { f() }; f(); 5`, Options{MaxSteps: 1000}, StepLimit},
+		{`len(range(1000000000))`, Options{MaxSteps: 10000}, StepLimit},
+		{`last(range(1000000000))`, Options{MaxSteps: 10000}, StepLimit},
+		{`collect(range(1000000000))`, Options{MaxAllocations: 1000}, AllocationLimit},
+		{`range(1000000000).collect()`, Options{MaxAllocations: 1000}, AllocationLimit},
+		{`channel(1000000000)`, Options{MaxAllocations: 1000}, AllocationLimit},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalContext(context.Background(), tt.input, tt.options)
+		limitErr, ok := evaluated.(*object.LimitError)
+		if !ok {
+			t.Errorf("expected *object.LimitError for %q. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if limitErr.Limit != tt.limit {
+			t.Errorf("wrong limit for %q. got=%q, want=%q", tt.input, limitErr.Limit, tt.limit)
+		}
+	}
+}
+
+func TestExecutionLimitsAllowCompletion(t *testing.T) {
+	input := `let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)`
+	options := Options{MaxSteps: 1000000, MaxCallDepth: 100, MaxAllocations: 1000}
+
+	testIntegerObject(t, testEvalContext(context.Background(), input, options), 610)
+}
+
+func TestEvalContextTimeout(t *testing.T) {
+	tests := []string{
+		`let f = fn() { f() }; f()`,
+		`receive(channel())`,
+		`send(channel(), 1)`,
+		`channel().receive()`,
+		`await(spawn receive(channel()))`,
+		`select { receive(channel()) as v => v }`,
+		`for (x in channel()) { x }`,
+		`collect(channel())`,
+	}
+
+	for _, input := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		evaluated := testEvalContext(ctx, input, Options{})
+		cancel()
+
+		limitErr, ok := evaluated.(*object.LimitError)
+		if !ok {
+			t.Errorf("expected *object.LimitError for %q. got=%T (%+v)", input, evaluated, evaluated)
+			continue
+		}
+		if limitErr.Limit != ContextLimit {
+			t.Errorf("wrong limit for %q. got=%q, want=%q", input, limitErr.Limit, ContextLimit)
+		}
+	}
+}
+
+func TestLimitsApplyToModules(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"spin.mk": `export let spin = fn(n) { spin(n + 1) };`,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	program := parser.New(lexer.New(`import "spin" as m; m.spin(0)`)).ParseProgram()
+	env := object.NewEnvironment()
+	env.SetFile(filepath.Join(dir, "main.mk"))
+	env.SetImporter(NewModuleResolver())
+
+	evaluated := EvalContext(ctx, program, env, Options{MaxSteps: 1000})
+	limitErr, ok := evaluated.(*object.LimitError)
+	if !ok {
+		t.Fatalf("expected *object.LimitError. got=%T (%+v)", evaluated, evaluated)
+	}
+	if limitErr.Limit != StepLimit {
+		t.Errorf("wrong limit. got=%q, want=%q", limitErr.Limit, StepLimit)
+	}
+}
+
+func TestLimitsOutliveEvaluation(t *testing.T) {
+	program := parser.New(lexer.New(`let spin = fn() { spin() }; spawn spin()`)).ParseProgram()
+	env := object.NewEnvironment()
+
+	task, ok := EvalContext(context.Background(), program, env, Options{MaxSteps: 1000}).(*object.Task)
+	if !ok {
+		t.Fatalf("expected *object.Task")
+	}
+	if env.Limiter() != nil {
+		t.Errorf("the limiter was left in the environment")
+	}
+
+	done := make(chan object.Object)
+	go func() { done <- task.Await() }()
+	select {
+	case result := <-done:
+		if _, ok := result.(*object.LimitError); !ok {
+			t.Errorf("expected *object.LimitError. got=%T (%+v)", result, result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the spawned task ran without a limit")
+	}
+}
+
+func TestIOBuiltins(t *testing.T) {
+	input := `let name = readline();
+	puts("hello " + name, 2);
+	eputs("oops");
+	let rest = readall();
+	puts(rest);
+	readline()`
+
+	var stdout, stderr strings.Builder
+	env := object.NewEnvironment()
+	env.SetIO(object.NewIO(strings.NewReader("monkey\r\nline two\nline three"), &stdout, &stderr))
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	evaluated := Eval(program, env)
+
+	if evaluated != NULL {
+		t.Errorf("readline at end of input should return NULL. got=%T (%+v)", evaluated, evaluated)
+	}
+	if stdout.String() != "hello monkey\n2\nline two\nline three\n" {
+		t.Errorf("wrong stdout. got=%q", stdout.String())
+	}
+	if stderr.String() != "oops\n" {
+		t.Errorf("wrong stderr. got=%q", stderr.String())
+	}
+}
+
+func TestModulesUseImporterIO(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"greet.mk": `puts("loaded"); export let hi = fn() { puts("hi") };`,
+	})
+	var stdout strings.Builder
+	env := object.NewEnvironment()
+	env.SetFile(filepath.Join(dir, "main.mk"))
+	env.SetImporter(NewModuleResolver())
+	env.SetIO(object.NewIO(strings.NewReader(""), &stdout, &stdout))
+
+	program := parser.New(lexer.New(`import "greet" as g; g.hi()`)).ParseProgram()
+	if evaluated := Eval(program, env); isError(evaluated) {
+		t.Fatalf("unexpected error %s", evaluated.Inspect())
+	}
+	if stdout.String() != "loaded\nhi\n" {
+		t.Errorf("wrong stdout. got=%q", stdout.String())
+	}
+}
+
+func TestErrorStackTraces(t *testing.T) {
+	input := `let inner = fn(a, b) {
+  a + b
+};
+let outer = fn(x) {
+  let y = inner(x, "two");
+  y
+};
+outer(1)`
+
+	errObj, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+
+	expected := []object.StackFrame{
+		{Function: "inner", Line: 5, Column: 16, Args: 2},
+		{Function: "outer", Line: 8, Column: 6, Args: 1},
+	}
+	if len(errObj.Stack) != len(expected) {
+		t.Fatalf("wrong number of frames. got=%d, want=%d (%+v)", len(errObj.Stack), len(expected), errObj.Stack)
+	}
+	for i, frame := range expected {
+		if errObj.Stack[i] != frame {
+			t.Errorf("frame %d wrong. got=%+v, want=%+v", i, errObj.Stack[i], frame)
+		}
+	}
+
+	traceback := `Traceback (most recent call last):
+  at line 8, column 6, in outer with 1 argument
+  at line 5, column 16, in inner with 2 arguments
+ERROR: type mismatch: INTEGER + STRING`
+	if errObj.Traceback() != traceback {
+		t.Errorf("wrong traceback. got=\n%s\nwant=\n%s", errObj.Traceback(), traceback)
+	}
+}
+
+func TestOperatorMethodStackTraces(t *testing.T) {
+	tests := []struct {
+		input  string
+		line   int
+		column int
+	}{
+		{"let v = {\"__add__\": fn(self, other) { other + \"x\" }};\nv  + 1", 2, 4},
+		{"let v = {\"__neg__\": fn(self) { 1 + \"x\" }};\n -v", 2, 2},
+		{"let v = {\"__index__\": fn(self, key) { key + \"x\" }};\nv[1]", 2, 2},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Fatalf("no error object returned")
+		}
+		if len(errObj.Stack) != 1 {
+			t.Fatalf("wrong number of frames. got=%d (%+v)", len(errObj.Stack), errObj.Stack)
+		}
+		if errObj.Stack[0].Line != tt.line || errObj.Stack[0].Column != tt.column {
+			t.Errorf("%q: wrong call site. got=%d:%d, want=%d:%d", tt.input,
+				errObj.Stack[0].Line, errObj.Stack[0].Column, tt.line, tt.column)
+		}
+	}
+}
+
+func TestRecursionTracebackCollapsesFrames(t *testing.T) {
+	errObj, ok := testEval(`let f = fn(n) { 1 + f(n + 1) }; f(0)`).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+
+	lines := strings.Split(errObj.Traceback(), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("wrong number of traceback lines. got=%d\n%s", len(lines), errObj.Traceback())
+	}
+	if lines[3] != "  [previous frame repeated 9999 more times]" {
+		t.Errorf("repeated frames not collapsed. got=%q", lines[3])
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let square = fn(x) { x * x }; await(spawn square(7))`, "49"},
+		{`let t = spawn fn() { 1 + 2 }; t.await()`, "3"},
+		{`let tasks = [spawn len("ab"), spawn len("abc")]; await(tasks[0]) + await(tasks[1])`, "5"},
+		{`let ch = channel(); spawn send(ch, 42); receive(ch)`, "42"},
+		{`let ch = channel(2); ch.send(1); ch.send(2); ch.len()`, "2"},
+		{`let ch = channel(1); send(ch, 1); close(ch); [receive(ch), receive(ch)]`, "[1, NULL]"},
+		{`let produce = fn(ch, n) { for (i in range(n)) { send(ch, i * 10) }; close(ch) };
+		  let ch = channel(); spawn produce(ch, 3); ch.collect()`, "[0, 10, 20]"},
+		{`let ch = channel(); spawn fn() { send(ch, 1); send(ch, 2); close(ch) }();
+		  last(ch)`, "2"},
+		{`let worker = fn(results, x) { send(results, x * x) };
+		  let results = channel(3);
+		  let ts = [spawn worker(results, 1), spawn worker(results, 2), spawn worker(results, 3)];
+		  await(ts[0]); await(ts[1]); await(ts[2]);
+		  let a = receive(results); let b = receive(results); let c = receive(results);
+		  a + b + c`, "14"},
+		{`let ch = channel(1); send(ch, 5); select { receive(ch) as v => v * 2, _ => 0 }`, "10"},
+		{`let ch = channel(); select { receive(ch) as v => v, _ => "empty" }`, "empty"},
+		{`let ch = channel(1); select { send(ch, 3) => "sent" }; receive(ch)`, "3"},
+		{`let ch = channel(); close(ch); select { receive(ch) as v => v }`, "NULL"},
+		{`let ch = channel(); close(ch); select { send(ch, 1) => 1 }`, "ERROR: send on closed channel"},
+		{`let ch = channel(); close(ch); send(ch, 1)`, "ERROR: send on closed channel"},
+		{`let ch = channel(); close(ch); close(ch)`, "ERROR: close of closed channel"},
+		{`select { receive(1) => 1 }`, "ERROR: select on INTEGER, want CHANNEL"},
+		{`spawn 1`, "ERROR: cannot spawn INTEGER"},
+		{`await(spawn fn() { 1 + true }())`, "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{`channel(-1)`, "ERROR: argument to `channel` must be a non-negative INTEGER, got -1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("no result for %q", tt.input)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let x: int = 5; x`, 5},
+		{`let x: int = "five"; x`, "type error: cannot assign STRING to x of type int"},
+		{`let xs: [int] = [1, "2"]; 0`, "type error: cannot assign ARRAY to xs of type [int]"},
+		{`let h: {string: int} = {"a": 1}; h["a"]`, 1},
+		{`let f: fn(int) -> int = fn(a) { a }; f(2)`, 2},
+		{`let f: fn(int) = fn(a, b) { a }; 0`, "type error: cannot assign FUNCTION to f of type fn(int)"},
+		{`let add = fn(a: int, b: int) -> int { a + b }; add(1, 2)`, 3},
+		{`let add = fn(a: int, b: int) -> int { a + b }; add(1, "2")`, "type error: argument b of add is STRING, want int"},
+		{`let f = fn(a) -> int { if (a) { return "yes"; } 1 }; f(true)`, "type error: f returned STRING, want int"},
+		{`let f = fn() -> null { let x = 1; }; f(); 7`, 7},
+		{`let count = fn(n) -> int { if (n == 0) { "done" } else { count(n - 1) } }; count(3)`,
+			"type error: count returned STRING, want int"},
+		{`enum Color { Red, Green }; let c: Color = Red; let d: Color = 1`, "type error: cannot assign INTEGER to d of type Color"},
+		{`let f = fn(x: any) -> any { x }; f(8)`, 8},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		env := object.NewEnvironment()
+		env.SetStrict(true)
+		evaluated := Eval(program, env)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			err, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%q: no error object returned. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if err.Message != expected {
+				t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, expected, err.Message)
+			}
+		}
+	}
+
+	// Annotations are ignored unless strict mode is on.
+	testIntegerObject(t, testEval(`let x: string = 5; x`), 5)
+}
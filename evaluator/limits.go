@@ -0,0 +1,198 @@
+package evaluator
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/object"
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// Names of the limits reported in object.LimitError.
+const (
+	StepLimit       = "steps"
+	CallDepthLimit  = "call depth"
+	AllocationLimit = "allocations"
+	ContextLimit    = "context"
+)
+
+// contextCheckInterval is the number of steps between checks of the
+// context, which is too expensive to consult on every step.
+const contextCheckInterval = 256
+
+// Options bounds the resources a single evaluation may use. A zero field
+// means no limit.
+type Options struct {
+	// MaxSteps is the number of nodes that may be evaluated.
+	MaxSteps int
+	// MaxCallDepth is the number of nested function calls allowed.
+	MaxCallDepth int
+	// MaxAllocations roughly bounds memory use, counted in array elements,
+	// hash pairs and string bytes created.
+	MaxAllocations int
+}
+
+// limiter is shared by every task spawned during an evaluation, so its
+// counters are updated atomically.
+type limiter struct {
+	ctx       context.Context
+	options   Options
+	steps     atomic.Int64
+	allocated atomic.Int64
+}
+
+func (l *limiter) Step() object.Object {
+	steps := l.steps.Add(1)
+
+	if l.options.MaxSteps > 0 && steps > int64(l.options.MaxSteps) {
+		return newLimitError(StepLimit, "step limit of %d exceeded", l.options.MaxSteps)
+	}
+
+	if steps%contextCheckInterval == 0 {
+		if err := l.ctx.Err(); err != nil {
+			return newLimitError(ContextLimit, "evaluation stopped: %s", err)
+		}
+	}
+
+	return nil
+}
+
+func (l *limiter) Allocate(size int) object.Object {
+	allocated := l.allocated.Add(int64(size))
+
+	if l.options.MaxAllocations > 0 && allocated > int64(l.options.MaxAllocations) {
+		return newLimitError(AllocationLimit, "allocation limit of %d exceeded", l.options.MaxAllocations)
+	}
+
+	return nil
+}
+
+func (l *limiter) Done() <-chan struct{} {
+	return l.ctx.Done()
+}
+
+func (l *limiter) Stopped() object.Object {
+	return newLimitError(ContextLimit, "evaluation stopped: %s", l.ctx.Err())
+}
+
+func (l *limiter) EnterCall(depth int) object.Object {
+	if l.options.MaxCallDepth > 0 && depth > l.options.MaxCallDepth {
+		return newLimitError(CallDepthLimit, "call depth limit of %d exceeded", l.options.MaxCallDepth)
+	}
+
+	return nil
+}
+
+func newLimitError(limit, format string, a ...interface{}) *object.LimitError {
+	return &object.LimitError{Limit: limit, Message: fmt.Sprintf(format, a...)}
+}
+
+// EvalContext evaluates node like Eval, but stops with an
+// *object.LimitError once ctx is done or any limit in options is exceeded.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, options Options) object.Object {
+	if ctx.Err() != nil {
+		return (&limiter{ctx: ctx}).Stopped()
+	}
+
+	// The limiter is bound to a view of env rather than to env itself, so
+	// that evaluations sharing env do not see each other's limits. Calls,
+	// generators and spawned tasks pass it on from their callers.
+	return Eval(node, env.WithLimiter(&limiter{ctx: ctx, options: options}))
+}
+
+func limiterOf(env *object.Environment) object.Limiter {
+	if env == nil {
+		return nil
+	}
+	return env.Limiter()
+}
+
+// allocate charges the size of obj, newly created by a builtin that could
+// not charge it beforehand, to the limiter of env.
+func allocate(obj object.Object, env *object.Environment) object.Object {
+	var size int
+	switch obj := obj.(type) {
+	case *object.Array:
+		size = len(obj.Elements)
+	case *object.Hash:
+		size = len(obj.Pairs)
+	case *object.String:
+		size = len(obj.Value)
+	default:
+		return obj
+	}
+
+	if err := charge(env.Limiter(), size); err != nil {
+		return err
+	}
+	return obj
+}
+
+// charge charges size to limiter, if there is one, before an object of
+// that size is created.
+func charge(limiter object.Limiter, size int) object.Object {
+	if limiter == nil {
+		return nil
+	}
+	return limiter.Allocate(size)
+}
+
+// done returns the channel that is closed once the evaluation of limiter
+// is stopped, or nil, which is never ready, if there is no limiter.
+func done(limiter object.Limiter) <-chan struct{} {
+	if limiter == nil {
+		return nil
+	}
+	return limiter.Done()
+}
+
+// nextElement returns the next element of it, charging limiter a step for
+// it, so that looping over a long sequence counts like evaluating a loop.
+func nextElement(it object.Iterator, limiter object.Limiter) (object.Object, bool) {
+	if limiter != nil {
+		if err := limiter.Step(); err != nil {
+			return err, true
+		}
+	}
+	return it.Next()
+}
+
+// receive receives from ch like ch.Receive, unless the evaluation of
+// limiter is stopped first, in which case it returns the error to stop
+// with.
+func receive(ch *object.Channel, limiter object.Limiter) (object.Object, bool) {
+	select {
+	case value, ok := <-ch.C:
+		return value, ok
+	case <-done(limiter):
+		return limiter.Stopped(), true
+	}
+}
+
+// send sends value on ch like ch.Send, unless the evaluation of limiter is
+// stopped first.
+func send(ch *object.Channel, value object.Object, limiter object.Limiter) (err object.Object) {
+	defer func() {
+		if recover() != nil {
+			err = newError("send on closed channel")
+		}
+	}()
+
+	select {
+	case ch.C <- value:
+		return nil
+	case <-done(limiter):
+		return limiter.Stopped()
+	}
+}
+
+// channelIterator receives from a channel until it is closed, or until the
+// evaluation of limiter is stopped, whose error it then yields.
+type channelIterator struct {
+	channel *object.Channel
+	limiter object.Limiter
+}
+
+func (it channelIterator) Next() (object.Object, bool) {
+	return receive(it.channel, it.limiter)
+}
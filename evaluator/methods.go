@@ -0,0 +1,176 @@
+package evaluator
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/object"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// methods holds the functions callable with method syntax on each object
+// type. Every method receives its receiver as the first argument, but
+// reports the number of arguments it was called with without it.
+var methods = map[object.ObjectType]map[string]*object.Builtin{
+	object.ARRAY_OBJ: {
+		"len":   builtinMethod("len"),
+		"first": builtinMethod("first"),
+		"last":  builtinMethod("last"),
+		"rest":  builtinMethod("rest"),
+		"push":  builtinMethod("push"),
+	},
+	object.HASH_OBJ: {
+		"len": builtinMethod("len"),
+		"keys": limited(func(limiter object.Limiter, args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=0", len(args)-1)
+			}
+			return collectIterator(args[0].(*object.Hash).Iterator(), limiter)
+		}),
+	},
+	object.STRING_OBJ: {
+		"len":   builtinMethod("len"),
+		"first": builtinMethod("first"),
+		"last":  builtinMethod("last"),
+		"upper": stringMethod("upper", strings.ToUpper),
+		"lower": stringMethod("lower", strings.ToLower),
+		"split": limited(func(limiter object.Limiter, args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args)-1)
+			}
+
+			sep, ok := args[1].(*object.String)
+			if !ok {
+				return newError("argument to `split` must be STRING, got %s", args[1].Type())
+			}
+
+			// Charge for the parts and, at most, the bytes they hold.
+			str := args[0].(*object.String).Value
+			count := strings.Count(str, sep.Value) + 1
+			if sep.Value == "" {
+				count = utf8.RuneCountInString(str)
+			}
+			if err := charge(limiter, count+len(str)); err != nil {
+				return err
+			}
+
+			parts := strings.Split(str, sep.Value)
+			elements := make([]object.Object, len(parts))
+			for i, part := range parts {
+				elements[i] = &object.String{Value: part}
+			}
+
+			return &object.Array{Elements: elements}
+		}),
+	},
+	object.RANGE_OBJ: {
+		"len":     builtinMethod("len"),
+		"first":   builtinMethod("first"),
+		"last":    builtinMethod("last"),
+		"collect": builtinMethod("collect"),
+	},
+	object.GENERATOR_OBJ: {
+		"next":    builtinMethod("next"),
+		"close":   builtinMethod("close"),
+		"collect": builtinMethod("collect"),
+	},
+	object.CHANNEL_OBJ: {
+		"len":     builtinMethod("len"),
+		"send":    builtinMethod("send"),
+		"receive": builtinMethod("receive"),
+		"close":   builtinMethod("close"),
+		"collect": builtinMethod("collect"),
+	},
+	object.TASK_OBJ: {
+		"await": builtinMethod("await"),
+	},
+}
+
+// MethodNames returns the sorted names of the methods of every type.
+func MethodNames() []string {
+	seen := make(map[string]bool)
+	names := []string{}
+	for _, typeMethods := range methods {
+		for name := range typeMethods {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// builtinMethod returns the builtin called name for use as a method.
+func builtinMethod(name string) *object.Builtin {
+	builtin := builtins[name]
+	least, greatest := builtinArities[name][0]-1, builtinArities[name][1]-1
+
+	return limited(func(limiter object.Limiter, args ...object.Object) object.Object {
+		got := len(args) - 1
+		if got < least || greatest >= 0 && got > greatest {
+			want := fmt.Sprint(least)
+			if greatest != least {
+				want = fmt.Sprintf("%d..%d", least, greatest)
+			}
+			return newError("wrong number of arguments. got=%d, want=%s", got, want)
+		}
+		if builtin.Limited != nil {
+			return builtin.Limited(limiter, args...)
+		}
+		return builtin.Fn(args...)
+	})
+}
+
+func stringMethod(name string, fn func(string) string) *object.Builtin {
+	return limited(func(limiter object.Limiter, args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError("wrong number of arguments. got=%d, want=0", len(args)-1)
+		}
+		str := args[0].(*object.String).Value
+		if err := charge(limiter, len(str)); err != nil {
+			return err
+		}
+		return &object.String{Value: fn(str)}
+	})
+}
+
+// evalMethodCallExpression resolves receiver.name(args) by looking for a
+// method registered for the receiver's type first and then for a function
+// in scope that takes the receiver as its first argument.
+func evalMethodCallExpression(node *ast.MethodCallExpression, env *object.Environment) object.Object {
+	receiver := Eval(node.Receiver, env)
+	if isError(receiver) {
+		return receiver
+	}
+
+	args := evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	name := node.Method.Value
+
+	if module, ok := receiver.(*object.Module); ok {
+		fn, ok := module.Exports[name]
+		if !ok {
+			return newError("%s is not exported by module %s", name, module.Name)
+		}
+		return applyFunction(fn, args, env, node.Method.Token)
+	}
+
+	receiverArgs := append([]object.Object{receiver}, args...)
+
+	if method, ok := methods[receiver.Type()][name]; ok {
+		return applyFunction(method, receiverArgs, env, node.Method.Token)
+	}
+
+	fn := evalIdentifier(node.Method, env)
+	if isError(fn) {
+		return newError("undefined method %s for %s", name, receiver.Type())
+	}
+
+	return applyFunction(fn, receiverArgs, env, node.Method.Token)
+}
@@ -0,0 +1,271 @@
+package evaluator
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/lexer"
+	"WeekTwo/object"
+	"WeekTwo/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const ModuleExtension = ".mk"
+
+// ModuleResolver finds, evaluates and caches imported modules. Modules are
+// looked up relative to the importing file first and then in each directory
+// of SearchPath. When Globals is set, every module is evaluated in an
+// environment enclosed by it.
+//
+// A resolver is safe for concurrent use. A task importing a module that
+// another task is loading waits for it instead of loading it again.
+type ModuleResolver struct {
+	SearchPath []string
+	Globals    *object.Environment
+	mu         sync.Mutex
+	modules    map[string]*moduleLoad
+}
+
+// moduleLoad is a module that is loaded or being loaded. result is set
+// before done is closed.
+type moduleLoad struct {
+	done   chan struct{}
+	result object.Object
+	// The modules the module is importing while it loads, with the number
+	// of its imports of each that are in progress.
+	imports map[string]int
+}
+
+func NewModuleResolver(searchPath ...string) *ModuleResolver {
+	return &ModuleResolver{
+		SearchPath: searchPath,
+		modules:    make(map[string]*moduleLoad),
+	}
+}
+
+// defaultResolver serves environments that were not given an importer. Its
+// search path comes from the MONKEYPATH environment variable.
+var defaultResolver = NewModuleResolver(filepath.SplitList(os.Getenv("MONKEYPATH"))...)
+
+func (r *ModuleResolver) Resolve(path string, file string) (string, bool) {
+	if filepath.Ext(path) == "" {
+		path += ModuleExtension
+	}
+
+	candidates := []string{}
+	if filepath.IsAbs(path) {
+		candidates = append(candidates, path)
+	} else {
+		dir := "."
+		if file != "" {
+			dir = filepath.Dir(file)
+		}
+		candidates = append(candidates, filepath.Join(dir, path))
+		for _, searchDir := range r.SearchPath {
+			candidates = append(candidates, filepath.Join(searchDir, path))
+		}
+	}
+
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		abs, err := filepath.Abs(candidate)
+		if err != nil {
+			continue
+		}
+		return abs, true
+	}
+
+	return "", false
+}
+
+func (r *ModuleResolver) Import(path string, importing *object.Environment) object.Object {
+	file := importing.File()
+	resolved, ok := r.Resolve(path, file)
+	if !ok {
+		return newError("module not found: %s", path)
+	}
+
+	r.mu.Lock()
+	load, loading := r.modules[resolved]
+	if loading {
+		if cycle := r.cycle(file, resolved); cycle != nil {
+			r.mu.Unlock()
+			return newError("import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	} else {
+		load = &moduleLoad{done: make(chan struct{}), imports: make(map[string]int)}
+		r.modules[resolved] = load
+	}
+	// Until the import is over, the importing module waits for this one,
+	// which is what the cycle check of other imports follows.
+	importer := r.loading(file)
+	if importer != nil {
+		importer.imports[resolved]++
+	}
+	r.mu.Unlock()
+
+	if loading {
+		<-load.done
+	} else {
+		load.result = r.load(path, resolved, importing)
+	}
+
+	r.mu.Lock()
+	if importer != nil {
+		importer.imports[resolved]--
+		if importer.imports[resolved] == 0 {
+			delete(importer.imports, resolved)
+		}
+	}
+	if !loading {
+		// Failed loads are not cached, so that a fixed module can be
+		// imported again.
+		if isError(load.result) {
+			delete(r.modules, resolved)
+		}
+		close(load.done)
+	}
+	r.mu.Unlock()
+
+	return load.result
+}
+
+// loading returns the load of file if it is in progress. r.mu must be held.
+func (r *ModuleResolver) loading(file string) *moduleLoad {
+	load, ok := r.modules[file]
+	if !ok || loaded(load) {
+		return nil
+	}
+	return load
+}
+
+// cycle returns the chain of imports that leads from file to resolved and
+// back, through modules being loaded by any task, or nil if waiting for
+// resolved would not come back to file. r.mu must be held.
+func (r *ModuleResolver) cycle(file string, resolved string) []string {
+	if chain := r.waitsFor(resolved, file); chain != nil {
+		return append([]string{file}, chain...)
+	}
+	return nil
+}
+
+// waitsFor returns the chain of imports through which the load of from is
+// waiting for to, or nil if it is not. r.mu must be held.
+func (r *ModuleResolver) waitsFor(from string, to string) []string {
+	if from == to {
+		return []string{to}
+	}
+	load := r.loading(from)
+	if load == nil {
+		return nil
+	}
+	for next := range load.imports {
+		if chain := r.waitsFor(next, to); chain != nil {
+			return append([]string{from}, chain...)
+		}
+	}
+	return nil
+}
+
+func loaded(load *moduleLoad) bool {
+	select {
+	case <-load.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// load reads and evaluates the module at resolved. The module writes to
+// the streams of the importing environment and is evaluated within its
+// limits.
+func (r *ModuleResolver) load(path string, resolved string, importing *object.Environment) object.Object {
+	source, err := os.ReadFile(resolved)
+	if err != nil {
+		return newError("could not read module %s: %s", path, err)
+	}
+
+	p := parser.New(lexer.New(string(source)))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return newError("parse errors in module %s: %s", path, strings.Join(p.Errors(), "; "))
+	}
+
+	env := object.NewEnvironment()
+	if r.Globals != nil {
+		env = object.NewEnclosedEnvironment(r.Globals)
+	}
+	env.SetFile(resolved)
+	env.SetImporter(r)
+	if streams := importing.IO(); streams != nil {
+		env.SetIO(streams)
+	}
+	env.SetLimiter(limiterOf(importing))
+
+	result := Eval(program, env)
+	if isError(result) {
+		return result
+	}
+
+	return &object.Module{
+		Name:    path,
+		Path:    resolved,
+		Env:     env,
+		Exports: moduleExports(program, env),
+	}
+}
+
+func moduleExports(program *ast.Program, env *object.Environment) map[string]object.Object {
+	exports := make(map[string]object.Object)
+
+	for _, stmt := range program.Statements {
+		export, ok := stmt.(*ast.ExportStatement)
+		if !ok {
+			continue
+		}
+		name := export.Statement.Name.Value
+		if val, ok := env.Get(name); ok {
+			exports[name] = val
+		}
+	}
+
+	return exports
+}
+
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	importer := env.Importer()
+	if importer == nil {
+		importer = defaultResolver
+	}
+
+	module := importer.Import(node.Path.Value, env)
+	if isError(module) {
+		return module
+	}
+
+	bind(env, node.Alias, module)
+	return nil
+}
+
+func evalMemberExpression(node *ast.MemberExpression, env *object.Environment) object.Object {
+	obj := Eval(node.Object, env)
+	if isError(obj) {
+		return obj
+	}
+
+	module, ok := obj.(*object.Module)
+	if !ok {
+		return newError("member access not supported: %s", obj.Type())
+	}
+
+	val, ok := module.Exports[node.Property.Value]
+	if !ok {
+		return newError("%s is not exported by module %s", node.Property.Value, module.Name)
+	}
+
+	return val
+}
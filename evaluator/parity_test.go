@@ -0,0 +1,199 @@
+package evaluator_test
+
+import (
+	"WeekTwo/compiler"
+	"WeekTwo/evaluator"
+	"WeekTwo/lexer"
+	"WeekTwo/object"
+	"WeekTwo/parser"
+	"WeekTwo/vm"
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// The compiler and the virtual machine must give the same results as the
+// evaluator on its whole test suite. Once the tests have run, every input
+// testEval evaluated is compiled and run again on the virtual machine.
+//
+// The programs using what the compiler does not support are listed in
+// unsupportedFile, so that none is left out unnoticed. Run the tests with
+// -update-unsupported to rewrite the list.
+const unsupportedFile = "testdata/unsupported.txt"
+
+var updateUnsupported = flag.Bool("update-unsupported", false, "rewrite "+unsupportedFile)
+
+var (
+	evaluatedMu sync.Mutex
+	evaluated   = map[string]string{}
+)
+
+func TestMain(m *testing.M) {
+	evaluator.Evaluated = func(input string, result object.Object) {
+		evaluatedMu.Lock()
+		defer evaluatedMu.Unlock()
+		if _, ok := evaluated[input]; !ok {
+			evaluated[input] = inspect(result)
+		}
+	}
+
+	code := m.Run()
+	if code == 0 && !checkParity() {
+		code = 1
+	}
+	os.Exit(code)
+}
+
+// checkParity runs the evaluated inputs on the virtual machine, prints the
+// differences with the evaluator and reports whether there were none.
+func checkParity() bool {
+	listed, err := readUnsupported()
+	if err != nil {
+		fmt.Println(err)
+		return false
+	}
+
+	inputs := make([]string, 0, len(evaluated))
+	for input := range evaluated {
+		inputs = append(inputs, input)
+	}
+	sort.Strings(inputs)
+
+	ok := true
+	unsupported := []string{}
+	for _, input := range inputs {
+		got, supported := runOnVM(input)
+		if !supported {
+			unsupported = append(unsupported, input)
+			if !listed[input] && !*updateUnsupported {
+				fmt.Printf("%q is not supported by the compiler and is not listed in %s\n", input, unsupportedFile)
+				ok = false
+			}
+			continue
+		}
+		if listed[input] && !*updateUnsupported {
+			fmt.Printf("%q is supported by the compiler but is listed in %s\n", input, unsupportedFile)
+			ok = false
+		}
+		if want := evaluated[input]; got != want {
+			fmt.Printf("%q: the vm differs from the evaluator. evaluator=%q, vm=%q\n", input, want, got)
+			ok = false
+		}
+	}
+
+	if *updateUnsupported {
+		if err := writeUnsupported(unsupported); err != nil {
+			fmt.Println(err)
+			return false
+		}
+		return ok
+	}
+
+	// Only the whole suite evaluates every listed input.
+	if flag.Lookup("test.run").Value.String() == "" {
+		for input := range listed {
+			if _, seen := evaluated[input]; !seen {
+				fmt.Printf("%q is listed in %s but no test evaluates it\n", input, unsupportedFile)
+				ok = false
+			}
+		}
+	}
+
+	if !ok {
+		fmt.Println("FAIL: the vm does not match the evaluator")
+	}
+	return ok
+}
+
+// runOnVM compiles and runs input and returns its result as inspect prints
+// it, or false if the compiler does not support the program. Like testEval,
+// it runs what could be parsed of the program.
+func runOnVM(input string) (string, bool) {
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	// The compiler reports some of the errors the evaluator only finds
+	// running the program, such as the use of an unbound name.
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		if strings.HasSuffix(err.Error(), "is not supported by the compiler") {
+			return "", false
+		}
+		return "ERROR: " + err.Error(), true
+	}
+
+	machine := vm.New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		return "ERROR: " + err.Error(), true
+	}
+	return inspect(machine.LastPoppedStackElem()), true
+}
+
+// readUnsupported reads the inputs of unsupportedFile, one quoted input per
+// line.
+func readUnsupported() (map[string]bool, error) {
+	listed := map[string]bool{}
+
+	f, err := os.Open(unsupportedFile)
+	if err != nil {
+		if os.IsNotExist(err) && *updateUnsupported {
+			return listed, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		input, err := strconv.Unquote(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q: %s", unsupportedFile, scanner.Text(), err)
+		}
+		listed[input] = true
+	}
+	return listed, scanner.Err()
+}
+
+func writeUnsupported(inputs []string) error {
+	var b strings.Builder
+	for _, input := range inputs {
+		b.WriteString(strconv.Quote(input) + "\n")
+	}
+	if err := os.MkdirAll("testdata", 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(unsupportedFile, []byte(b.String()), 0o644)
+}
+
+// inspect returns obj the way the REPL prints it, except that the pairs of
+// hashes are sorted, and that functions, which the two backends represent
+// differently, are all printed alike.
+func inspect(obj object.Object) string {
+	switch obj := obj.(type) {
+	case nil:
+		return ""
+	case *object.Function, *object.Closure:
+		return "function"
+	case *object.Array:
+		elements := make([]string, len(obj.Elements))
+		for i, el := range obj.Elements {
+			elements[i] = inspect(el)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *object.Hash:
+		pairs := make([]string, 0, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			pairs = append(pairs, inspect(pair.Key)+": "+inspect(pair.Value))
+		}
+		sort.Strings(pairs)
+		return "{" + strings.Join(pairs, ", ") + "}"
+	default:
+		return obj.Inspect()
+	}
+}
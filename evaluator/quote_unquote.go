@@ -0,0 +1,128 @@
+package evaluator
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/object"
+	"WeekTwo/token"
+	"fmt"
+)
+
+func quote(node ast.Node, env *object.Environment) object.Object {
+	node, err := evalUnquoteCalls(node, env)
+	if err != nil {
+		return err
+	}
+	return &object.Quote{Node: node}
+}
+
+// evalUnquoteCalls replaces the unquote calls in quoted with the syntax of
+// their values. It returns the first error an unquote call evaluates to or
+// that its value cannot be converted with.
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment) (ast.Node, object.Object) {
+	var err object.Object
+
+	node := ast.Modify(quoted, func(node ast.Node) ast.Node {
+		if err != nil || !isUnquoteCall(node) {
+			return node
+		}
+
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+
+		if len(call.Arguments) != 1 {
+			return node
+		}
+
+		unquoted := Eval(call.Arguments[0], env)
+		if isError(unquoted) {
+			err = unquoted
+			return node
+		}
+
+		var converted ast.Node
+		converted, err = convertObjectToASTNode(unquoted)
+		if err != nil {
+			return node
+		}
+		return converted
+	})
+
+	return node, err
+}
+
+func isUnquoteCall(node ast.Node) bool {
+	callExpression, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+
+	return callExpression.Function.TokenLiteral() == "unquote"
+}
+
+func convertObjectToASTNode(obj object.Object) (ast.Node, object.Object) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		t := token.Token{
+			Type:    token.INT,
+			Literal: fmt.Sprintf("%d", obj.Value),
+		}
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}, nil
+	case *object.Boolean:
+		var t token.Token
+		if obj.Value {
+			t = token.Token{Type: token.TRUE, Literal: "true"}
+		} else {
+			t = token.Token{Type: token.FALSE, Literal: "false"}
+		}
+		return &ast.Boolean{Token: t, Value: obj.Value}, nil
+	case *object.String:
+		t := token.Token{Type: token.STRING, Literal: obj.Value}
+		return &ast.StringLiteral{Token: t, Value: obj.Value}, nil
+	case *object.Array:
+		elements := make([]ast.Expression, len(obj.Elements))
+		for i, element := range obj.Elements {
+			node, err := convertToExpression(element)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = node
+		}
+		t := token.Token{Type: token.LBRACKET, Literal: "["}
+		return &ast.ArrayLiteral{Token: t, Elements: elements}, nil
+	case *object.Hash:
+		pairs := make(map[ast.Expression]ast.Expression, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, err := convertToExpression(pair.Key)
+			if err != nil {
+				return nil, err
+			}
+			value, err := convertToExpression(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			pairs[key] = value
+		}
+		t := token.Token{Type: token.LBRACE, Literal: "{"}
+		return &ast.HashLiteral{Token: t, Pairs: pairs}, nil
+	case *object.Quote:
+		return obj.Node, nil
+	default:
+		return nil, newError("cannot unquote %s", obj.Type())
+	}
+}
+
+// convertToExpression converts obj like convertObjectToASTNode for use
+// inside an array or hash literal.
+func convertToExpression(obj object.Object) (ast.Expression, object.Object) {
+	node, err := convertObjectToASTNode(obj)
+	if err != nil {
+		return nil, err
+	}
+	expression, ok := node.(ast.Expression)
+	if !ok {
+		return nil, newError("cannot unquote %s", obj.Type())
+	}
+	return expression, nil
+}
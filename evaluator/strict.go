@@ -0,0 +1,111 @@
+package evaluator
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/object"
+)
+
+// matchesType reports whether obj is a value of the annotated type t. The
+// elements of arrays and hashes are checked too, but only the number of
+// parameters of functions.
+func matchesType(t ast.Type, obj object.Object) bool {
+	switch t := t.(type) {
+	case *ast.NamedType:
+		switch t.Name {
+		case "int":
+			return obj.Type() == object.INTEGER_OBJ
+		case "bool":
+			return obj.Type() == object.BOOLEAN_OBJ
+		case "string":
+			return obj.Type() == object.STRING_OBJ
+		case "null":
+			return obj.Type() == object.NULL_OBJ
+		case "any":
+			return true
+		}
+		value, ok := obj.(*object.EnumValue)
+		return ok && value.Enum.Name == t.Name
+	case *ast.ArrayType:
+		array, ok := obj.(*object.Array)
+		if !ok {
+			return false
+		}
+		for _, el := range array.Elements {
+			if !matchesType(t.Element, el) {
+				return false
+			}
+		}
+		return true
+	case *ast.HashType:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return false
+		}
+		for _, pair := range hash.Pairs {
+			if !matchesType(t.Key, pair.Key) || !matchesType(t.Value, pair.Value) {
+				return false
+			}
+		}
+		return true
+	case *ast.FunctionType:
+		switch fn := obj.(type) {
+		case *object.Function:
+			return len(fn.Parameters) == len(t.Parameters)
+		case *object.Builtin:
+			return true
+		}
+	}
+	return false
+}
+
+// checkArguments returns an error if strict mode is on and an argument
+// does not match the annotation of its parameter.
+func checkArguments(fn *object.Function, args []object.Object) object.Object {
+	if fn.ParameterTypes == nil || !fn.Env.Strict() {
+		return nil
+	}
+	for i, t := range fn.ParameterTypes {
+		if t != nil && i < len(args) && !matchesType(t, args[i]) {
+			return newError("type error: argument %s of %s is %s, want %s", fn.Parameters[i].Value, functionName(fn), args[i].Type(), t)
+		}
+	}
+	return nil
+}
+
+// appendReturn adds fn to the functions whose result has to be checked,
+// unless one with the same annotation is there already, as it is when a
+// function calls itself in tail position.
+func appendReturn(returns []*object.Function, fn *object.Function) []*object.Function {
+	for _, r := range returns {
+		if r.ReturnType == fn.ReturnType {
+			return returns
+		}
+	}
+	return append(returns, fn)
+}
+
+// checkReturns returns an error if result does not match the annotated
+// result type of one of returns, and result otherwise.
+func checkReturns(returns []*object.Function, result object.Object) object.Object {
+	if isError(result) {
+		return result
+	}
+	// A body that ends in a let statement has no value.
+	value := result
+	if value == nil {
+		value = NULL
+	}
+	for _, fn := range returns {
+		if !matchesType(fn.ReturnType, value) {
+			return newError("type error: %s returned %s, want %s", functionName(fn), value.Type(), fn.ReturnType)
+		}
+	}
+	return result
+}
+
+func functionName(fn *object.Function) string {
+	if fn.Name == "" {
+		return "function"
+	}
+	return fn.Name
+}
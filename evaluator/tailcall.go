@@ -0,0 +1,101 @@
+package evaluator
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/object"
+	"WeekTwo/token"
+)
+
+// tailCall is returned instead of a result when a function body ends in a
+// call. applyFunction runs it in a loop rather than recursing, so that tail
+// recursion runs in constant Go stack space.
+type tailCall struct {
+	fn     object.Object
+	args   []object.Object
+	caller *object.Environment
+	site   token.Token
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+func isTailCallable(call *ast.CallExpression) bool {
+	return call.Function.TokenLiteral() != "quote"
+}
+
+func evalCall(node *ast.CallExpression, env *object.Environment) (object.Object, []object.Object, object.Object) {
+	function := Eval(node.Function, env)
+	if isError(function) {
+		return nil, nil, function
+	}
+
+	args := evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return nil, nil, args[0]
+	}
+
+	return function, args, nil
+}
+
+func evalTailCall(node *ast.CallExpression, env *object.Environment) object.Object {
+	function, args, err := evalCall(node, env)
+	if err != nil {
+		return err
+	}
+
+	// Builtins never recurse, so there is nothing to gain from deferring them.
+	if _, ok := function.(*object.Builtin); ok {
+		return applyFunction(function, args, env, node.Token)
+	}
+
+	return &tailCall{fn: function, args: args, caller: env, site: node.Token}
+}
+
+// evalFunctionBody evaluates a function body like evalBlockStatement, but
+// evaluates its final statement in tail position.
+func evalFunctionBody(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+
+	for i, statement := range block.Statements {
+		trace(statement, env)
+
+		if i == len(block.Statements)-1 {
+			return evalTail(statement, env)
+		}
+
+		result = Eval(statement, env)
+
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+func evalTail(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		return evalTail(node.Expression, env)
+	case *ast.CallExpression:
+		if isTailCallable(node) {
+			return evalTailCall(node, env)
+		}
+	case *ast.IfExpression:
+		condition := Eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if isTruthy(condition) {
+			return evalFunctionBody(node.Consequence, env)
+		} else if node.Alternative != nil {
+			return evalFunctionBody(node.Alternative, env)
+		}
+		return NULL
+	}
+
+	return Eval(node, env)
+}
@@ -0,0 +1,644 @@
+// Package format prints Monkey programs in a canonical layout: statements
+// one per line, blocks indented by four spaces and argument, element and
+// pair lists broken one item per line when they do not fit in the line
+// width. Comments and single blank lines between statements are kept.
+package format
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/lexer"
+	"WeekTwo/parser"
+	"WeekTwo/token"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	width  = 80
+	indent = "    "
+)
+
+// Source formats src. It returns an error if src does not parse or holds
+// characters that are not part of any token, since formatting would lose
+// whatever could not be parsed.
+func Source(src []byte) ([]byte, error) {
+	if err := checkTokens(src); err != nil {
+		return nil, err
+	}
+
+	lex := lexer.New(string(src))
+	p := parser.New(lex)
+	program := p.ParseProgram()
+
+	if errors := p.ErrorDetails(); len(errors) != 0 {
+		messages := make([]string, len(errors))
+		for i, err := range errors {
+			messages[i] = fmt.Sprintf("%d:%d: %s", err.Token.Line, err.Token.Column, err.Message)
+		}
+		return nil, fmt.Errorf("%s", strings.Join(messages, "\n"))
+	}
+
+	pr := &printer{
+		comments: lex.Comments(),
+		lines:    strings.Split(string(src), "\n"),
+		atStart:  true,
+	}
+	pr.statements(program.Statements, false)
+	pr.flushComments(token.Token{Line: len(pr.lines) + 1})
+
+	return pr.buf.Bytes(), nil
+}
+
+// checkTokens returns an error for the first illegal token in src. The
+// parser stops at such a token without reporting it.
+func checkTokens(src []byte) error {
+	lex := lexer.New(string(src))
+	for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
+		if tok.Type == token.ILLEGAL {
+			return fmt.Errorf("%d:%d: illegal character %q", tok.Line, tok.Column, tok.Literal)
+		}
+	}
+	return nil
+}
+
+// printer writes a program to buf. In flat mode it writes everything on
+// one line and sets broken when something cannot be written that way.
+type printer struct {
+	buf   bytes.Buffer
+	depth int
+
+	comments []token.Token // the comments not written yet
+	lines    []string      // the source, to find blank lines
+	atStart  bool          // nothing has been written in the current block
+
+	flat   bool
+	broken bool
+}
+
+func (p *printer) write(s string) {
+	p.buf.WriteString(s)
+}
+
+func (p *printer) newline() {
+	p.buf.WriteByte('\n')
+}
+
+func (p *printer) writeIndent() {
+	p.write(strings.Repeat(indent, p.depth))
+}
+
+// column returns the length of the line being written.
+func (p *printer) column() int {
+	b := p.buf.Bytes()
+	return len(b) - bytes.LastIndexByte(b, '\n') - 1
+}
+
+// flatten writes what f writes to a new printer in flat mode and returns
+// it, and whether it fits on one line.
+func (p *printer) flatten(f func(q *printer)) (string, bool) {
+	q := &printer{flat: true}
+	f(q)
+	s := q.buf.String()
+	return s, !q.broken && !strings.Contains(s, "\n")
+}
+
+// fits reports whether s fits in the rest of the current line.
+func (p *printer) fits(s string) bool {
+	return p.column()+len(s) <= width
+}
+
+// blankLine writes an empty line if the source line before line is blank,
+// so that single blank lines between statements are kept.
+func (p *printer) blankLine(line int) {
+	if p.atStart || line < 2 || line-2 >= len(p.lines) {
+		return
+	}
+	if strings.TrimSpace(p.lines[line-2]) == "" {
+		p.newline()
+	}
+}
+
+func before(a, b token.Token) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// commentBetween reports whether a comment that has not been written yet
+// lies between from and to.
+func (p *printer) commentBetween(from, to token.Token) bool {
+	for _, c := range p.comments {
+		if before(from, c) && before(c, to) {
+			return true
+		}
+	}
+	return false
+}
+
+// flushComments writes the comments before pos. A comment that followed
+// code in the source is appended to the last line written, the others get
+// lines of their own. It is only called at the start of a line.
+func (p *printer) flushComments(pos token.Token) {
+	for len(p.comments) > 0 && before(p.comments[0], pos) {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		text := strings.TrimRight(c.Literal, " \t\r")
+
+		if p.trailing(c) && bytes.HasSuffix(p.buf.Bytes(), []byte("\n")) {
+			p.buf.Truncate(p.buf.Len() - 1)
+			p.write(" " + text)
+			p.newline()
+			continue
+		}
+
+		p.blankLine(c.Line)
+		p.writeIndent()
+		p.write(text)
+		p.newline()
+		p.atStart = false
+	}
+}
+
+// trailing reports whether comment follows code on its line.
+func (p *printer) trailing(comment token.Token) bool {
+	if comment.Line < 1 || comment.Line > len(p.lines) {
+		return false
+	}
+	line := p.lines[comment.Line-1]
+	return strings.TrimSpace(line[:min(comment.Column-1, len(line))]) != ""
+}
+
+// statements writes the statements of a program or block, one per line.
+// Expression statements are terminated with a semicolon unless they end a
+// block or end with a brace that the next statement cannot continue.
+func (p *printer) statements(stmts []ast.Statement, inBlock bool) {
+	for i, stmt := range stmts {
+		start := statementToken(stmt)
+		if !p.flat {
+			p.flushComments(start)
+			p.blankLine(start.Line)
+			p.writeIndent()
+		} else if i > 0 {
+			p.write(" ")
+		}
+
+		p.statement(stmt)
+
+		if _, ok := stmt.(*ast.ExpressionStatement); ok && p.needsSemicolon(stmts, i, inBlock) {
+			p.write(";")
+		}
+
+		if !p.flat {
+			p.newline()
+			p.atStart = false
+		}
+	}
+}
+
+func (p *printer) needsSemicolon(stmts []ast.Statement, i int, inBlock bool) bool {
+	last := i == len(stmts)-1
+	if last && inBlock {
+		return false
+	}
+	if !bytes.HasSuffix(p.buf.Bytes(), []byte("}")) {
+		return true
+	}
+	if last {
+		return false
+	}
+
+	next, _ := p.flatten(func(q *printer) { q.statement(stmts[i+1]) })
+	return strings.IndexByte("([-", next[0]) >= 0
+}
+
+func statementToken(stmt ast.Statement) token.Token {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token
+	case *ast.ReturnStatement:
+		return stmt.Token
+	case *ast.ExpressionStatement:
+		return stmt.Token
+	case *ast.EnumStatement:
+		return stmt.Token
+	case *ast.ImportStatement:
+		return stmt.Token
+	case *ast.ExportStatement:
+		return stmt.Token
+	}
+	return token.Token{}
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.write("let " + stmt.Name.Value)
+		if stmt.Type != nil {
+			p.write(": " + stmt.Type.String())
+		}
+		p.write(" = ")
+		p.expression(stmt.Value, parser.LOWEST)
+		p.write(";")
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expression(stmt.ReturnValue, parser.LOWEST)
+		p.write(";")
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression, parser.LOWEST)
+	case *ast.EnumStatement:
+		p.write("enum " + stmt.Name.Value + " ")
+		p.list("{ ", " }", stmt.Token, len(stmt.Variants), func(q *printer, i int) {
+			variant := stmt.Variants[i]
+			q.write(variant.Name.Value)
+			if len(variant.Fields) > 0 {
+				q.write("(" + identifiers(variant.Fields) + ")")
+			}
+		}, func(i int) token.Token { return stmt.Variants[i].Name.Token })
+	case *ast.ImportStatement:
+		p.write("import " + quote(stmt.Path.Value))
+		// An alias the parser derived from the path has no position.
+		if stmt.Alias.Token.Line != 0 {
+			p.write(" as " + stmt.Alias.Value)
+		}
+		p.write(";")
+	case *ast.ExportStatement:
+		p.write("export ")
+		p.statement(stmt.Statement)
+	}
+}
+
+// block writes a block on one line if it was on one line in the source,
+// holds at most one statement and fits. Otherwise its statements go on
+// lines of their own.
+func (p *printer) block(block *ast.BlockStatement) {
+	oneLine := len(block.Statements) <= 1 && block.Rbrace.Line == block.Token.Line
+
+	if p.flat {
+		if !oneLine {
+			p.broken = true
+		}
+		if len(block.Statements) == 0 {
+			p.write("{}")
+			return
+		}
+		p.write("{ ")
+		p.statements(block.Statements, true)
+		p.write(" }")
+		return
+	}
+
+	if len(block.Statements) == 0 && !p.commentBetween(block.Token, block.Rbrace) {
+		p.write("{}")
+		return
+	}
+
+	if oneLine {
+		if s, ok := p.flatten(func(q *printer) { q.block(block) }); ok && p.fits(s) {
+			p.write(s)
+			return
+		}
+	}
+
+	p.write("{")
+	p.newline()
+	p.depth++
+	p.atStart = true
+	p.statements(block.Statements, true)
+	p.flushComments(block.Rbrace)
+	p.depth--
+	p.writeIndent()
+	p.write("}")
+}
+
+// list writes n items between open and close: on one line if they fit and
+// no comment lies between them, otherwise one item per line. If only the
+// last item needs several lines, like a function literal passed as the last
+// argument, the others stay on the first line. start returns the first
+// token of an item, for the comments before it.
+func (p *printer) list(open, close string, openToken token.Token, n int, item func(q *printer, i int), start func(i int) token.Token) {
+	if n == 0 {
+		p.write(strings.TrimSpace(open) + strings.TrimSpace(close))
+		return
+	}
+
+	items := func(q *printer, count int) {
+		for i := 0; i < count; i++ {
+			if i > 0 {
+				q.write(", ")
+			}
+			item(q, i)
+		}
+	}
+
+	if p.flat {
+		p.write(open)
+		items(p, n)
+		p.write(close)
+		return
+	}
+
+	if !p.commentBetween(openToken, start(n-1)) {
+		if s, ok := p.flatten(func(q *printer) { q.write(open); items(q, n); q.write(close) }); ok && p.fits(s) {
+			p.write(s)
+			return
+		}
+
+		head, ok := p.flatten(func(q *printer) {
+			q.write(open)
+			items(q, n-1)
+			if n > 1 {
+				q.write(", ")
+			}
+		})
+		_, lastFlat := p.flatten(func(q *printer) { item(q, n-1) })
+		if ok && !lastFlat && p.fits(head) {
+			p.write(head)
+			item(p, n-1)
+			p.write(close)
+			return
+		}
+	}
+
+	p.write(strings.TrimSpace(open))
+	p.newline()
+	p.depth++
+	p.atStart = true
+	for i := 0; i < n; i++ {
+		p.flushComments(start(i))
+		p.writeIndent()
+		item(p, i)
+		if i < n-1 {
+			p.write(",")
+		}
+		p.newline()
+		p.atStart = false
+	}
+	p.depth--
+	p.writeIndent()
+	p.write(strings.TrimSpace(close))
+}
+
+// precedence returns how tightly exp binds, using the parser's precedences.
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(exp.Token.Type)
+	case *ast.PrefixExpression, *ast.SpawnExpression:
+		return parser.PREFIX
+	case *ast.YieldExpression:
+		return parser.LOWEST
+	}
+	return parser.INDEX + 1
+}
+
+// expression writes exp, in parentheses if it binds less tightly than
+// outer.
+func (p *printer) expression(exp ast.Expression, outer int) {
+	if precedence(exp) < outer {
+		p.write("(")
+		defer p.write(")")
+	}
+
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.write(exp.Value)
+	case *ast.IntegerLiteral:
+		p.write(exp.Token.Literal)
+	case *ast.Boolean:
+		p.write(exp.Token.Literal)
+	case *ast.StringLiteral:
+		p.write(quote(exp.Value))
+	case *ast.PrefixExpression:
+		p.write(exp.Operator)
+		p.expression(exp.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		prec := parser.Precedence(exp.Token.Type)
+		p.expression(exp.Left, prec)
+		p.write(" " + exp.Operator + " ")
+		p.expression(exp.Right, prec+1)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expression(exp.Condition, parser.LOWEST)
+		p.write(") ")
+		p.block(exp.Consequence)
+		if exp.Alternative != nil {
+			p.write(" else ")
+			p.block(exp.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		p.write("fn(" + parameters(exp.Parameters, exp.ParameterTypes) + ") ")
+		if exp.ReturnType != nil {
+			p.write("-> " + exp.ReturnType.String() + " ")
+		}
+		p.block(exp.Body)
+	case *ast.MacroLiteral:
+		p.write("macro(" + identifiers(exp.Parameters) + ") ")
+		p.block(exp.Body)
+	case *ast.CallExpression:
+		p.expression(exp.Function, parser.CALL)
+		p.arguments(exp.Token, exp.Arguments)
+	case *ast.MethodCallExpression:
+		p.expression(exp.Receiver, parser.CALL)
+		p.write("." + exp.Method.Value)
+		p.arguments(exp.Token, exp.Arguments)
+	case *ast.MemberExpression:
+		p.expression(exp.Object, parser.CALL)
+		p.write("." + exp.Property.Value)
+	case *ast.IndexExpression:
+		p.expression(exp.Left, parser.CALL)
+		p.write("[")
+		p.expression(exp.Index, parser.LOWEST)
+		p.write("]")
+	case *ast.ArrayLiteral:
+		p.list("[", "]", exp.Token, len(exp.Elements), func(q *printer, i int) {
+			q.expression(exp.Elements[i], parser.LOWEST)
+		}, func(i int) token.Token { return startToken(exp.Elements[i]) })
+	case *ast.HashLiteral:
+		keys := make([]ast.Expression, 0, len(exp.Pairs))
+		for key := range exp.Pairs {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return before(startToken(keys[i]), startToken(keys[j])) })
+
+		p.list("{", "}", exp.Token, len(keys), func(q *printer, i int) {
+			q.expression(keys[i], parser.LOWEST)
+			q.write(": ")
+			q.expression(exp.Pairs[keys[i]], parser.LOWEST)
+		}, func(i int) token.Token { return startToken(keys[i]) })
+	case *ast.YieldExpression:
+		p.write("yield ")
+		p.expression(exp.Value, parser.LOWEST)
+	case *ast.SpawnExpression:
+		p.write("spawn ")
+		p.expression(exp.Call, parser.PREFIX)
+	case *ast.ForExpression:
+		p.write("for (" + exp.Variable.Value + " in ")
+		p.expression(exp.Iterable, parser.LOWEST)
+		p.write(") ")
+		p.block(exp.Body)
+	case *ast.MatchExpression:
+		p.write("match (")
+		p.expression(exp.Subject, parser.LOWEST)
+		p.write(") ")
+		p.arms(len(exp.Arms), func(i int) token.Token { return exp.Arms[i].Variant.Token }, func(i int) {
+			arm := exp.Arms[i]
+			p.write(arm.Variant.Value)
+			if len(arm.Bindings) > 0 {
+				p.write("(" + identifiers(arm.Bindings) + ")")
+			}
+			p.write(" => ")
+			p.body(arm.Body)
+		})
+	case *ast.SelectExpression:
+		p.write("select ")
+		p.arms(len(exp.Cases), func(i int) token.Token { return exp.Cases[i].Token }, func(i int) {
+			c := exp.Cases[i]
+			switch {
+			case c.Value != nil:
+				p.write("send(")
+				p.expression(c.Channel, parser.LOWEST)
+				p.write(", ")
+				p.expression(c.Value, parser.LOWEST)
+				p.write(")")
+			case c.Channel != nil:
+				p.write("receive(")
+				p.expression(c.Channel, parser.LOWEST)
+				p.write(")")
+				if c.Binding != nil {
+					p.write(" as " + c.Binding.Value)
+				}
+			default:
+				p.write("_")
+			}
+			p.write(" => ")
+			p.body(c.Body)
+		})
+	}
+}
+
+func (p *printer) arguments(open token.Token, args []ast.Expression) {
+	p.list("(", ")", open, len(args), func(q *printer, i int) {
+		q.expression(args[i], parser.LOWEST)
+	}, func(i int) token.Token { return startToken(args[i]) })
+}
+
+// arms writes the arms of a match or the cases of a select, which always
+// go on lines of their own.
+func (p *printer) arms(n int, start func(i int) token.Token, arm func(i int)) {
+	if n == 0 {
+		p.write("{}")
+		return
+	}
+	if p.flat {
+		p.broken = true
+	}
+
+	p.write("{")
+	p.newline()
+	p.depth++
+	p.atStart = true
+	for i := 0; i < n; i++ {
+		if !p.flat {
+			p.flushComments(start(i))
+		}
+		p.writeIndent()
+		arm(i)
+		if i < n-1 {
+			p.write(",")
+		}
+		p.newline()
+		p.atStart = false
+	}
+	p.depth--
+	p.writeIndent()
+	p.write("}")
+}
+
+// body writes the body of a match arm or select case. The parser wraps a
+// body written without braces in a block that starts at the expression.
+func (p *printer) body(body *ast.BlockStatement) {
+	if body.Token.Type != token.LBRACE && len(body.Statements) == 1 {
+		if stmt, ok := body.Statements[0].(*ast.ExpressionStatement); ok {
+			// A body starting with a brace would be read as a block.
+			if s, _ := p.flatten(func(q *printer) { q.expression(stmt.Expression, parser.LOWEST) }); !strings.HasPrefix(s, "{") {
+				p.expression(stmt.Expression, parser.LOWEST)
+				return
+			}
+		}
+	}
+	p.block(body)
+}
+
+// startToken returns the leftmost token of exp.
+func startToken(exp ast.Expression) token.Token {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return startToken(exp.Left)
+	case *ast.CallExpression:
+		return startToken(exp.Function)
+	case *ast.MethodCallExpression:
+		return startToken(exp.Receiver)
+	case *ast.MemberExpression:
+		return startToken(exp.Object)
+	case *ast.IndexExpression:
+		return startToken(exp.Left)
+	case *ast.Identifier:
+		return exp.Token
+	case *ast.IntegerLiteral:
+		return exp.Token
+	case *ast.Boolean:
+		return exp.Token
+	case *ast.StringLiteral:
+		return exp.Token
+	case *ast.PrefixExpression:
+		return exp.Token
+	case *ast.IfExpression:
+		return exp.Token
+	case *ast.FunctionLiteral:
+		return exp.Token
+	case *ast.MacroLiteral:
+		return exp.Token
+	case *ast.ArrayLiteral:
+		return exp.Token
+	case *ast.HashLiteral:
+		return exp.Token
+	case *ast.YieldExpression:
+		return exp.Token
+	case *ast.SpawnExpression:
+		return exp.Token
+	case *ast.ForExpression:
+		return exp.Token
+	case *ast.MatchExpression:
+		return exp.Token
+	case *ast.SelectExpression:
+		return exp.Token
+	}
+	return token.Token{}
+}
+
+func identifiers(idents []*ast.Identifier) string {
+	names := make([]string, len(idents))
+	for i, ident := range idents {
+		names[i] = ident.Value
+	}
+	return strings.Join(names, ", ")
+}
+
+// parameters writes the parameters of a function literal with their
+// annotations.
+func parameters(idents []*ast.Identifier, types []ast.Type) string {
+	names := make([]string, len(idents))
+	for i, ident := range idents {
+		names[i] = ident.Value
+		if types != nil && types[i] != nil {
+			names[i] += ": " + types[i].String()
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// quote writes a string literal. Strings have no escapes, so the value is
+// written as it was read.
+func quote(s string) string {
+	return `"` + s + `"`
+}
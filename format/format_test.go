@@ -0,0 +1,214 @@
+package format
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=1+2*3;puts(x)", "let x = 1 + 2 * 3;\nputs(x);\n"},
+		{"let add = fn(a,b){a+b};", "let add = fn(a, b) { a + b };\n"},
+		{"let add = fn(a, b) {\na + b };", "let add = fn(a, b) {\n    a + b\n};\n"},
+		{"let f = fn() { let x = 1; x };", "let f = fn() {\n    let x = 1;\n    x\n};\n"},
+		{"let e = fn() {\n};", "let e = fn() {};\n"},
+		{"(1 + 2) * 3; 1 - (2 - 3); (1 - 2) - 3", "(1 + 2) * 3;\n1 - (2 - 3);\n1 - 2 - 3;\n"},
+		{"-(1 + 2); (-1).x; -a[0]; !(!a)", "-(1 + 2);\n(-1).x;\n-a[0];\n!!a;\n"},
+		{"let g = fn() { (yield 1) + 1 };", "let g = fn() { (yield 1) + 1 };\n"},
+		{"{\"b\": 2, \"a\": 1}", "{\"b\": 2, \"a\": 1}\n"},
+		{"if (x) { 1 } else { 2 }; puts(x);", "if (x) { 1 } else { 2 }\nputs(x);\n"},
+		{"if (x) { 1 }; -1;", "if (x) { 1 };\n-1;\n"},
+		{"if (x) { 1 }", "if (x) { 1 }\n"},
+		{"import \"lib/strings\"; import \"lib/math\" as m; export let y = m.max(1, 2);",
+			"import \"lib/strings\";\nimport \"lib/math\" as m;\nexport let y = m.max(1, 2);\n"},
+		{"enum Shape { Circle(r), Square() };", "enum Shape { Circle(r), Square }\n"},
+		{"match (s) { Circle(r) => r * r, Rect(w, h) => { w * h }, _ => 0 }",
+			"match (s) {\n    Circle(r) => r * r,\n    Rect(w, h) => { w * h },\n    _ => 0\n}\n"},
+		{"select { receive(c) as v => v, send(c, 1) => 1, _ => { 0 } }",
+			"select {\n    receive(c) as v => v,\n    send(c, 1) => 1,\n    _ => { 0 }\n}\n"},
+		{"for (x in [1, 2]) { puts(x) }", "for (x in [1, 2]) { puts(x) }\n"},
+		{"let c = channel(); spawn fn() { send(c, 1) }", "let c = channel();\nspawn fn() { send(c, 1) }\n"},
+		{"let n:int=1; let f = fn(a:[int],b)->{string:bool}{ a };",
+			"let n: int = 1;\nlet f = fn(a: [int], b) -> {string: bool} { a };\n"},
+		{"#!/usr/bin/env monkey\nputs(1)", "#!/usr/bin/env monkey\nputs(1);\n"},
+		{"let m = macro(a) { quote(unquote(a)) };", "let m = macro(a) { quote(unquote(a)) };\n"},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", tt.input, err)
+			continue
+		}
+		if string(formatted) != tt.expected {
+			t.Errorf("%q: wrong output.\nexpected=%q\ngot=%q", tt.input, tt.expected, formatted)
+		}
+	}
+}
+
+func TestLineBreaking(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let xs = [1000000000, 2000000000, 3000000000, 4000000000, 5000000000, 6000000000];",
+			`let xs = [
+    1000000000,
+    2000000000,
+    3000000000,
+    4000000000,
+    5000000000,
+    6000000000
+];
+`,
+		},
+		{
+			"let result = someFunction(argumentNumberOne, argumentNumberTwo, argumentNumberThree);",
+			`let result = someFunction(
+    argumentNumberOne,
+    argumentNumberTwo,
+    argumentNumberThree
+);
+`,
+		},
+		{
+			"let doubled = map(numbers, fn(x) {\nx * 2 });",
+			`let doubled = map(numbers, fn(x) {
+    x * 2
+});
+`,
+		},
+		{
+			"let f = fn() { outer(inner(aaaaaaaaaaaaaaaaaaaa, bbbbbbbbbbbbbbbbbbbb), cccccccccccccccccccc) };",
+			`let f = fn() {
+    outer(
+        inner(aaaaaaaaaaaaaaaaaaaa, bbbbbbbbbbbbbbbbbbbb),
+        cccccccccccccccccccc
+    )
+};
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", tt.input, err)
+			continue
+		}
+		if string(formatted) != tt.expected {
+			t.Errorf("%q: wrong output.\nexpected=%s\ngot=%s", tt.input, tt.expected, formatted)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// Package header.
+
+let x = 1;   // one
+
+
+// Twice applies f two times.
+let twice = fn(f, x) { // body
+  f(f(x)) // inner
+  // end of body
+};
+let config = {"host": "localhost",
+  // the default port
+  "port": 80};
+let todo = fn() {
+  // nothing yet
+};
+// the end
+`
+
+	expected := `// Package header.
+
+let x = 1; // one
+
+// Twice applies f two times.
+let twice = fn(f, x) { // body
+    f(f(x)) // inner
+    // end of body
+};
+let config = {
+    "host": "localhost",
+    // the default port
+    "port": 80
+};
+let todo = fn() {
+    // nothing yet
+};
+// the end
+`
+
+	formatted, err := Source([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if string(formatted) != expected {
+		t.Errorf("wrong output.\nexpected=%s\ngot=%s", expected, formatted)
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	input := `let add = fn(a,b){a+b};
+let twice = fn(f, x) {
+  f(f(x)) };
+let nums = [1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20, 21, 22];
+let total = reduce(nums, 0, fn(acc, x) { acc + x }); // sum
+enum Shape { Circle(r), Rect(w, h) }
+let area = fn(s) { match (s) { Circle(r) => r * r, Rect(w, h) => { w * h } } };
+if (total > 10) { puts("big") } else { puts("small") }
+let g = fn() { let c = channel(); spawn fn() { send(c, 1) }; select { receive(c) as v => v, _ => 0 } };
+let h = {"a": [1, 2], "b": {"c": fn() { 1 }}};
+// done
+`
+
+	once, err := Source([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	twice, err := Source(once)
+	if err != nil {
+		t.Fatalf("formatted output does not parse: %s\n%s", err, once)
+	}
+	if string(once) != string(twice) {
+		t.Errorf("formatting is not idempotent.\nfirst=%s\nsecond=%s", once, twice)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Source([]byte("let = 1;"))
+	if err == nil {
+		t.Fatalf("expected a parse error")
+	}
+	if !strings.HasPrefix(err.Error(), "1:5: expected next token to be IDENT") {
+		t.Errorf("wrong error. got=%q", err)
+	}
+}
+
+func TestIllegalTokens(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = 1 @ 2;`, `1:11: illegal character "@"`},
+		{`let s = "a\"b\n";`, `1:14: illegal character "\\"`},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source([]byte(tt.input))
+		if err == nil {
+			t.Errorf("%q: expected an error. got=%q", tt.input, formatted)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%q: wrong error. expected=%q, got=%q", tt.input, tt.expected, err)
+		}
+	}
+}
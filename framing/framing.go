@@ -0,0 +1,49 @@
+// Package framing reads and writes JSON messages framed by a Content-Length
+// header, the way the language server and debug adapter protocols send
+// them.
+package framing
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// Read reads one message and decodes its content into msg.
+func Read(r *bufio.Reader, msg any) error {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return err
+	}
+
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil {
+		return fmt.Errorf("invalid Content-Length header %q", header.Get("Content-Length"))
+	}
+
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, msg)
+}
+
+// Write writes msg, which is marshalled to JSON, framed by a
+// Content-Length header.
+func Write(w io.Writer, msg any) error {
+	content, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(content)); err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
@@ -0,0 +1,34 @@
+package framing
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, map[string]int{"a": 1}); err != nil {
+		t.Fatalf("Write returned error: %s", err)
+	}
+	if buf.String() != "Content-Length: 7\r\n\r\n{\"a\":1}" {
+		t.Errorf("wrong framing. got=%q", buf.String())
+	}
+
+	var msg map[string]int
+	if err := Read(bufio.NewReader(&buf), &msg); err != nil {
+		t.Fatalf("Read returned error: %s", err)
+	}
+	if msg["a"] != 1 {
+		t.Errorf("wrong message. got=%v", msg)
+	}
+}
+
+func TestInvalidContentLength(t *testing.T) {
+	var msg any
+	err := Read(bufio.NewReader(strings.NewReader("Content-Length: x\r\n\r\n{}")), &msg)
+	if err == nil || err.Error() != `invalid Content-Length header "x"` {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
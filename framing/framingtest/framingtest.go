@@ -0,0 +1,81 @@
+// Package framingtest connects tests to servers that speak framed JSON
+// messages.
+package framingtest
+
+import (
+	"WeekTwo/framing"
+	"bufio"
+	"io"
+	"testing"
+	"time"
+)
+
+// Conn is a client's end of a connection to a server. It reads the
+// server's messages as they arrive, so that the server never blocks on
+// writing while the client writes.
+type Conn[M any] struct {
+	t        *testing.T
+	received chan *M
+	out      io.Writer
+}
+
+// Connect starts serve on one end of a pair of pipes and returns the other
+// end. The client's end is closed when the test ends.
+func Connect[M any](t *testing.T, serve func(in io.Reader, out io.Writer)) *Conn[M] {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	go func() {
+		serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+	t.Cleanup(func() { clientOut.Close() })
+
+	received := make(chan *M, 100)
+	go func() {
+		defer close(received)
+		in := bufio.NewReader(clientIn)
+		for {
+			msg := new(M)
+			if err := framing.Read(in, msg); err != nil {
+				return
+			}
+			received <- msg
+		}
+	}()
+
+	return &Conn[M]{t: t, received: received, out: clientOut}
+}
+
+// Send writes msg to the server.
+func (c *Conn[M]) Send(msg any) {
+	c.t.Helper()
+	if err := framing.Write(c.out, msg); err != nil {
+		c.t.Fatalf("could not send a message: %s", err)
+	}
+}
+
+// Next returns the next message from the server. It fails the test if the
+// connection closes, or if no message arrives shortly before the test's
+// deadline, so that the failure says what the test was waiting for.
+func (c *Conn[M]) Next() *M {
+	c.t.Helper()
+
+	var timeout <-chan time.Time
+	if deadline, ok := c.t.Deadline(); ok {
+		timer := time.NewTimer(time.Until(deadline) * 9 / 10)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case msg, ok := <-c.received:
+		if !ok {
+			c.t.Fatalf("connection closed")
+		}
+		return msg
+	case <-timeout:
+		c.t.Fatalf("timed out waiting for a message")
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package interpreter
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/evaluator"
+	"WeekTwo/lexer"
+	"WeekTwo/object"
+	"WeekTwo/parser"
+	"WeekTwo/resolver"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ParseError is returned when the source given to an Interpreter does not
+// parse.
+type ParseError struct {
+	Errors  []string
+	Details []parser.Error // the errors with their positions
+}
+
+func (e *ParseError) Error() string {
+	return "parse errors: " + strings.Join(e.Errors, "; ")
+}
+
+// RuntimeError is returned when evaluation produces an error object, which
+// is kept in Object.
+type RuntimeError struct {
+	Message string
+	Object  object.Object
+	Stack   []object.StackFrame
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Message
+}
+
+// Traceback formats the call stack of the error, most recent call last.
+func (e *RuntimeError) Traceback() string {
+	return (&object.Error{Message: e.Message, Stack: e.Stack}).Traceback()
+}
+
+// Interpreter runs Monkey programs against a persistent global environment.
+// Functions and values registered with the interpreter are visible to every
+// program it runs and to the modules those programs import, but not to
+// other interpreters. Modules are evaluated in environments enclosed by the
+// global one, so they also see its globals and settings.
+type Interpreter struct {
+	host     *object.Environment
+	globals  *object.Environment
+	macros   *object.Environment
+	modules  *evaluator.ModuleResolver
+	scopes   *resolver.Resolver
+	enums    map[string][]string
+	warnings []string
+}
+
+// New returns an interpreter whose imports are resolved against searchPath
+// after the importing file's directory.
+func New(searchPath ...string) *Interpreter {
+	host := object.NewEnvironment()
+
+	globals := object.NewEnclosedEnvironment(host)
+
+	modules := evaluator.NewModuleResolver(searchPath...)
+	modules.Globals = globals
+	globals.SetImporter(modules)
+
+	return &Interpreter{
+		host:    host,
+		globals: globals,
+		macros:  object.NewEnvironment(),
+		modules: modules,
+		scopes:  resolver.New(),
+		enums:   make(map[string][]string),
+	}
+}
+
+// SetIO sets the streams used by builtins such as puts and readline. By
+// default they use the process's standard streams.
+func (i *Interpreter) SetIO(stdin io.Reader, stdout, stderr io.Writer) {
+	i.host.SetIO(object.NewIO(stdin, stdout, stderr))
+}
+
+// SetTracer makes tracer follow the programs and modules the interpreter
+// runs, for example to debug them.
+func (i *Interpreter) SetTracer(tracer object.Tracer) {
+	i.host.SetTracer(tracer)
+}
+
+// SetStrict turns the run-time checking of type annotations on or off.
+// Without it annotations are ignored.
+func (i *Interpreter) SetStrict(strict bool) {
+	i.host.SetStrict(strict)
+}
+
+// RegisterFunction makes fn callable under name.
+func (i *Interpreter) RegisterFunction(name string, fn object.BuiltinFunction) {
+	i.host.Set(name, &object.Builtin{Fn: fn})
+}
+
+// RegisterValue makes value available under name.
+func (i *Interpreter) RegisterValue(name string, value object.Object) {
+	i.host.Set(name, value)
+}
+
+// Register converts v with ToObject and makes it available under name.
+// Go funcs become callable builtins.
+func (i *Interpreter) Register(name string, v any) error {
+	obj, err := ToObject(v)
+	if err != nil {
+		return err
+	}
+	i.host.Set(name, obj)
+	return nil
+}
+
+// Global returns the value bound to name by a program or by the host.
+func (i *Interpreter) Global(name string) (object.Object, bool) {
+	return i.globals.Get(name)
+}
+
+// SetGlobal binds name in the global environment, as a let statement at the
+// top level of a program would.
+func (i *Interpreter) SetGlobal(name string, value object.Object) {
+	i.globals.Set(name, value)
+}
+
+// Run evaluates source and returns the value of its last statement. A
+// program that uses a name nothing defines is rejected with a *ParseError
+// before it runs.
+func (i *Interpreter) Run(source string) (object.Object, error) {
+	return i.RunContext(context.Background(), source, evaluator.Options{})
+}
+
+// RunContext evaluates source like Run, within the limits of options and
+// until ctx is done.
+func (i *Interpreter) RunContext(ctx context.Context, source string, options evaluator.Options) (object.Object, error) {
+	program, err := i.parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	return result(evaluator.EvalContext(ctx, program, i.globals, options))
+}
+
+// RunFile evaluates the program in the file at path. Imports in the
+// program are resolved relative to the file's directory.
+func (i *Interpreter) RunFile(path string) (object.Object, error) {
+	return i.RunFileContext(context.Background(), path, evaluator.Options{})
+}
+
+// RunFileContext evaluates the program in the file at path like RunFile,
+// within the limits of options and until ctx is done. The limits cover the
+// modules the program imports.
+func (i *Interpreter) RunFileContext(ctx context.Context, path string, options evaluator.Options) (object.Object, error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	program, err := i.parse(string(source))
+	if err != nil {
+		return nil, err
+	}
+
+	previous := i.globals.File()
+	i.globals.SetFile(abs)
+	defer i.globals.SetFile(previous)
+
+	return result(evaluator.EvalContext(ctx, program, i.globals, options))
+}
+
+// Warnings returns the warnings the parser found in the program last run,
+// such as matches that do not cover every variant of an enum.
+func (i *Interpreter) Warnings() []string {
+	return i.warnings
+}
+
+func (i *Interpreter) parse(source string) (ast.Node, error) {
+	p := parser.New(lexer.New(source))
+	// Enums declared by earlier programs are still defined.
+	for name, variants := range i.enums {
+		p.DeclareEnum(name, variants)
+	}
+	program := p.ParseProgram()
+	i.warnings = p.Warnings()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors(), Details: p.ErrorDetails()}
+	}
+	for name, variants := range p.Enums() {
+		i.enums[name] = variants
+	}
+
+	evaluator.DefineMacros(program, i.macros)
+	expanded, err := evaluator.ExpandMacros(program, i.macros)
+	if err != nil {
+		return nil, &RuntimeError{Message: err.Message, Object: err}
+	}
+
+	// Names the host bound since the last program are not known to the
+	// resolver yet.
+	for _, env := range []*object.Environment{i.host, i.globals} {
+		for name := range env.Bindings() {
+			i.scopes.Declare(name)
+		}
+	}
+	if diagnostics := i.scopes.Resolve(expanded.(*ast.Program)); resolver.HasErrors(diagnostics) {
+		parseErr := &ParseError{}
+		for _, d := range diagnostics {
+			if d.Severity == resolver.Error {
+				parseErr.Errors = append(parseErr.Errors, d.Message)
+				parseErr.Details = append(parseErr.Details, parser.Error{Message: d.Message, Token: d.Token})
+			}
+		}
+		return nil, parseErr
+	}
+
+	return expanded, nil
+}
+
+func result(obj object.Object) (object.Object, error) {
+	switch obj := obj.(type) {
+	case *object.Error:
+		return nil, &RuntimeError{Message: obj.Message, Object: obj, Stack: obj.Stack}
+	case *object.LimitError:
+		return nil, &RuntimeError{Message: obj.Message, Object: obj}
+	case nil:
+		return evaluator.NULL, nil
+	}
+
+	return obj, nil
+}
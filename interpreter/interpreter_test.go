@@ -0,0 +1,220 @@
+package interpreter
+
+import (
+	"WeekTwo/evaluator"
+	"WeekTwo/object"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	interp := New()
+
+	result, err := interp.Run(`let add = fn(a, b) { a + b }; add(1, 2)`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testInteger(t, result, 3)
+
+	result, err = interp.Run(`add(40, 2)`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testInteger(t, result, 42)
+}
+
+func TestRegisterFunction(t *testing.T) {
+	interp := New()
+	interp.RegisterFunction("double", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	})
+	interp.RegisterValue("answer", &object.Integer{Value: 21})
+
+	result, err := interp.Run(`double(answer)`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testInteger(t, result, 42)
+
+	_, err = New().Run(`double(1)`)
+	if err == nil || err.Error() != "parse errors: identifier not found: double" {
+		t.Errorf("host function leaked into another interpreter. err=%v", err)
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	interp := New()
+	interp.SetGlobal("x", &object.Integer{Value: 5})
+
+	if _, err := interp.Run(`let y = x * 2;`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	y, ok := interp.Global("y")
+	if !ok {
+		t.Fatalf("global y not defined")
+	}
+	testInteger(t, y, 10)
+
+	if _, ok := interp.Global("z"); ok {
+		t.Errorf("global z should not be defined")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	interp := New()
+
+	_, err := interp.Run(`let = 5;`)
+	var parseErr *ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected *ParseError. got=%T (%v)", err, err)
+	}
+	if len(parseErr.Details) != len(parseErr.Errors) || parseErr.Details[0].Token.Line != 1 || parseErr.Details[0].Token.Column != 5 {
+		t.Errorf("wrong error details. got=%+v", parseErr.Details)
+	}
+
+	_, err = interp.Run(`let f = fn() { if (false) { typo } };`)
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected *ParseError. got=%T (%v)", err, err)
+	}
+	if len(parseErr.Details) != 1 || parseErr.Details[0].Message != "identifier not found: typo" || parseErr.Details[0].Token.Column != 29 {
+		t.Errorf("wrong error details. got=%+v", parseErr.Details)
+	}
+
+	interp.RegisterValue("later", &object.Integer{Value: 1})
+	if _, err := interp.Run(`later + 1`); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	_, err = interp.Run(`5 + true`)
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected *RuntimeError. got=%T (%v)", err, err)
+	}
+	if runtimeErr.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong message. got=%q", runtimeErr.Message)
+	}
+
+	_, err = interp.RunContext(context.Background(), `let f = fn() { f() }; f()`, evaluator.Options{MaxSteps: 1000})
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected *RuntimeError. got=%T (%v)", err, err)
+	}
+	if _, ok := runtimeErr.Object.(*object.LimitError); !ok {
+		t.Errorf("expected *object.LimitError. got=%T", runtimeErr.Object)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "lib.mk"), `export let scaled = fn(x) { x * factor };`)
+	writeFile(t, filepath.Join(dir, "main.mk"), `import "lib"; lib.scaled(7)`)
+
+	interp := New()
+	interp.RegisterValue("factor", &object.Integer{Value: 6})
+
+	result, err := interp.RunFile(filepath.Join(dir, "main.mk"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	testInteger(t, result, 42)
+}
+
+func TestRunFileContext(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "spin.mk"), `let spin = fn() { spin() }; spin();`)
+	writeFile(t, filepath.Join(dir, "main.mk"), `import "spin"; 1`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := New().RunFileContext(ctx, filepath.Join(dir, "main.mk"), evaluator.Options{MaxSteps: 1000})
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected *RuntimeError. got=%T (%v)", err, err)
+	}
+	limitErr, ok := runtimeErr.Object.(*object.LimitError)
+	if !ok || limitErr.Limit != evaluator.StepLimit {
+		t.Errorf("expected a step limit error. got=%T (%v)", runtimeErr.Object, runtimeErr.Object)
+	}
+}
+
+func TestModulesSeeGlobals(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "lib.mk"), `export let greeting = "hello " + name;`)
+	writeFile(t, filepath.Join(dir, "main.mk"), `import "lib"; lib.greeting`)
+
+	interp := New()
+	interp.SetGlobal("name", &object.String{Value: "world"})
+
+	result, err := interp.RunFile(filepath.Join(dir, "main.mk"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if result.Inspect() != "hello world" {
+		t.Errorf("wrong result. got=%q", result.Inspect())
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testInteger(t *testing.T, obj object.Object, expected int64) {
+	t.Helper()
+	integer, ok := obj.(*object.Integer)
+	if !ok {
+		t.Fatalf("object is not Integer. got=%T (%+v)", obj, obj)
+	}
+	if integer.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", integer.Value, expected)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	interp := New()
+	if _, err := interp.Run(`enum Light { Red, Green };`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if _, err := interp.Run(`match (Red) { Red => 1 }`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	warnings := interp.Warnings()
+	if len(warnings) != 1 || warnings[0] != "non-exhaustive match on Light: missing Green" {
+		t.Errorf("wrong warnings. got=%q", warnings)
+	}
+}
+
+func TestSetIO(t *testing.T) {
+	var out strings.Builder
+	interp := New()
+	interp.SetIO(strings.NewReader("world\n"), &out, &out)
+
+	if _, err := interp.Run(`puts("hello " + readline())`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if out.String() != "hello world\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
+
+func TestSetStrict(t *testing.T) {
+	interp := New()
+	if _, err := interp.Run(`let x: int = "five";`); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	interp.SetStrict(true)
+	_, err := interp.Run(`let y: int = "five";`)
+	if err == nil || err.Error() != "type error: cannot assign STRING to y of type int" {
+		t.Errorf("expected a type error. got=%v", err)
+	}
+}
@@ -0,0 +1,427 @@
+package interpreter
+
+import (
+	"WeekTwo/evaluator"
+	"WeekTwo/object"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// TagName is the struct tag that renames a field when a struct is
+// converted to or from a hash. A tag of "-" skips the field.
+const TagName = "monkey"
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ToObject converts a Go value to a Monkey object. Integers, strings and
+// booleans map to their Monkey counterparts, slices and arrays to arrays,
+// maps and structs to hashes, and funcs to builtins. Monkey has no floats,
+// so a float converts only if it holds a whole number.
+func ToObject(v any) (object.Object, error) {
+	if v == nil {
+		return evaluator.NULL, nil
+	}
+	return toObject(reflect.ValueOf(v))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	if v.Type().Implements(objectType) && v.CanInterface() {
+		if obj, ok := v.Interface().(object.Object); ok && obj != nil {
+			return obj, nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("cannot convert %d to INTEGER: out of range", v.Uint())
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return nil, fmt.Errorf("cannot convert %v to INTEGER", f)
+		}
+		return &object.Integer{Value: int64(f)}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			el, err := toObject(v.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %w", i, err)
+			}
+			elements[i] = el
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := toObject(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			value, err := toObject(iter.Value())
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", key.Inspect(), err)
+			}
+			if err := setPair(hash, key, value); err != nil {
+				return nil, err
+			}
+		}
+		return hash, nil
+	case reflect.Struct:
+		hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
+		for _, field := range structFields(v.Type()) {
+			// A field promoted through a nil embedded pointer has no
+			// value.
+			fieldValue, err := v.FieldByIndexErr(field.index)
+			if err != nil {
+				setPair(hash, &object.String{Value: field.name}, evaluator.NULL)
+				continue
+			}
+			value, err := toObject(fieldValue)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.name, err)
+			}
+			setPair(hash, &object.String{Value: field.name}, value)
+		}
+		return hash, nil
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return toObject(v.Elem())
+	case reflect.Func:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return wrapFunc(v), nil
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to a Monkey object", v.Type())
+}
+
+func setPair(hash *object.Hash, key, value object.Object) error {
+	hashable, ok := key.(object.Hashable)
+	if !ok {
+		return fmt.Errorf("unusable as hash key: %s", key.Type())
+	}
+	hash.Pairs[hashable.HashKey()] = object.HashPair{Key: key, Value: value}
+	return nil
+}
+
+// FromObject stores the Go equivalent of obj in *out, following the
+// mappings of ToObject in reverse. Storing into an `any` produces int64,
+// string, bool, nil, []any and map[any]any values.
+func FromObject[T any](obj object.Object, out *T) error {
+	return fromObject(obj, reflect.ValueOf(out).Elem())
+}
+
+func fromObject(obj object.Object, v reflect.Value) error {
+	if obj == nil {
+		obj = evaluator.NULL
+	}
+
+	if v.Kind() == reflect.Interface && v.NumMethod() == 0 {
+		value, err := natural(obj)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(value))
+		}
+		return nil
+	}
+
+	if reflect.TypeOf(obj).AssignableTo(v.Type()) {
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	if obj.Type() == object.NULL_OBJ {
+		switch v.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface, reflect.Func:
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		ptr := reflect.New(v.Type().Elem())
+		if err := fromObject(obj, ptr.Elem()); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	case reflect.Bool:
+		if b, ok := obj.(*object.Boolean); ok {
+			v.SetBool(b.Value)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if integer, ok := obj.(*object.Integer); ok {
+			if v.OverflowInt(integer.Value) {
+				return fmt.Errorf("cannot convert %d to %s: out of range", integer.Value, v.Type())
+			}
+			v.SetInt(integer.Value)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if integer, ok := obj.(*object.Integer); ok {
+			if integer.Value < 0 || v.OverflowUint(uint64(integer.Value)) {
+				return fmt.Errorf("cannot convert %d to %s: out of range", integer.Value, v.Type())
+			}
+			v.SetUint(uint64(integer.Value))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if integer, ok := obj.(*object.Integer); ok {
+			v.SetFloat(float64(integer.Value))
+			return nil
+		}
+	case reflect.String:
+		if str, ok := obj.(*object.String); ok {
+			v.SetString(str.Value)
+			return nil
+		}
+	case reflect.Slice, reflect.Array:
+		array, ok := obj.(*object.Array)
+		if !ok {
+			break
+		}
+		if v.Kind() == reflect.Slice {
+			v.Set(reflect.MakeSlice(v.Type(), len(array.Elements), len(array.Elements)))
+		} else if v.Len() != len(array.Elements) {
+			return fmt.Errorf("cannot convert ARRAY of length %d to %s", len(array.Elements), v.Type())
+		}
+		for i, el := range array.Elements {
+			if err := fromObject(el, v.Index(i)); err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+		}
+		return nil
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			break
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(hash.Pairs))
+		for _, pair := range hash.Pairs {
+			key := reflect.New(v.Type().Key()).Elem()
+			if err := fromObject(pair.Key, key); err != nil {
+				return err
+			}
+			value := reflect.New(v.Type().Elem()).Elem()
+			if err := fromObject(pair.Value, value); err != nil {
+				return fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+		return nil
+	case reflect.Struct:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			break
+		}
+		for _, field := range structFields(v.Type()) {
+			key := (&object.String{Value: field.name}).HashKey()
+			pair, ok := hash.Pairs[key]
+			if !ok {
+				continue
+			}
+			fieldValue, ok := settableField(v, field.index)
+			if !ok {
+				continue
+			}
+			if err := fromObject(pair.Value, fieldValue); err != nil {
+				return fmt.Errorf("field %s: %w", field.name, err)
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("cannot convert %s to %s", obj.Type(), v.Type())
+}
+
+// natural returns the plainest Go value for obj.
+func natural(obj object.Object) (any, error) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return obj.Value, nil
+	case *object.String:
+		return obj.Value, nil
+	case *object.Boolean:
+		return obj.Value, nil
+	case *object.NULL:
+		return nil, nil
+	case *object.Array:
+		elements := make([]any, len(obj.Elements))
+		for i, el := range obj.Elements {
+			value, err := natural(el)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = value
+		}
+		return elements, nil
+	case *object.Hash:
+		m := make(map[any]any, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, err := natural(pair.Key)
+			if err != nil {
+				return nil, err
+			}
+			value, err := natural(pair.Value)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	}
+
+	return obj, nil
+}
+
+type structField struct {
+	name  string
+	index []int
+}
+
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		name := f.Name
+		if tag, ok := f.Tag.Lookup(TagName); ok {
+			tag, _, _ = strings.Cut(tag, ",")
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+
+		fields = append(fields, structField{name: name, index: f.Index})
+	}
+
+	return fields
+}
+
+// settableField returns the field of the struct v at index. Nil pointers
+// to embedded structs on the way to a promoted field are set to new
+// structs, unless they cannot be set, in which case there is no field.
+func settableField(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// wrapFunc turns a Go func into a builtin that converts its arguments with
+// FromObject and its results with ToObject. A non-nil error returned as the
+// last result becomes an error object, and so does a panic.
+func wrapFunc(fn reflect.Value) *object.Builtin {
+	t := fn.Type()
+
+	return &object.Builtin{Fn: func(args ...object.Object) (result object.Object) {
+		defer func() {
+			if r := recover(); r != nil {
+				result = &object.Error{Message: fmt.Sprintf("panic: %v", r)}
+			}
+		}()
+
+		numIn := t.NumIn()
+		if (!t.IsVariadic() && len(args) != numIn) || (t.IsVariadic() && len(args) < numIn-1) {
+			return &object.Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%d", len(args), numIn)}
+		}
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var paramType reflect.Type
+			if t.IsVariadic() && i >= numIn-1 {
+				paramType = t.In(numIn - 1).Elem()
+			} else {
+				paramType = t.In(i)
+			}
+
+			in[i] = reflect.New(paramType).Elem()
+			if err := fromObject(arg, in[i]); err != nil {
+				return &object.Error{Message: fmt.Sprintf("argument %d: %s", i+1, err)}
+			}
+		}
+
+		out := fn.Call(in)
+
+		if len(out) > 0 && t.Out(len(out)-1) == errorType {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return &object.Error{Message: err.Error()}
+			}
+			out = out[:len(out)-1]
+		}
+
+		switch len(out) {
+		case 0:
+			return evaluator.NULL
+		case 1:
+			result, err := toObject(out[0])
+			if err != nil {
+				return &object.Error{Message: err.Error()}
+			}
+			return result
+		}
+
+		results, err := toObject(reflect.ValueOf(valuesToInterfaces(out)))
+		if err != nil {
+			return &object.Error{Message: err.Error()}
+		}
+		return results
+	}}
+}
+
+func valuesToInterfaces(values []reflect.Value) []any {
+	result := make([]any, len(values))
+	for i, v := range values {
+		result[i] = v.Interface()
+	}
+	return result
+}
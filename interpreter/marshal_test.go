@@ -0,0 +1,189 @@
+package interpreter
+
+import (
+	"WeekTwo/object"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type point struct {
+	X      int    `monkey:"x"`
+	Y      int    `monkey:"y"`
+	Label  string `monkey:"label,omitempty"`
+	Secret string `monkey:"-"`
+	hidden int
+}
+
+type Inner struct {
+	A int `monkey:"a"`
+}
+
+type outer struct {
+	*Inner
+	B int `monkey:"b"`
+}
+
+func TestToObject(t *testing.T) {
+	tests := []struct {
+		input    any
+		expected string
+	}{
+		{42, "42"},
+		{uint8(7), "7"},
+		{3.0, "3"},
+		{"hi", "hi"},
+		{true, "true"},
+		{nil, "NULL"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]string{"a", "b"}, "[a, b]"},
+		{map[string]int{"a": 1}, "{a: 1}"},
+		{point{X: 1, Y: 2, Secret: "s"}, ""},
+		{&object.Integer{Value: 9}, "9"},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		if err != nil {
+			t.Errorf("ToObject(%#v) returned error: %s", tt.input, err)
+			continue
+		}
+		if tt.expected != "" && obj.Inspect() != tt.expected {
+			t.Errorf("ToObject(%#v) wrong. got=%q, want=%q", tt.input, obj.Inspect(), tt.expected)
+		}
+	}
+
+	obj, _ := ToObject(point{X: 1, Y: 2, Secret: "s"})
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		t.Fatalf("struct did not convert to *object.Hash. got=%T", obj)
+	}
+	if len(hash.Pairs) != 3 {
+		t.Errorf("hash has wrong number of pairs. got=%d, want=3", len(hash.Pairs))
+	}
+	pair, ok := hash.Pairs[(&object.String{Value: "x"}).HashKey()]
+	if !ok || pair.Value.Inspect() != "1" {
+		t.Errorf("field x not converted under its tag name")
+	}
+
+	if _, err := ToObject(1.5); err == nil {
+		t.Errorf("expected an error converting 1.5")
+	}
+
+	obj, err := ToObject(outer{B: 1})
+	if err != nil {
+		t.Fatalf("ToObject returned error: %s", err)
+	}
+	pair, ok = obj.(*object.Hash).Pairs[(&object.String{Value: "a"}).HashKey()]
+	if !ok || pair.Value.Inspect() != "NULL" {
+		t.Errorf("field promoted through a nil pointer not converted to NULL")
+	}
+}
+
+func TestFromObject(t *testing.T) {
+	interp := New()
+	result, err := interp.Run(`{"x": 3, "y": 4, "label": "p", "extra": true}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var p point
+	if err := FromObject(result, &p); err != nil {
+		t.Fatalf("FromObject returned error: %s", err)
+	}
+	if p != (point{X: 3, Y: 4, Label: "p"}) {
+		t.Errorf("wrong struct. got=%+v", p)
+	}
+
+	var numbers []int64
+	if err := FromObject(&object.Array{Elements: []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}}}, &numbers); err != nil {
+		t.Fatalf("FromObject returned error: %s", err)
+	}
+	if !reflect.DeepEqual(numbers, []int64{1, 2}) {
+		t.Errorf("wrong slice. got=%v", numbers)
+	}
+
+	var anything any
+	if err := FromObject(&object.String{Value: "s"}, &anything); err != nil || anything != "s" {
+		t.Errorf("wrong value for any. got=%v (%v)", anything, err)
+	}
+
+	var small int8
+	if err := FromObject(&object.Integer{Value: 1000}, &small); err == nil {
+		t.Errorf("expected an out of range error")
+	}
+
+	result, err = interp.Run(`{"a": 2, "b": 3}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	var o outer
+	if err := FromObject(result, &o); err != nil {
+		t.Fatalf("FromObject returned error: %s", err)
+	}
+	if o.Inner == nil || o.A != 2 || o.B != 3 {
+		t.Errorf("wrong struct. got=%+v", o)
+	}
+
+	var s string
+	if err := FromObject(&object.Integer{Value: 1}, &s); err == nil || err.Error() != "cannot convert INTEGER to string" {
+		t.Errorf("wrong error. got=%v", err)
+	}
+}
+
+func TestGoFunctions(t *testing.T) {
+	interp := New()
+	interp.Register("add", func(a, b int) int { return a + b })
+	interp.Register("sum", func(xs ...int) int {
+		total := 0
+		for _, x := range xs {
+			total += x
+		}
+		return total
+	})
+	interp.Register("check", func(p point) (string, error) {
+		if p.X < 0 {
+			return "", errors.New("negative x")
+		}
+		return p.Label, nil
+	})
+	interp.Register("boom", func() int { panic("boom") })
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`add(1, 2)`, "3"},
+		{`sum(1, 2, 3, 4)`, "10"},
+		{`sum()`, "0"},
+		{`check({"x": 1, "label": "ok"})`, "ok"},
+	}
+
+	for _, tt := range tests {
+		result, err := interp.Run(tt.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.input, err)
+			continue
+		}
+		if result.Inspect() != tt.expected {
+			t.Errorf("%s: got=%q, want=%q", tt.input, result.Inspect(), tt.expected)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`check({"x": -1})`, "negative x"},
+		{`add(1)`, "wrong number of arguments. got=1, want=2"},
+		{`add(1, "two")`, "argument 2: cannot convert STRING to int"},
+		{`boom()`, "panic: boom"},
+	}
+
+	for _, tt := range errorTests {
+		_, err := interp.Run(tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: wrong error. got=%v, want=%q", tt.input, err, tt.expected)
+		}
+	}
+}
@@ -7,11 +7,21 @@ type Lexer struct {
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
 	char         byte // current char under examination
+	line         int  // line of the current char, starting at 1
+	column       int  // column of the current char, starting at 1
+
+	comments     []token.Token // the // comments skipped so far
+	unterminated bool          // whether a string ran into the end of input
 }
 
 func New(input string) *Lexer {
-	lex := &Lexer{input: input}
+	lex := &Lexer{input: input, line: 1}
 	lex.readChar()
+	// A #! line lets scripts be run directly. It is kept as a comment so
+	// that the formatter does not drop it.
+	if lex.char == '#' && lex.peekChar() == '!' {
+		lex.readComment()
+	}
 	return lex
 }
 
@@ -20,6 +30,12 @@ func newToken(tokenType token.TokenType, ch byte) token.Token {
 }
 
 func (lex *Lexer) readChar() {
+	if lex.char == '\n' {
+		lex.line++
+		lex.column = 0
+	}
+	lex.column++
+
 	if lex.readPosition >= len(lex.input) {
 		lex.char = 0
 	} else {
@@ -53,10 +69,40 @@ func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+// skipWhitespace skips whitespace and comments, recording the comments.
 func (lex *Lexer) skipWhitespace() {
-	for lex.char == ' ' || lex.char == '\t' || lex.char == '\n' || lex.char == '\r' {
+	for {
+		switch {
+		case lex.char == ' ' || lex.char == '\t' || lex.char == '\n' || lex.char == '\r':
+			lex.readChar()
+		case lex.char == '/' && lex.peekChar() == '/':
+			lex.readComment()
+		default:
+			return
+		}
+	}
+}
+
+func (lex *Lexer) readComment() {
+	tok := token.Token{Type: token.COMMENT, Line: lex.line, Column: lex.column}
+	position := lex.position
+	for lex.char != '\n' && lex.char != 0 {
 		lex.readChar()
 	}
+	tok.Literal = lex.input[position:lex.position]
+	lex.comments = append(lex.comments, tok)
+}
+
+// Comments returns the comments skipped so far, in order. The parser
+// ignores them; tools such as the formatter use them to keep comments.
+func (lex *Lexer) Comments() []token.Token {
+	return lex.comments
+}
+
+// Unterminated reports whether a string read so far was not closed before
+// the end of the input.
+func (lex *Lexer) Unterminated() bool {
+	return lex.unterminated
 }
 
 func (lex *Lexer) peekChar() byte {
@@ -71,7 +117,11 @@ func (lex *Lexer) readString() string {
 	position := lex.position + 1
 	for {
 		lex.readChar()
-		if lex.char == '"' || lex.char == 0 {
+		if lex.char == '"' {
+			break
+		}
+		if lex.char == 0 {
+			lex.unterminated = true
 			break
 		}
 	}
@@ -83,6 +133,8 @@ func (lex *Lexer) NextToken() token.Token {
 
 	lex.skipWhitespace()
 
+	line, column := lex.line, lex.column
+
 	switch lex.char {
 	case '"':
 		tok.Type = token.STRING
@@ -92,6 +144,10 @@ func (lex *Lexer) NextToken() token.Token {
 			ch := lex.char
 			lex.readChar()
 			tok = token.Token{Type: token.EQ, Literal: string(ch) + string(lex.char)}
+		} else if lex.peekChar() == '>' {
+			ch := lex.char
+			lex.readChar()
+			tok = token.Token{Type: token.ARROW, Literal: string(ch) + string(lex.char)}
 		} else {
 			tok = newToken(token.ASSIGN, lex.char)
 		}
@@ -99,6 +155,8 @@ func (lex *Lexer) NextToken() token.Token {
 		tok = newToken(token.SEMICOLON, lex.char)
 	case ':':
 		tok = newToken(token.COLON, lex.char)
+	case '.':
+		tok = newToken(token.DOT, lex.char)
 	case '(':
 		tok = newToken(token.LPAREN, lex.char)
 	case ')':
@@ -112,7 +170,13 @@ func (lex *Lexer) NextToken() token.Token {
 	case '+':
 		tok = newToken(token.PLUS, lex.char)
 	case '-':
-		tok = newToken(token.MINUS, lex.char)
+		if lex.peekChar() == '>' {
+			ch := lex.char
+			lex.readChar()
+			tok = token.Token{Type: token.RARROW, Literal: string(ch) + string(lex.char)}
+		} else {
+			tok = newToken(token.MINUS, lex.char)
+		}
 	case '*':
 		tok = newToken(token.ASTERISK, lex.char)
 	case '/':
@@ -140,15 +204,18 @@ func (lex *Lexer) NextToken() token.Token {
 		if isLetter(lex.char) {
 			tok.Literal = lex.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Line, tok.Column = line, column
 			return tok
 		} else if isDigit(lex.char) {
 			tok.Type = token.INT
 			tok.Literal = lex.readNumber()
+			tok.Line, tok.Column = line, column
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, lex.char)
 		}
 	}
+	tok.Line, tok.Column = line, column
 	lex.readChar()
 	return tok
 }
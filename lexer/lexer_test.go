@@ -30,6 +30,11 @@ func TestNextToken(testInput *testing.T) {
 	"foo bar"
 	[1, 2];
 	:
+	enum Shape { Circle(r) }
+	match (s) { Circle(r) => r }
+	import "lib/strings" as s;
+	export let x = s.upper;
+	fn(a: int) -> bool
 	`
 
 	tests := []struct {
@@ -118,6 +123,47 @@ func TestNextToken(testInput *testing.T) {
 		{token.RBRACKET, "]"},
 		{token.SEMICOLON, ";"},
 		{token.COLON, ":"},
+		{token.ENUM, "enum"},
+		{token.IDENT, "Shape"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "Circle"},
+		{token.LPAREN, "("},
+		{token.IDENT, "r"},
+		{token.RPAREN, ")"},
+		{token.RBRACE, "}"},
+		{token.MATCH, "match"},
+		{token.LPAREN, "("},
+		{token.IDENT, "s"},
+		{token.RPAREN, ")"},
+		{token.LBRACE, "{"},
+		{token.IDENT, "Circle"},
+		{token.LPAREN, "("},
+		{token.IDENT, "r"},
+		{token.RPAREN, ")"},
+		{token.ARROW, "=>"},
+		{token.IDENT, "r"},
+		{token.RBRACE, "}"},
+		{token.IMPORT, "import"},
+		{token.STRING, "lib/strings"},
+		{token.AS, "as"},
+		{token.IDENT, "s"},
+		{token.SEMICOLON, ";"},
+		{token.EXPORT, "export"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "s"},
+		{token.DOT, "."},
+		{token.IDENT, "upper"},
+		{token.SEMICOLON, ";"},
+		{token.FUNCTION, "fn"},
+		{token.LPAREN, "("},
+		{token.IDENT, "a"},
+		{token.COLON, ":"},
+		{token.IDENT, "int"},
+		{token.RPAREN, ")"},
+		{token.RARROW, "->"},
+		{token.IDENT, "bool"},
 		{token.EOF, ""},
 	}
 
@@ -137,3 +183,122 @@ func TestNextToken(testInput *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(testInput *testing.T) {
+	input := `let x = 5;
+  add(x,
+	"two")
+`
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"5", 1, 9},
+		{";", 1, 10},
+		{"add", 2, 3},
+		{"(", 2, 6},
+		{"x", 2, 7},
+		{",", 2, 8},
+		{"two", 3, 2},
+		{")", 3, 7},
+		{"", 4, 1},
+	}
+
+	lexer := New(input)
+
+	for i, tt := range tests {
+		tok := lexer.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			testInput.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Line != tt.expectedLine || tok.Column != tt.expectedColumn {
+			testInput.Fatalf("tests[%d] - position wrong. expected=%d:%d, got=%d:%d",
+				i, tt.expectedLine, tt.expectedColumn, tok.Line, tok.Column)
+		}
+	}
+}
+
+func TestComments(testInput *testing.T) {
+	input := `// leading
+let x = 10 / 2; // trailing
+"not // a comment"
+//`
+
+	expectedTokens := []string{"let", "x", "=", "10", "/", "2", ";", "not // a comment", ""}
+
+	lexer := New(input)
+	for i, expected := range expectedTokens {
+		tok := lexer.NextToken()
+		if tok.Literal != expected {
+			testInput.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, expected, tok.Literal)
+		}
+	}
+
+	expectedComments := []token.Token{
+		{Type: token.COMMENT, Literal: "// leading", Line: 1, Column: 1},
+		{Type: token.COMMENT, Literal: "// trailing", Line: 2, Column: 17},
+		{Type: token.COMMENT, Literal: "//", Line: 4, Column: 1},
+	}
+
+	comments := lexer.Comments()
+	if len(comments) != len(expectedComments) {
+		testInput.Fatalf("wrong number of comments. expected=%d, got=%+v", len(expectedComments), comments)
+	}
+	for i, expected := range expectedComments {
+		if comments[i] != expected {
+			testInput.Errorf("comments[%d] wrong. expected=%+v, got=%+v", i, expected, comments[i])
+		}
+	}
+}
+
+func TestShebang(testInput *testing.T) {
+	lexer := New("#!/usr/bin/env monkey\nlet x = 1;")
+
+	tok := lexer.NextToken()
+	if tok.Type != token.LET || tok.Line != 2 || tok.Column != 1 {
+		testInput.Fatalf("wrong first token. got=%+v", tok)
+	}
+
+	comments := lexer.Comments()
+	expected := token.Token{Type: token.COMMENT, Literal: "#!/usr/bin/env monkey", Line: 1, Column: 1}
+	if len(comments) != 1 || comments[0] != expected {
+		testInput.Errorf("wrong comments. expected=%+v, got=%+v", expected, comments)
+	}
+
+	// Only the first line can be a shebang.
+	lexer = New("1\n#!")
+	lexer.NextToken()
+	if tok := lexer.NextToken(); tok.Type != token.ILLEGAL {
+		testInput.Errorf("expected an ILLEGAL token. got=%+v", tok)
+	}
+}
+
+func TestUnterminated(testInput *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"closed"`, false},
+		{`let s = "open`, true},
+		{`"a" + "b`, true},
+		{`"`, true},
+		{`// "not a string`, false},
+	}
+
+	for _, tt := range tests {
+		lexer := New(tt.input)
+		for tok := lexer.NextToken(); tok.Type != token.EOF; tok = lexer.NextToken() {
+		}
+		if lexer.Unterminated() != tt.expected {
+			testInput.Errorf("%q: Unterminated wrong. expected=%t, got=%t", tt.input, tt.expected, lexer.Unterminated())
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Package lint reports code that parses and resolves but is likely to be a
+// bug. Each check is a named rule that a Config can turn off, and a
+// comment of the form
+//
+//	// lint:ignore rule-name
+//
+// suppresses the named rules, or all rules if none are named, on its own
+// line if it follows code and otherwise on the next line.
+package lint
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/lexer"
+	"WeekTwo/parser"
+	"WeekTwo/resolver"
+	"WeekTwo/token"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Problem is something a rule found at the position of Token.
+type Problem struct {
+	Rule    string
+	Message string
+	Token   token.Token
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%d:%d: %s (%s)", p.Token.Line, p.Token.Column, p.Message, p.Rule)
+}
+
+type Rule struct {
+	Name        string
+	Description string
+	check       func(l *linter)
+}
+
+// Rules lists every rule, all of which are enabled unless a Config turns
+// them off.
+var Rules = []*Rule{
+	{Name: "unused-binding", Description: "let bindings that are never used", check: checkUnusedBindings},
+	{Name: "unreachable-code", Description: "statements after a return statement", check: checkUnreachableCode},
+	{Name: "constant-condition", Description: "if expressions whose condition is a constant", check: checkConstantConditions},
+	{Name: "type-mismatch", Description: "operators applied to literals of different types", check: checkTypeMismatches},
+	{Name: "duplicate-key", Description: "hash literals with the same key twice", check: checkDuplicateKeys},
+	{Name: "builtin-arity", Description: "calls to builtins with the wrong number of arguments", check: checkBuiltinArity},
+}
+
+// DefaultConfigFile is the config file the lint command reads if no other
+// is given.
+const DefaultConfigFile = ".monkeylint.json"
+
+// Config turns rules on or off by name, for example
+//
+//	{"rules": {"unused-binding": false}}
+//
+// Rules that are not mentioned are enabled.
+type Config struct {
+	Rules map[string]bool `json:"rules"`
+}
+
+// LoadConfig reads a config from the JSON file at path.
+func LoadConfig(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	for name := range config.Rules {
+		if lookupRule(name) == nil {
+			return nil, fmt.Errorf("%s: unknown rule %q", path, name)
+		}
+	}
+
+	return &config, nil
+}
+
+// Enabled reports whether the rule called name should run. A nil config
+// enables every rule.
+func (c *Config) Enabled(name string) bool {
+	if c == nil {
+		return true
+	}
+	enabled, ok := c.Rules[name]
+	return !ok || enabled
+}
+
+func lookupRule(name string) *Rule {
+	for _, rule := range Rules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Source lints src with the rules config enables and returns the problems
+// found, sorted by position. It returns an error if src does not parse.
+func Source(src []byte, config *Config) ([]Problem, error) {
+	lex := lexer.New(string(src))
+	p := parser.New(lex)
+	program := p.ParseProgram()
+
+	if errors := p.ErrorDetails(); len(errors) != 0 {
+		messages := make([]string, len(errors))
+		for i, err := range errors {
+			messages[i] = fmt.Sprintf("%d:%d: %s", err.Token.Line, err.Token.Column, err.Message)
+		}
+		return nil, fmt.Errorf("%s", strings.Join(messages, "\n"))
+	}
+
+	r := resolver.New()
+	r.Resolve(program)
+
+	l := &linter{program: program, definitions: r.Definitions()}
+	for _, rule := range Rules {
+		if config.Enabled(rule.Name) {
+			l.rule = rule.Name
+			rule.check(l)
+		}
+	}
+
+	ignored := suppressions(lex.Comments(), strings.Split(string(src), "\n"))
+	problems := []Problem{}
+	for _, problem := range l.problems {
+		if rules, ok := ignored[problem.Token.Line]; ok && (len(rules) == 0 || rules[problem.Rule]) {
+			continue
+		}
+		problems = append(problems, problem)
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		a, b := problems[i].Token, problems[j].Token
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	return problems, nil
+}
+
+// suppressions maps lines to the rules lint:ignore comments suppress on
+// them. An empty set suppresses every rule.
+func suppressions(comments []token.Token, lines []string) map[int]map[string]bool {
+	ignored := make(map[int]map[string]bool)
+
+	for _, comment := range comments {
+		text := strings.TrimSpace(strings.TrimPrefix(comment.Literal, "//"))
+		if !strings.HasPrefix(text, "lint:ignore") {
+			continue
+		}
+
+		line := comment.Line + 1
+		if prefix := lines[comment.Line-1][:comment.Column-1]; strings.TrimSpace(prefix) != "" {
+			line = comment.Line
+		}
+
+		rules := make(map[string]bool)
+		names := strings.FieldsFunc(strings.TrimPrefix(text, "lint:ignore"), func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		for _, name := range names {
+			rules[name] = true
+		}
+		ignored[line] = rules
+	}
+
+	return ignored
+}
+
+type linter struct {
+	program     *ast.Program
+	definitions map[*ast.Identifier]*ast.Identifier
+	rule        string
+	problems    []Problem
+}
+
+func (l *linter) report(tok token.Token, format string, a ...interface{}) {
+	l.problems = append(l.problems, Problem{Rule: l.rule, Message: fmt.Sprintf(format, a...), Token: tok})
+}
+
+// inspect calls f for every node of the program.
+func (l *linter) inspect(f func(node ast.Node)) {
+	ast.Modify(l.program, func(node ast.Node) ast.Node {
+		f(node)
+		return node
+	})
+}
@@ -0,0 +1,106 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let x = 1; let _y = 2; export let z = 3;`, []string{"1:5: x declared and not used (unused-binding)"}},
+		{`let f = fn() { let a = 1; let b = a; b }; f();`, nil},
+		{`let f = fn() { return 1; puts(2); puts(3) }; f();`, []string{"1:26: unreachable code (unreachable-code)"}},
+		{`return 1; puts(2);`, []string{"1:11: unreachable code (unreachable-code)"}},
+		{`if (true) { 1 }; if (!0) { 2 }; if ("s") { 3 }`, []string{
+			"1:1: if condition is always true (constant-condition)",
+			"1:18: if condition is always false (constant-condition)",
+			"1:33: if condition is always true (constant-condition)",
+		}},
+		{`let x = 1; if (x > 0) { x }`, nil},
+		{`1 + "a"; 1 == "1"; [1] != -1; true < 2`, []string{
+			"1:3: type mismatch: INTEGER + STRING (type-mismatch)",
+			"1:12: comparison of INTEGER and STRING is always false (type-mismatch)",
+			"1:24: comparison of ARRAY and INTEGER is always true (type-mismatch)",
+			"1:36: type mismatch: BOOLEAN < INTEGER (type-mismatch)",
+		}},
+		{`1 + 2; "a" + "b"; {"__add__": fn(a, b) { a }} + 1`, nil},
+		{`{"a": 1, 2: 2, "a": 3, 2: 4, true: 5}`, []string{
+			`1:16: duplicate key "a" in hash literal (duplicate-key)`,
+			"1:24: duplicate key 2 in hash literal (duplicate-key)",
+		}},
+		{`len(); range(1, 2, 3, 4); puts(); puts(1, 2); channel(1)`, []string{
+			"1:1: wrong number of arguments to len. got=0, want=1 (builtin-arity)",
+			"1:8: wrong number of arguments to range. got=4, want=1..3 (builtin-arity)",
+		}},
+		{`let len = fn(a, b) { a }; len(1, 2)`, nil},
+	}
+
+	for _, tt := range tests {
+		problems, err := Source([]byte(tt.input), nil)
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", tt.input, err)
+			continue
+		}
+
+		if len(problems) != len(tt.expected) {
+			t.Errorf("%q: wrong number of problems. expected=%q, got=%v", tt.input, tt.expected, problems)
+			continue
+		}
+		for i, expected := range tt.expected {
+			if problems[i].String() != expected {
+				t.Errorf("%q: problems[%d] wrong. expected=%q, got=%q", tt.input, i, expected, problems[i])
+			}
+		}
+	}
+}
+
+func TestSuppressions(t *testing.T) {
+	input := `let a = 1; // lint:ignore unused-binding
+// lint:ignore
+let b = len(1, 2);
+let c = 1; // lint:ignore duplicate-key
+// lint:ignore unused-binding, builtin-arity
+let d = len();
+`
+
+	problems, err := Source([]byte(input), nil)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	expected := []string{"4:5: c declared and not used (unused-binding)"}
+	if len(problems) != len(expected) || problems[0].String() != expected[0] {
+		t.Errorf("wrong problems. expected=%q, got=%v", expected, problems)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, DefaultConfigFile)
+	os.WriteFile(path, []byte(`{"rules": {"unused-binding": false, "builtin-arity": true}}`), 0644)
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if config.Enabled("unused-binding") || !config.Enabled("builtin-arity") || !config.Enabled("duplicate-key") {
+		t.Errorf("wrong rules enabled. got=%v", config.Rules)
+	}
+
+	problems, err := Source([]byte(`let x = len();`), config)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if len(problems) != 1 || problems[0].Rule != "builtin-arity" {
+		t.Errorf("wrong problems. got=%v", problems)
+	}
+
+	os.WriteFile(path, []byte(`{"rules": {"no-such-rule": false}}`), 0644)
+	if _, err := LoadConfig(path); err == nil || err.Error() != path+`: unknown rule "no-such-rule"` {
+		t.Errorf("expected an unknown rule error. got=%v", err)
+	}
+}
@@ -0,0 +1,254 @@
+package lint
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/evaluator"
+	"WeekTwo/object"
+	"WeekTwo/token"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// checkUnusedBindings reports let bindings that nothing refers to. Exported
+// bindings are used by the modules that import them, and names starting
+// with _ are meant to be unused.
+func checkUnusedBindings(l *linter) {
+	used := make(map[*ast.Identifier]bool)
+	for ident, declaration := range l.definitions {
+		if ident != declaration {
+			used[declaration] = true
+		}
+	}
+
+	exported := make(map[*ast.LetStatement]bool)
+	var lets []*ast.LetStatement
+	l.inspect(func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.ExportStatement:
+			exported[node.Statement] = true
+		case *ast.LetStatement:
+			lets = append(lets, node)
+		}
+	})
+
+	for _, let := range lets {
+		if exported[let] || used[let.Name] || strings.HasPrefix(let.Name.Value, "_") {
+			continue
+		}
+		l.report(let.Name.Token, "%s declared and not used", let.Name.Value)
+	}
+}
+
+// checkUnreachableCode reports the first statement after a return
+// statement in a block.
+func checkUnreachableCode(l *linter) {
+	check := func(stmts []ast.Statement) {
+		for i, stmt := range stmts[:max(len(stmts)-1, 0)] {
+			if _, ok := stmt.(*ast.ReturnStatement); ok {
+				l.report(statementToken(stmts[i+1]), "unreachable code")
+				return
+			}
+		}
+	}
+
+	l.inspect(func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.Program:
+			check(node.Statements)
+		case *ast.BlockStatement:
+			check(node.Statements)
+		}
+	})
+}
+
+func statementToken(stmt ast.Statement) token.Token {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		return stmt.Token
+	case *ast.ReturnStatement:
+		return stmt.Token
+	case *ast.ExpressionStatement:
+		return stmt.Token
+	case *ast.EnumStatement:
+		return stmt.Token
+	case *ast.ImportStatement:
+		return stmt.Token
+	case *ast.ExportStatement:
+		return stmt.Token
+	}
+	return token.Token{}
+}
+
+// checkConstantConditions reports if expressions that always take the same
+// branch because their condition is a literal.
+func checkConstantConditions(l *linter) {
+	l.inspect(func(node ast.Node) {
+		if node, ok := node.(*ast.IfExpression); ok {
+			if truthy, ok := constantTruth(node.Condition); ok {
+				l.report(node.Token, "if condition is always %t", truthy)
+			}
+		}
+	})
+}
+
+// constantTruth returns whether exp is truthy if it is a literal or the
+// negation of one.
+func constantTruth(exp ast.Expression) (truthy bool, ok bool) {
+	switch exp := exp.(type) {
+	case *ast.Boolean:
+		return exp.Value, true
+	case *ast.PrefixExpression:
+		if exp.Operator == "!" {
+			truthy, ok := constantTruth(exp.Right)
+			return !truthy, ok
+		}
+	}
+	if _, ok := literalType(exp); ok {
+		return true, true
+	}
+	return false, false
+}
+
+// literalType returns the type of the object exp evaluates to if exp is a
+// literal.
+func literalType(exp ast.Expression) (object.ObjectType, bool) {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return object.INTEGER_OBJ, true
+	case *ast.StringLiteral:
+		return object.STRING_OBJ, true
+	case *ast.Boolean:
+		return object.BOOLEAN_OBJ, true
+	case *ast.ArrayLiteral:
+		return object.ARRAY_OBJ, true
+	case *ast.HashLiteral:
+		return object.HASH_OBJ, true
+	case *ast.FunctionLiteral:
+		return object.FUNCTION_OBJ, true
+	case *ast.PrefixExpression:
+		if exp.Operator == "!" {
+			return object.BOOLEAN_OBJ, true
+		}
+		if t, ok := literalType(exp.Right); ok && t == object.INTEGER_OBJ {
+			return object.INTEGER_OBJ, true
+		}
+	}
+	return "", false
+}
+
+// checkTypeMismatches reports infix expressions over literals of different
+// types. == and != compare them by identity, so they always give the same
+// result, and the other operators are type mismatch errors.
+func checkTypeMismatches(l *linter) {
+	l.inspect(func(node ast.Node) {
+		infix, ok := node.(*ast.InfixExpression)
+		if !ok {
+			return
+		}
+		left, ok := literalType(infix.Left)
+		if !ok || left == object.HASH_OBJ { // hashes can overload operators
+			return
+		}
+		right, ok := literalType(infix.Right)
+		if !ok || left == right {
+			return
+		}
+
+		switch infix.Operator {
+		case "==":
+			l.report(infix.Token, "comparison of %s and %s is always false", left, right)
+		case "!=":
+			l.report(infix.Token, "comparison of %s and %s is always true", left, right)
+		default:
+			l.report(infix.Token, "type mismatch: %s %s %s", left, infix.Operator, right)
+		}
+	})
+}
+
+// checkDuplicateKeys reports literal keys that appear twice in a hash
+// literal, of which only the last value is kept.
+func checkDuplicateKeys(l *linter) {
+	l.inspect(func(node ast.Node) {
+		hash, ok := node.(*ast.HashLiteral)
+		if !ok {
+			return
+		}
+
+		keys := make([]ast.Expression, 0, len(hash.Pairs))
+		for key := range hash.Pairs {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			a, b := keyToken(keys[i]), keyToken(keys[j])
+			return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+		})
+
+		seen := make(map[string]bool)
+		for _, key := range keys {
+			var value string
+			switch key := key.(type) {
+			case *ast.IntegerLiteral:
+				value = fmt.Sprintf("%d", key.Value)
+			case *ast.StringLiteral:
+				value = fmt.Sprintf("%q", key.Value)
+			case *ast.Boolean:
+				value = fmt.Sprintf("%t", key.Value)
+			default:
+				continue
+			}
+
+			if seen[value] {
+				l.report(keyToken(key), "duplicate key %s in hash literal", value)
+			}
+			seen[value] = true
+		}
+	})
+}
+
+// keyToken returns the token of a literal key, or the zero token for other
+// keys, which are not compared.
+func keyToken(key ast.Expression) token.Token {
+	switch key := key.(type) {
+	case *ast.IntegerLiteral:
+		return key.Token
+	case *ast.StringLiteral:
+		return key.Token
+	case *ast.Boolean:
+		return key.Token
+	}
+	return token.Token{}
+}
+
+// checkBuiltinArity reports calls to builtins that will fail because of
+// the number of arguments. Names the program declares itself are skipped.
+func checkBuiltinArity(l *linter) {
+	l.inspect(func(node ast.Node) {
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return
+		}
+		ident, ok := call.Function.(*ast.Identifier)
+		if !ok || l.definitions[ident] != nil {
+			return
+		}
+		least, greatest, ok := evaluator.BuiltinArity(ident.Value)
+		if !ok {
+			return
+		}
+
+		got := len(call.Arguments)
+		if got >= least && (greatest == -1 || got <= greatest) {
+			return
+		}
+
+		want := fmt.Sprintf("%d", least)
+		switch {
+		case greatest == -1:
+			want += ".."
+		case greatest != least:
+			want = fmt.Sprintf("%d..%d", least, greatest)
+		}
+		l.report(ident.Token, "wrong number of arguments to %s. got=%d, want=%s", ident.Value, got, want)
+	})
+}
@@ -0,0 +1,230 @@
+package lsp
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/lexer"
+	"WeekTwo/parser"
+	"WeekTwo/resolver"
+	"WeekTwo/token"
+	"sort"
+	"strings"
+)
+
+// document is an open text document and what is known about its program.
+// Navigation only works while the document parses.
+type document struct {
+	uri         string
+	text        string
+	program     *ast.Program
+	diagnostics []Diagnostic
+
+	// definitions maps identifiers to their declarations, as found by the
+	// resolver.
+	definitions map[*ast.Identifier]*ast.Identifier
+	// identifiers holds every identifier in the program, sorted by position.
+	identifiers []*ast.Identifier
+	lets        map[*ast.Identifier]*ast.LetStatement
+	parameters  map[*ast.Identifier]*ast.FunctionLiteral
+}
+
+func newDocument(uri, text string) *document {
+	doc := &document{
+		uri:         uri,
+		text:        text,
+		diagnostics: []Diagnostic{},
+		lets:        make(map[*ast.Identifier]*ast.LetStatement),
+		parameters:  make(map[*ast.Identifier]*ast.FunctionLiteral),
+	}
+
+	p := parser.New(lexer.New(text))
+	program := p.ParseProgram()
+
+	if errors := p.ErrorDetails(); len(errors) != 0 {
+		for _, err := range errors {
+			doc.diagnostics = append(doc.diagnostics, Diagnostic{
+				Range:    tokenRange(err.Token),
+				Severity: SeverityError,
+				Source:   "parser",
+				Message:  err.Message,
+			})
+		}
+		return doc
+	}
+
+	doc.program = program
+
+	r := resolver.New()
+	for _, d := range r.Resolve(program) {
+		severity := SeverityError
+		if d.Severity == resolver.Warning {
+			severity = SeverityWarning
+		}
+		doc.diagnostics = append(doc.diagnostics, Diagnostic{
+			Range:    tokenRange(d.Token),
+			Severity: severity,
+			Source:   "resolver",
+			Message:  d.Message,
+		})
+	}
+	doc.definitions = r.Definitions()
+
+	doc.index()
+	return doc
+}
+
+// index records the identifiers of the program and the let statements and
+// function literals that declare them.
+func (doc *document) index() {
+	seen := make(map[*ast.Identifier]bool)
+	add := func(ident *ast.Identifier) {
+		if !seen[ident] {
+			seen[ident] = true
+			doc.identifiers = append(doc.identifiers, ident)
+		}
+	}
+
+	for ident := range doc.definitions {
+		add(ident)
+	}
+
+	ast.Modify(doc.program, func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case *ast.Identifier:
+			add(node)
+		case *ast.LetStatement:
+			doc.lets[node.Name] = node
+		case *ast.FunctionLiteral:
+			for _, param := range node.Parameters {
+				doc.parameters[param] = node
+			}
+		}
+		return node
+	})
+
+	sort.Slice(doc.identifiers, func(i, j int) bool {
+		a, b := doc.identifiers[i].Token, doc.identifiers[j].Token
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// identifierAt returns the identifier at pos, or nil.
+func (doc *document) identifierAt(pos Position) *ast.Identifier {
+	for _, ident := range doc.identifiers {
+		r := identifierRange(ident)
+		if r.Start.Line == pos.Line && r.Start.Character <= pos.Character && pos.Character <= r.End.Character {
+			return ident
+		}
+	}
+	return nil
+}
+
+// references returns the identifiers that refer to the same declaration as
+// ident, in order of position.
+func (doc *document) references(ident *ast.Identifier, includeDeclaration bool) []*ast.Identifier {
+	declaration, ok := doc.definitions[ident]
+	if !ok {
+		return nil
+	}
+
+	var refs []*ast.Identifier
+	for _, other := range doc.identifiers {
+		if doc.definitions[other] != declaration {
+			continue
+		}
+		if other == declaration && !includeDeclaration {
+			continue
+		}
+		refs = append(refs, other)
+	}
+	return refs
+}
+
+// signature describes the declaration of ident for hovers: the parameters
+// of a function bound with let, or the function a parameter belongs to.
+func (doc *document) signature(declaration *ast.Identifier) string {
+	if let, ok := doc.lets[declaration]; ok {
+		if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+			return "let " + declaration.Value + " = " + functionSignature(fn)
+		}
+		return "let " + declaration.Value
+	}
+
+	if fn, ok := doc.parameters[declaration]; ok {
+		owner := fn.Name
+		if owner == "" {
+			owner = "<anonymous>"
+		}
+		return declaration.Value + ": parameter of " + owner + " = " + functionSignature(fn)
+	}
+
+	return declaration.Value
+}
+
+func functionSignature(fn *ast.FunctionLiteral) string {
+	params := make([]string, len(fn.Parameters))
+	for i, p := range fn.Parameters {
+		params[i] = p.Value
+	}
+	return "fn(" + strings.Join(params, ", ") + ")"
+}
+
+// symbols returns the let statements at the top level of the program.
+func (doc *document) symbols() []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	if doc.program == nil {
+		return symbols
+	}
+
+	for _, stmt := range doc.program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			stmt = export.Statement
+		}
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			continue
+		}
+
+		symbol := DocumentSymbol{
+			Name:           let.Name.Value,
+			Kind:           SymbolKindVariable,
+			Range:          Range{Start: tokenRange(let.Token).Start, End: identifierRange(let.Name).End},
+			SelectionRange: identifierRange(let.Name),
+		}
+		if fn, ok := let.Value.(*ast.FunctionLiteral); ok {
+			symbol.Kind = SymbolKindFunction
+			symbol.Detail = functionSignature(fn)
+		}
+		symbols = append(symbols, symbol)
+	}
+
+	return symbols
+}
+
+// declaredNames returns the names declared anywhere in the program.
+func (doc *document) declaredNames() map[string]*ast.Identifier {
+	names := make(map[string]*ast.Identifier)
+	for ident, declaration := range doc.definitions {
+		if ident == declaration {
+			names[ident.Value] = ident
+		}
+	}
+	return names
+}
+
+// tokenRange converts the 1-based position of tok to an LSP range that
+// covers its literal.
+func tokenRange(tok token.Token) Range {
+	start := Position{Line: max(tok.Line-1, 0), Character: max(tok.Column-1, 0)}
+	end := start
+	end.Character += len(tok.Literal)
+	return Range{Start: start, End: end}
+}
+
+func identifierRange(ident *ast.Identifier) Range {
+	r := tokenRange(ident.Token)
+	r.End.Character = r.Start.Character + len(ident.Value)
+	return r
+}
@@ -0,0 +1,68 @@
+package lsp
+
+import (
+	"WeekTwo/framing"
+	"bufio"
+	"encoding/json"
+	"io"
+)
+
+// Message is a JSON-RPC 2.0 request, notification or response. Requests
+// have both an ID and a Method, notifications only a Method.
+type Message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *ResponseError  `json:"error,omitempty"`
+}
+
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+const (
+	MethodNotFoundCode = -32601
+	InvalidParamsCode  = -32602
+)
+
+func (e *ResponseError) Error() string {
+	return e.Message
+}
+
+// ReadMessage reads one message framed by a Content-Length header.
+func ReadMessage(r *bufio.Reader) (*Message, error) {
+	var msg Message
+	if err := framing.Read(r, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// WriteMessage writes msg, which is marshalled to JSON, framed by a
+// Content-Length header.
+func WriteMessage(w io.Writer, msg any) error {
+	return framing.Write(w, msg)
+}
+
+// response is written instead of Message so that a null result is sent
+// rather than left out.
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result"`
+}
+
+type errorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   *ResponseError  `json:"error"`
+}
+
+type notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
@@ -0,0 +1,291 @@
+package lsp
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/evaluator"
+	"WeekTwo/token"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Server serves the Language Server Protocol for Monkey documents. It keeps
+// the text of the open documents in memory and analyzes a document
+// whenever it changes.
+type Server struct {
+	in        *bufio.Reader
+	out       io.Writer
+	documents map[string]*document
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: make(map[string]*document),
+	}
+}
+
+// errExit is returned by handle for the exit notification.
+var errExit = errors.New("exit")
+
+// Serve handles messages until the client sends exit or in is exhausted.
+func (s *Server) Serve() error {
+	for {
+		msg, err := ReadMessage(s.in)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		result, err := s.handle(msg)
+		if err == errExit {
+			return nil
+		}
+		if msg.ID == nil {
+			continue
+		}
+
+		var respErr *ResponseError
+		switch {
+		case errors.As(err, &respErr):
+			WriteMessage(s.out, &errorResponse{JSONRPC: "2.0", ID: msg.ID, Error: respErr})
+		case err != nil:
+			WriteMessage(s.out, &errorResponse{JSONRPC: "2.0", ID: msg.ID, Error: &ResponseError{Code: InvalidParamsCode, Message: err.Error()}})
+		default:
+			WriteMessage(s.out, &response{JSONRPC: "2.0", ID: msg.ID, Result: result})
+		}
+	}
+}
+
+func (s *Server) handle(msg *Message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":       1, // the full text is sent on every change
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"hoverProvider":          true,
+				"documentSymbolProvider": true,
+				"completionProvider":     map[string]any{},
+			},
+			"serverInfo": map[string]any{"name": "monkey"},
+		}, nil
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		return nil, nil
+	case "exit":
+		return nil, errExit
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		s.publish(params.TextDocument.URI, []Diagnostic{})
+		return nil, nil
+	case "textDocument/definition":
+		return s.definition(msg)
+	case "textDocument/references":
+		return s.references(msg)
+	case "textDocument/hover":
+		return s.hover(msg)
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		doc, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, err
+		}
+		return doc.symbols(), nil
+	case "textDocument/completion":
+		return s.completion(msg)
+	}
+
+	return nil, &ResponseError{Code: MethodNotFoundCode, Message: fmt.Sprintf("method not found: %s", msg.Method)}
+}
+
+func decode(msg *Message, params any) error {
+	if err := json.Unmarshal(msg.Params, params); err != nil {
+		return &ResponseError{Code: InvalidParamsCode, Message: fmt.Sprintf("invalid params for %s: %s", msg.Method, err)}
+	}
+	return nil
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		return nil, fmt.Errorf("unknown document %s", uri)
+	}
+	return doc, nil
+}
+
+// update analyzes the new text of a document and publishes its
+// diagnostics.
+func (s *Server) update(uri, text string) {
+	doc := newDocument(uri, text)
+	s.documents[uri] = doc
+	s.publish(uri, doc.diagnostics)
+}
+
+func (s *Server) publish(uri string, diagnostics []Diagnostic) {
+	WriteMessage(s.out, &notification{
+		JSONRPC: "2.0",
+		Method:  "textDocument/publishDiagnostics",
+		Params:  PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics},
+	})
+}
+
+// lookup returns the document and the identifier at the position given by
+// params, or a nil identifier if there is none.
+func (s *Server) lookup(params TextDocumentPositionParams) (*document, *target, error) {
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, nil, err
+	}
+	ident := doc.identifierAt(params.Position)
+	if ident == nil {
+		return doc, nil, nil
+	}
+	return doc, &target{ident: ident, declaration: doc.definitions[ident]}, nil
+}
+
+func (s *Server) definition(msg *Message) (any, error) {
+	var params TextDocumentPositionParams
+	if err := decode(msg, &params); err != nil {
+		return nil, err
+	}
+
+	doc, at, err := s.lookup(params)
+	if err != nil || at == nil || at.declaration == nil {
+		return nil, err
+	}
+
+	return Location{URI: doc.uri, Range: identifierRange(at.declaration)}, nil
+}
+
+func (s *Server) references(msg *Message) (any, error) {
+	var params ReferenceParams
+	if err := decode(msg, &params); err != nil {
+		return nil, err
+	}
+
+	doc, at, err := s.lookup(params.TextDocumentPositionParams)
+	if err != nil {
+		return nil, err
+	}
+
+	locations := []Location{}
+	if at == nil {
+		return locations, nil
+	}
+	for _, ref := range doc.references(at.ident, params.Context.IncludeDeclaration) {
+		locations = append(locations, Location{URI: doc.uri, Range: identifierRange(ref)})
+	}
+	return locations, nil
+}
+
+func (s *Server) hover(msg *Message) (any, error) {
+	var params TextDocumentPositionParams
+	if err := decode(msg, &params); err != nil {
+		return nil, err
+	}
+
+	doc, at, err := s.lookup(params)
+	if err != nil || at == nil {
+		return nil, err
+	}
+
+	var text string
+	switch {
+	case at.declaration != nil:
+		text = doc.signature(at.declaration)
+	case isBuiltin(at.ident.Value):
+		text = "builtin " + at.ident.Value
+	default:
+		return nil, nil
+	}
+
+	return Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```monkey\n" + text + "\n```"},
+		Range:    identifierRange(at.ident),
+	}, nil
+}
+
+func isBuiltin(name string) bool {
+	_, ok := evaluator.LookupBuiltin(name)
+	return ok
+}
+
+// completion offers the names declared in the document, the builtins and
+// the keywords. Clients filter them by what has been typed.
+func (s *Server) completion(msg *Message) (any, error) {
+	var params TextDocumentPositionParams
+	if err := decode(msg, &params); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []CompletionItem{}
+
+	declared := doc.declaredNames()
+	names := make([]string, 0, len(declared))
+	for name := range declared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		item := CompletionItem{Label: name, Kind: CompletionKindVariable, Detail: doc.signature(declared[name])}
+		if let, ok := doc.lets[declared[name]]; ok {
+			if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+				item.Kind = CompletionKindFunction
+			}
+		}
+		items = append(items, item)
+	}
+
+	for _, name := range evaluator.BuiltinNames() {
+		items = append(items, CompletionItem{Label: name, Kind: CompletionKindFunction, Detail: "builtin"})
+	}
+	for _, keyword := range token.Keywords() {
+		items = append(items, CompletionItem{Label: keyword, Kind: CompletionKindKeyword})
+	}
+
+	return items, nil
+}
+
+// target is the identifier a request is about and its declaration, which
+// is nil for builtins and undeclared names.
+type target struct {
+	ident       *ast.Identifier
+	declaration *ast.Identifier
+}
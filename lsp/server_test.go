@@ -0,0 +1,249 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client drives a Server with JSON-RPC messages, reading the server's
+// messages as they arrive.
+type client struct {
+	t           *testing.T
+	received    chan *Message
+	out         io.Writer
+	id          int
+	diagnostics map[string][]Diagnostic
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	go func() {
+		NewServer(serverIn, serverOut).Serve()
+		serverOut.Close()
+	}()
+	t.Cleanup(func() { clientOut.Close() })
+
+	received := make(chan *Message, 100)
+	go func() {
+		defer close(received)
+		in := bufio.NewReader(clientIn)
+		for {
+			msg, err := ReadMessage(in)
+			if err != nil {
+				return
+			}
+			received <- msg
+		}
+	}()
+
+	return &client{t: t, received: received, out: clientOut, diagnostics: make(map[string][]Diagnostic)}
+}
+
+func (c *client) next() *Message {
+	c.t.Helper()
+
+	select {
+	case msg, ok := <-c.received:
+		if !ok {
+			c.t.Fatalf("connection closed")
+		}
+		if msg.Method == "textDocument/publishDiagnostics" {
+			var params PublishDiagnosticsParams
+			json.Unmarshal(msg.Params, &params)
+			c.diagnostics[params.URI] = params.Diagnostics
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timed out waiting for a message")
+	}
+	return nil
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	if err := WriteMessage(c.out, &notification{JSONRPC: "2.0", Method: method, Params: params}); err != nil {
+		c.t.Fatalf("could not send %s: %s", method, err)
+	}
+}
+
+// call sends a request and decodes the result of its response into result.
+func (c *client) call(method string, params any, result any) *Message {
+	c.t.Helper()
+
+	c.id++
+	request := map[string]any{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params}
+	if err := WriteMessage(c.out, request); err != nil {
+		c.t.Fatalf("could not send %s: %s", method, err)
+	}
+
+	for {
+		msg := c.next()
+		if msg.ID == nil || string(msg.ID) != string(mustMarshal(c.id)) {
+			continue
+		}
+		if msg.Error == nil && result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("could not decode %s result %s: %s", method, msg.Result, err)
+			}
+		}
+		return msg
+	}
+}
+
+// publishedDiagnostics waits for the diagnostics of uri.
+func (c *client) publishedDiagnostics(uri string) []Diagnostic {
+	c.t.Helper()
+	delete(c.diagnostics, uri)
+	for {
+		if diagnostics, ok := c.diagnostics[uri]; ok {
+			return diagnostics
+		}
+		c.next()
+	}
+}
+
+func mustMarshal(v any) []byte {
+	content, _ := json.Marshal(v)
+	return content
+}
+
+const uri = "file:///tmp/main.mk"
+
+const source = `let add = fn(a, b) {
+  a + b
+};
+let twice = fn(f, x) { f(f(x)) };
+let result = add(1, 2);
+puts(add(result, len("abc")));
+`
+
+func at(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: uri}, Position: Position{Line: line, Character: character}}
+}
+
+func TestLanguageServer(t *testing.T) {
+	c := newClient(t)
+
+	var initialized struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+	c.call("initialize", map[string]any{"processId": nil, "rootUri": nil, "capabilities": map[string]any{}}, &initialized)
+	for _, capability := range []string{"definitionProvider", "referencesProvider", "hoverProvider", "documentSymbolProvider", "completionProvider"} {
+		if initialized.Capabilities[capability] == nil {
+			t.Errorf("capability %s missing", capability)
+		}
+	}
+	c.notify("initialized", map[string]any{})
+
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: uri, LanguageID: "monkey", Version: 1, Text: source}})
+	if diagnostics := c.publishedDiagnostics(uri); len(diagnostics) != 0 {
+		t.Errorf("unexpected diagnostics: %+v", diagnostics)
+	}
+
+	// Definition of add from its call on line 5.
+	var location Location
+	c.call("textDocument/definition", at(4, 14), &location)
+	if location.URI != uri || location.Range != (Range{Start: Position{0, 4}, End: Position{0, 7}}) {
+		t.Errorf("wrong definition of add. got=%+v", location)
+	}
+
+	// Definition of the parameter b from its use in the body.
+	c.call("textDocument/definition", at(1, 6), &location)
+	if location.Range.Start != (Position{0, 16}) {
+		t.Errorf("wrong definition of b. got=%+v", location)
+	}
+
+	var references []Location
+	c.call("textDocument/references", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     Position{Line: 0, Character: 5},
+		"context":      map[string]any{"includeDeclaration": true},
+	}, &references)
+	var lines []int
+	for _, ref := range references {
+		lines = append(lines, ref.Range.Start.Line)
+	}
+	if len(lines) != 3 || lines[0] != 0 || lines[1] != 4 || lines[2] != 5 {
+		t.Errorf("wrong references to add. got=%+v", references)
+	}
+
+	var hover Hover
+	c.call("textDocument/hover", at(5, 6), &hover)
+	if !strings.Contains(hover.Contents.Value, "let add = fn(a, b)") {
+		t.Errorf("wrong hover for add. got=%q", hover.Contents.Value)
+	}
+	c.call("textDocument/hover", at(3, 24), &hover)
+	if !strings.Contains(hover.Contents.Value, "f: parameter of twice = fn(f, x)") {
+		t.Errorf("wrong hover for f. got=%q", hover.Contents.Value)
+	}
+	c.call("textDocument/hover", at(5, 20), &hover)
+	if !strings.Contains(hover.Contents.Value, "builtin len") {
+		t.Errorf("wrong hover for len. got=%q", hover.Contents.Value)
+	}
+	if response := c.call("textDocument/hover", at(2, 0), nil); string(response.Result) != "null" {
+		t.Errorf("expected no hover outside identifiers. got=%s", response.Result)
+	}
+
+	var symbols []DocumentSymbol
+	c.call("textDocument/documentSymbol", map[string]any{"textDocument": map[string]any{"uri": uri}}, &symbols)
+	if len(symbols) != 3 {
+		t.Fatalf("wrong number of symbols. got=%+v", symbols)
+	}
+	if symbols[0].Name != "add" || symbols[0].Kind != SymbolKindFunction || symbols[0].Detail != "fn(a, b)" {
+		t.Errorf("wrong symbol for add. got=%+v", symbols[0])
+	}
+	if symbols[2].Name != "result" || symbols[2].Kind != SymbolKindVariable || symbols[2].SelectionRange.Start != (Position{4, 4}) {
+		t.Errorf("wrong symbol for result. got=%+v", symbols[2])
+	}
+
+	var items []CompletionItem
+	c.call("textDocument/completion", at(5, 0), &items)
+	labels := make(map[string]CompletionItem)
+	for _, item := range items {
+		labels[item.Label] = item
+	}
+	for _, name := range []string{"add", "twice", "result", "len", "puts", "push", "channel", "let"} {
+		if _, ok := labels[name]; !ok {
+			t.Errorf("completion is missing %s", name)
+		}
+	}
+	if labels["add"].Kind != CompletionKindFunction || labels["len"].Kind != CompletionKindFunction {
+		t.Errorf("wrong completion kinds. got=%+v, %+v", labels["add"], labels["len"])
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let x = ;\nlet y = 1;"}},
+	})
+	diagnostics := c.publishedDiagnostics(uri)
+	if len(diagnostics) == 0 || diagnostics[0].Source != "parser" || diagnostics[0].Severity != SeverityError {
+		t.Fatalf("expected a parser error. got=%+v", diagnostics)
+	}
+	if diagnostics[0].Message != "no prefix parse function for ; found" || diagnostics[0].Range.Start != (Position{0, 8}) {
+		t.Errorf("wrong parser error. got=%+v", diagnostics[0])
+	}
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   TextDocumentIdentifier{URI: uri},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let f = fn() { let unused = 1; missing };"}},
+	})
+	diagnostics = c.publishedDiagnostics(uri)
+	if len(diagnostics) != 2 || diagnostics[0].Message != "unused declared and not used" || diagnostics[1].Message != "identifier not found: missing" {
+		t.Errorf("wrong resolver diagnostics. got=%+v", diagnostics)
+	}
+
+	if response := c.call("textDocument/unknown", map[string]any{}, nil); response.Error == nil || response.Error.Code != MethodNotFoundCode {
+		t.Errorf("expected method not found. got=%+v", response)
+	}
+
+	if response := c.call("shutdown", nil, nil); response.Error != nil {
+		t.Errorf("shutdown failed: %+v", response.Error)
+	}
+	c.notify("exit", nil)
+}
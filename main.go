@@ -1,13 +1,74 @@
 package main
 
 import (
+	"WeekTwo/checker"
+	"WeekTwo/dap"
+	"WeekTwo/evaluator"
+	"WeekTwo/format"
+	"WeekTwo/interpreter"
+	"WeekTwo/lint"
+	"WeekTwo/lsp"
+	"WeekTwo/object"
 	"WeekTwo/repl"
+	"bytes"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"net"
 	"os"
 	"os/user"
+	"strings"
+)
+
+// commands maps the name of a subcommand to the function that runs it with
+// the remaining arguments and returns the exit code.
+var commands = map[string]func(args []string) int{
+	"check": checkCommand,
+	"dap":   dapCommand,
+	"fmt":   fmtCommand,
+	"lint":  lintCommand,
+	"lsp":   lspCommand,
+	"run":   runCommand,
+}
+
+// The exit codes of programs that fail, as in sysexits.h.
+const (
+	exitParseError   = 65
+	exitRuntimeError = 70
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+	os.Exit(monkeyCommand(os.Args[1:]))
+}
+
+// monkeyCommand runs a program given with -e, in a file or on stdin, and
+// starts the REPL if there is none. A file can be given without the run
+// command, so that scripts can start with a line such as
+//
+//	#!/usr/bin/env monkey
+func monkeyCommand(args []string) int {
+	flags := flag.NewFlagSet("monkey", flag.ContinueOnError)
+	expression := flags.String("e", "", "run this program and print its value")
+	strict := flags.Bool("strict", false, "check type annotations at run time")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	switch {
+	case *expression != "":
+		return runProgram("-e", *expression, flags.Args(), *strict, true)
+	case flags.NArg() > 0:
+		return runProgram(flags.Arg(0), "", flags.Args()[1:], *strict, false)
+	case !isTerminal(os.Stdin):
+		return runProgram("-", "", nil, *strict, false)
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -16,4 +77,323 @@ func main() {
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username)
 	fmt.Printf("Feel free to type in commands\n")
 	repl.Start(os.Stdin, os.Stdout)
+	return 0
+}
+
+// runCommand runs the program in a file, or on stdin if the file is -,
+// with the arguments that follow it.
+func runCommand(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	strict := flags.Bool("strict", false, "check type annotations at run time")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: monkey run [-strict] file.mk [args...]")
+		return 2
+	}
+
+	return runProgram(flags.Arg(0), "", flags.Args()[1:], *strict, false)
+}
+
+// runProgram runs a program with args in the args global and returns the
+// exit code. The program is source if path is -e, is read from stdin if
+// path is - and from the file at path otherwise. With printResult the
+// value of the program is printed unless it is null.
+func runProgram(path, source string, args []string, strict, printResult bool) int {
+	interp := interpreter.New()
+	interp.SetStrict(strict)
+
+	elements := make([]object.Object, len(args))
+	for i, arg := range args {
+		elements[i] = &object.String{Value: arg}
+	}
+	interp.SetGlobal("args", &object.Array{Elements: elements})
+
+	var result object.Object
+	var err error
+	switch path {
+	case "-e":
+		result, err = interp.Run(source)
+	case "-":
+		var src []byte
+		if src, err = io.ReadAll(os.Stdin); err == nil {
+			path = "<stdin>"
+			result, err = interp.Run(string(src))
+		}
+	default:
+		result, err = interp.RunFile(path)
+	}
+
+	for _, msg := range interp.Warnings() {
+		fmt.Fprintf(os.Stderr, "%s: warning: %s\n", path, msg)
+	}
+
+	var parseErr *interpreter.ParseError
+	var runtimeErr *interpreter.RuntimeError
+	switch {
+	case errors.As(err, &parseErr):
+		for _, e := range parseErr.Details {
+			fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", path, e.Token.Line, e.Token.Column, e.Message)
+		}
+		return exitParseError
+	case errors.As(err, &runtimeErr):
+		if len(runtimeErr.Stack) == 0 {
+			fmt.Fprintln(os.Stderr, "ERROR: "+runtimeErr.Message)
+		} else {
+			fmt.Fprintln(os.Stderr, runtimeErr.Traceback())
+		}
+		return exitRuntimeError
+	case err != nil:
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if printResult && result != evaluator.NULL {
+		fmt.Println(result.Inspect())
+	}
+	return 0
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or a file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func dapCommand(args []string) int {
+	flags := flag.NewFlagSet("dap", flag.ContinueOnError)
+	listen := flags.String("listen", "", "serve on this TCP address instead of stdio, e.g. localhost:4711")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *listen == "" {
+		if err := dap.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer listener.Close()
+	fmt.Fprintf(os.Stderr, "serving DAP on %s\n", listener.Addr())
+
+	conn, err := listener.Accept()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer conn.Close()
+
+	if err := dap.NewServer(conn, conn).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func lspCommand(args []string) int {
+	flags := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// fmtCommand formats the given files, or stdin, and prints the result.
+// With -w it rewrites the files instead, and with -check it only lists the
+// files that are not formatted and fails if there are any.
+func fmtCommand(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	write := flags.Bool("w", false, "write the result to the files instead of stdout")
+	check := flags.Bool("check", false, "list the files that are not formatted and exit with status 1 if there are any")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		formatted, err := format.Source(src)
+		if err != nil {
+			printParseErrors("<stdin>", err)
+			return 1
+		}
+		if *check {
+			if !bytes.Equal(src, formatted) {
+				fmt.Println("<stdin>")
+				return 1
+			}
+			return 0
+		}
+		os.Stdout.Write(formatted)
+		return 0
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		formatted, err := format.Source(src)
+		if err != nil {
+			printParseErrors(path, err)
+			status = 1
+			continue
+		}
+
+		switch {
+		case *check:
+			if !bytes.Equal(src, formatted) {
+				fmt.Println(path)
+				status = 1
+			}
+		case *write:
+			if !bytes.Equal(src, formatted) {
+				if err := os.WriteFile(path, formatted, 0644); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					status = 1
+				}
+			}
+		default:
+			os.Stdout.Write(formatted)
+		}
+	}
+	return status
+}
+
+// printParseErrors prints each parse error of path as path:line:column.
+func printParseErrors(path string, err error) {
+	for _, line := range strings.Split(err.Error(), "\n") {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", path, line)
+	}
+}
+
+// lintCommand lints the given files, or stdin, and prints the problems
+// found. It fails if there are any.
+func lintCommand(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	configPath := flags.String("config", "", "read the enabled rules from this file instead of "+lint.DefaultConfigFile)
+	listRules := flags.Bool("rules", false, "list the rules and exit")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *listRules {
+		for _, rule := range lint.Rules {
+			fmt.Printf("%-20s %s\n", rule.Name, rule.Description)
+		}
+		return 0
+	}
+
+	var config *lint.Config
+	var err error
+	switch {
+	case *configPath != "":
+		config, err = lint.LoadConfig(*configPath)
+	default:
+		if _, statErr := os.Stat(lint.DefaultConfigFile); statErr == nil {
+			config, err = lint.LoadConfig(lint.DefaultConfigFile)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	type file struct {
+		path string
+		src  []byte
+	}
+	var files []file
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		files = append(files, file{"<stdin>", src})
+	}
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		files = append(files, file{path, src})
+	}
+
+	status := 0
+	for _, f := range files {
+		problems, err := lint.Source(f.src, config)
+		if err != nil {
+			printParseErrors(f.path, err)
+			status = 1
+			continue
+		}
+		for _, problem := range problems {
+			fmt.Printf("%s:%s\n", f.path, problem)
+			status = 1
+		}
+	}
+	return status
+}
+
+// checkCommand type checks the given files, or stdin, and prints the type
+// errors found. It fails if there are any.
+func checkCommand(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"<stdin>"}
+	}
+
+	status := 0
+	for _, path := range paths {
+		var src []byte
+		var err error
+		if path == "<stdin>" {
+			src, err = io.ReadAll(os.Stdin)
+		} else {
+			src, err = os.ReadFile(path)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		errors, err := checker.Source(src)
+		if err != nil {
+			printParseErrors(path, err)
+			status = 1
+			continue
+		}
+		for _, e := range errors {
+			fmt.Printf("%s:%s\n", path, e)
+			status = 1
+		}
+	}
+	return status
 }
@@ -0,0 +1,95 @@
+package object
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Task is the handle of a function running on its own goroutine.
+type Task struct {
+	done   chan struct{}
+	result Object
+}
+
+// NewTask starts run on a new goroutine.
+func NewTask(run func() Object) *Task {
+	t := &Task{done: make(chan struct{})}
+
+	go func() {
+		defer close(t.done)
+		t.result = run()
+	}()
+
+	return t
+}
+
+func (t *Task) Type() ObjectType { return TASK_OBJ }
+func (t *Task) Inspect() string  { return "task" }
+
+// Done returns a channel that is closed once the task has finished.
+func (t *Task) Done() <-chan struct{} {
+	return t.done
+}
+
+// Await blocks until the task has finished and returns its result.
+func (t *Task) Await() Object {
+	<-t.done
+	return t.result
+}
+
+type Channel struct {
+	C      chan Object
+	mu     sync.Mutex
+	closed bool
+}
+
+func NewChannel(capacity int) *Channel {
+	return &Channel{C: make(chan Object, capacity)}
+}
+
+func (c *Channel) Type() ObjectType { return CHANNEL_OBJ }
+func (c *Channel) Inspect() string  { return fmt.Sprintf("channel(%d)", cap(c.C)) }
+
+// Send blocks until value is sent, and returns false if the channel is
+// closed.
+func (c *Channel) Send(value Object) (sent bool) {
+	defer func() {
+		if recover() != nil {
+			sent = false
+		}
+	}()
+
+	c.C <- value
+	return true
+}
+
+// Receive blocks until a value is available, and returns false once the
+// channel is closed and drained.
+func (c *Channel) Receive() (Object, bool) {
+	value, ok := <-c.C
+	return value, ok
+}
+
+// Close closes the channel, and returns false if it was already closed.
+func (c *Channel) Close() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return false
+	}
+	c.closed = true
+	close(c.C)
+	return true
+}
+
+// Iterator receives values until the channel is closed.
+func (c *Channel) Iterator() Iterator { return channelIterator{c} }
+
+type channelIterator struct {
+	channel *Channel
+}
+
+func (it channelIterator) Next() (Object, bool) {
+	return it.channel.Receive()
+}
@@ -1,30 +1,386 @@
 package object
 
+import (
+	"WeekTwo/ast"
+	"bufio"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// Importer loads the module at path for code evaluated in env, which
+// gives the file the path is relative to and the streams the module uses.
+type Importer interface {
+	Import(path string, env *Environment) Object
+}
+
+// Limiter enforces the execution limits of an evaluation. Step, Allocate
+// and EnterCall return an error object once their limit is exceeded, and
+// nil otherwise. Done returns a channel that is closed once the evaluation
+// is stopped from outside, which operations that block wait on too, and
+// Stopped returns the error to stop with then.
+type Limiter interface {
+	Step() Object
+	Allocate(size int) Object
+	EnterCall(depth int) Object
+	Done() <-chan struct{}
+	Stopped() Object
+}
+
+// Tracer follows an evaluation statement by statement. A debugger
+// implements it to pause the evaluation: Statement is called before each
+// statement and may block until the evaluation should resume.
+type Tracer interface {
+	Statement(stmt ast.Statement, env *Environment)
+	EnterCall(fn *Function, env *Environment)
+	LeaveCall()
+}
+
+// IO holds the streams that builtins read from and write to. Hold its lock
+// while using a stream that tasks running concurrently may share.
+type IO struct {
+	sync.Mutex
+	Stdin  *bufio.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+func NewIO(stdin io.Reader, stdout, stderr io.Writer) *IO {
+	reader, ok := stdin.(*bufio.Reader)
+	if !ok {
+		reader = bufio.NewReader(stdin)
+	}
+	return &IO{Stdin: reader, Stdout: stdout, Stderr: stderr}
+}
+
+// Environment is safe for concurrent use, so that closures running in
+// spawned tasks can share the scopes they were defined in.
+//
+// An environment inherits the settings of the one it is enclosed in. The
+// settings of its call frame are copied when it is created, and the others
+// are looked up once after any environment's settings change and cached,
+// so that the evaluator can consult them on every node cheaply.
 type Environment struct {
-	store map[string]Object
+	scope *scope
 	outer *Environment
+	frame atomic.Pointer[frame]
+	// The frame of a call environment, which frame points to unless it
+	// was changed since, so that a call allocates one object less.
+	call frame
+
+	mu       sync.Mutex
+	file     string
+	importer Importer
+	io       *IO
+	tracer   Tracer
+	strict   bool
+	// Whether any of the settings above was set on e.
+	configured atomic.Bool
+	settings   atomic.Pointer[settings]
+}
+
+// frame holds the settings of the function call an environment belongs to.
+// A frame is never modified, so the environments of a call share it.
+type frame struct {
+	depth   int
+	limiter Limiter
+	yield   func(Object) bool
+}
+
+// settings holds the settings an environment has or inherits that are not
+// bound to a call frame, as of version.
+type settings struct {
+	version  uint64
+	file     string
+	importer Importer
+	io       *IO
+	tracer   Tracer
+	strict   bool
+}
+
+// settingsVersion counts the changes to the settings of any environment,
+// which make the settings cached by every environment stale.
+var settingsVersion atomic.Uint64
+
+// scope holds the bindings of an environment, which WithLimiter shares
+// between two environments. Each binding is found by name in store and,
+// once it has been bound with SetSlot, by its slot in slots.
+type scope struct {
+	sync.RWMutex
+	store map[string]*cell
+	slots []*cell
+}
+
+type cell struct {
+	name  string
+	value Object
 }
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.frame.Store(outer.frame.Load())
+	return env
+}
+
+// NewCallEnvironment returns the environment of a function call made at
+// depth under limiter, enclosed in outer.
+func NewCallEnvironment(outer *Environment, depth int, limiter Limiter) *Environment {
+	env := &Environment{scope: &scope{}, outer: outer}
+	env.call = frame{depth: depth, limiter: limiter}
+	env.frame.Store(&env.call)
 	return env
 }
 
 func NewEnvironment() *Environment {
-	s := make(map[string]Object)
-	return &Environment{store: s}
+	env := &Environment{scope: &scope{}}
+	env.frame.Store(&frame{})
+	return env
+}
+
+// WithLimiter returns an environment that shares the bindings and settings
+// of e but evaluates under limiter, leaving e itself unchanged.
+func (e *Environment) WithLimiter(limiter Limiter) *Environment {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	env := &Environment{
+		scope:    e.scope,
+		outer:    e.outer,
+		file:     e.file,
+		importer: e.importer,
+		io:       e.io,
+		tracer:   e.tracer,
+		strict:   e.strict,
+	}
+	env.configured.Store(e.configured.Load())
+	f := *e.frame.Load()
+	f.limiter = limiter
+	env.frame.Store(&f)
+	return env
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
-	if !ok && e.outer != nil {
-		obj, ok = e.outer.Get(name)
+	e.scope.RLock()
+	c, ok := e.scope.store[name]
+	e.scope.RUnlock()
+	if !ok {
+		if e.outer != nil {
+			return e.outer.Get(name)
+		}
+		return nil, false
 	}
-	return obj, ok
+	return c.value, true
+}
+
+// GetSlot returns the binding in the given slot of the environment depth
+// levels out from e, without looking any name up. It reports false if that
+// slot holds no binding for name, for example because the binding has not
+// been made yet.
+func (e *Environment) GetSlot(depth, slot int, name string) (Object, bool) {
+	env := e
+	for i := 0; i < depth && env != nil; i++ {
+		env = env.outer
+	}
+	if env == nil {
+		return nil, false
+	}
+	env.scope.RLock()
+	defer env.scope.RUnlock()
+	if slot >= len(env.scope.slots) {
+		return nil, false
+	}
+	c := env.scope.slots[slot]
+	if c == nil || c.name != name {
+		return nil, false
+	}
+	return c.value, true
 }
 
 func (e *Environment) Set(name string, val Object) Object {
-	e.store[name] = val
+	e.scope.Lock()
+	defer e.scope.Unlock()
+	e.cell(name).value = val
 	return val
 }
+
+// SetSlot binds name like Set and also makes the binding available in
+// slot, where GetSlot finds it.
+func (e *Environment) SetSlot(slot int, name string, val Object) Object {
+	e.scope.Lock()
+	defer e.scope.Unlock()
+	c := e.cell(name)
+	c.value = val
+	for len(e.scope.slots) <= slot {
+		e.scope.slots = append(e.scope.slots, nil)
+	}
+	e.scope.slots[slot] = c
+	return val
+}
+
+// cell returns the binding of name, adding it if there is none. The scope
+// must be locked.
+func (e *Environment) cell(name string) *cell {
+	c, ok := e.scope.store[name]
+	if !ok {
+		if e.scope.store == nil {
+			e.scope.store = make(map[string]*cell)
+		}
+		c = &cell{name: name}
+		e.scope.store[name] = c
+	}
+	return c
+}
+
+// Outer returns the environment e is enclosed in, or nil.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Bindings returns a copy of the names bound directly in e, without those
+// of its outer environments.
+func (e *Environment) Bindings() map[string]Object {
+	e.scope.RLock()
+	defer e.scope.RUnlock()
+
+	bindings := make(map[string]Object, len(e.scope.store))
+	for name, c := range e.scope.store {
+		bindings[name] = c.value
+	}
+	return bindings
+}
+
+// inherited returns the settings of e, which are those of its outer
+// environments overridden by the ones set on e.
+func (e *Environment) inherited() *settings {
+	if !e.configured.Load() && e.outer != nil {
+		return e.outer.inherited()
+	}
+
+	version := settingsVersion.Load()
+	if cached := e.settings.Load(); cached != nil && cached.version == version {
+		return cached
+	}
+
+	var s settings
+	if e.outer != nil {
+		s = *e.outer.inherited()
+	}
+	s.version = version
+
+	e.mu.Lock()
+	if e.file != "" {
+		s.file = e.file
+	}
+	if e.importer != nil {
+		s.importer = e.importer
+	}
+	if e.io != nil {
+		s.io = e.io
+	}
+	if e.tracer != nil {
+		s.tracer = e.tracer
+	}
+	s.strict = s.strict || e.strict
+	e.mu.Unlock()
+
+	e.settings.Store(&s)
+	return &s
+}
+
+// set changes a setting of e and the environments enclosed in it.
+func (e *Environment) set(change func()) {
+	e.mu.Lock()
+	change()
+	e.configured.Store(true)
+	e.mu.Unlock()
+	settingsVersion.Add(1)
+}
+
+// setFrame changes the frame settings of e, which the environments
+// enclosed in e afterwards inherit.
+func (e *Environment) setFrame(change func(f *frame)) {
+	f := *e.frame.Load()
+	change(&f)
+	e.frame.Store(&f)
+}
+
+// File returns the path of the script this environment belongs to, or ""
+// when it was not loaded from a file.
+func (e *Environment) File() string {
+	return e.inherited().file
+}
+
+func (e *Environment) SetFile(path string) {
+	e.set(func() { e.file = path })
+}
+
+func (e *Environment) Importer() Importer {
+	return e.inherited().importer
+}
+
+func (e *Environment) SetImporter(importer Importer) {
+	e.set(func() { e.importer = importer })
+}
+
+// Yield returns the function that suspends the innermost generator this
+// environment belongs to, or nil outside of a generator. The function
+// returns false once the generator has been closed.
+func (e *Environment) Yield() func(Object) bool {
+	return e.frame.Load().yield
+}
+
+func (e *Environment) SetYield(yield func(Object) bool) {
+	e.setFrame(func(f *frame) { f.yield = yield })
+}
+
+// CallDepth returns the number of nested function calls active when this
+// environment was created; 0 at the top level.
+func (e *Environment) CallDepth() int {
+	return e.frame.Load().depth
+}
+
+func (e *Environment) SetCallDepth(depth int) {
+	e.setFrame(func(f *frame) { f.depth = depth })
+}
+
+// Limiter returns the limiter bound to the call frame of e, or nil.
+func (e *Environment) Limiter() Limiter {
+	return e.frame.Load().limiter
+}
+
+// SetLimiter binds limiter to e and the environments enclosed in it from
+// then on. A nil limiter is bound too, hiding the limiter of the outer
+// environment, so that a call frame only ever sees the limiter of its
+// caller.
+func (e *Environment) SetLimiter(limiter Limiter) {
+	e.setFrame(func(f *frame) { f.limiter = limiter })
+}
+
+// IO returns the streams for builtins evaluated in this environment, or nil
+// when none were set.
+func (e *Environment) IO() *IO {
+	return e.inherited().io
+}
+
+func (e *Environment) SetIO(io *IO) {
+	e.set(func() { e.io = io })
+}
+
+func (e *Environment) Tracer() Tracer {
+	return e.inherited().tracer
+}
+
+func (e *Environment) SetTracer(tracer Tracer) {
+	e.set(func() { e.tracer = tracer })
+}
+
+// Strict reports whether the type annotations of the program are checked
+// at run time.
+func (e *Environment) Strict() bool {
+	return e.inherited().strict
+}
+
+func (e *Environment) SetStrict(strict bool) {
+	e.set(func() { e.strict = strict })
+}
@@ -0,0 +1,218 @@
+package object
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"unicode/utf8"
+)
+
+// Iterator yields the elements of a sequence one at a time. Next returns
+// false once the sequence is exhausted.
+type Iterator interface {
+	Next() (Object, bool)
+}
+
+// Iterable is implemented by every object that can be consumed by a for
+// loop or by the collection builtins.
+type Iterable interface {
+	Iterator() Iterator
+}
+
+type Range struct {
+	Start int64
+	End   int64
+	Step  int64
+}
+
+func (r *Range) Type() ObjectType { return RANGE_OBJ }
+func (r *Range) Inspect() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("range(%d, %d)", r.Start, r.End)
+	}
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.End, r.Step)
+}
+
+// Generator runs a function body on its own goroutine, suspending it at
+// every yield until the next value is requested. Closing the generator, or
+// dropping the last reference to it, stops the body at its next yield.
+type Generator struct {
+	*generator
+}
+
+// generator is the state the body's goroutine shares with the Generator.
+// The goroutine holds no reference to the Generator itself, so that an
+// abandoned generator can be collected and its finalizer can stop the body.
+type generator struct {
+	mu      sync.Mutex
+	body    func(yield func(Object) bool) Object
+	started bool
+	done    bool
+	resume  chan struct{}
+	values  chan Object
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// NewGenerator returns a generator running body. The yield function body is
+// given returns false once the generator has been closed; body should then
+// return without yielding again.
+func NewGenerator(body func(yield func(Object) bool) Object) *Generator {
+	g := &Generator{&generator{
+		body:   body,
+		resume: make(chan struct{}),
+		values: make(chan Object),
+		stop:   make(chan struct{}),
+	}}
+	runtime.SetFinalizer(g, (*Generator).Close)
+	return g
+}
+
+func (g *Generator) Type() ObjectType { return GENERATOR_OBJ }
+func (g *Generator) Inspect() string  { return "generator" }
+
+func (g *generator) start() {
+	g.started = true
+
+	go func() {
+		defer close(g.values)
+		if !g.wait() {
+			return
+		}
+		result := g.body(func(value Object) bool {
+			return g.send(value) && g.wait()
+		})
+		if result != nil && result.Type() == ERROR_OBJ && g.send(result) {
+			g.wait()
+		}
+	}()
+}
+
+// send hands value to Next. It returns false if the generator is closed
+// first.
+func (g *generator) send(value Object) bool {
+	select {
+	case g.values <- value:
+		return true
+	case <-g.stop:
+		return false
+	}
+}
+
+// wait suspends the body until Next asks for the next value. It returns
+// false if the generator is closed first.
+func (g *generator) wait() bool {
+	select {
+	case <-g.resume:
+		return true
+	case <-g.stop:
+		return false
+	}
+}
+
+// Next resumes the generator until it yields its next value. An error
+// raised inside the generator body is delivered as a final value. Next is
+// safe for concurrent use.
+func (g *Generator) Next() (Object, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	select {
+	case <-g.stop:
+		g.done = true
+	default:
+	}
+	if g.done {
+		return nil, false
+	}
+
+	if !g.started {
+		g.start()
+	}
+
+	select {
+	case g.resume <- struct{}{}:
+	case <-g.stop:
+		g.done = true
+		return nil, false
+	}
+	value, ok := <-g.values
+	if !ok {
+		g.done = true
+		return nil, false
+	}
+
+	return value, true
+}
+
+// Close stops the generator. The body is abandoned at its next yield, and
+// Next returns no more values. Closing a closed generator does nothing.
+func (g *Generator) Close() {
+	g.once.Do(func() { close(g.stop) })
+}
+
+func (g *Generator) Iterator() Iterator { return g }
+
+type arrayIterator struct {
+	elements []Object
+	index    int
+}
+
+func (it *arrayIterator) Next() (Object, bool) {
+	if it.index >= len(it.elements) {
+		return nil, false
+	}
+	el := it.elements[it.index]
+	it.index++
+	return el, true
+}
+
+func (a *Array) Iterator() Iterator {
+	return &arrayIterator{elements: a.Elements}
+}
+
+// Iterator walks the keys of the hash in no particular order.
+func (h *Hash) Iterator() Iterator {
+	keys := make([]Object, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		keys = append(keys, pair.Key)
+	}
+	return &arrayIterator{elements: keys}
+}
+
+type stringIterator struct {
+	value    string
+	position int
+}
+
+func (it *stringIterator) Next() (Object, bool) {
+	if it.position >= len(it.value) {
+		return nil, false
+	}
+	r, size := utf8.DecodeRuneInString(it.value[it.position:])
+	it.position += size
+	return &String{Value: string(r)}, true
+}
+
+func (s *String) Iterator() Iterator {
+	return &stringIterator{value: s.Value}
+}
+
+type rangeIterator struct {
+	current int64
+	end     int64
+	step    int64
+}
+
+func (it *rangeIterator) Next() (Object, bool) {
+	if (it.step > 0 && it.current >= it.end) || (it.step < 0 && it.current <= it.end) {
+		return nil, false
+	}
+	value := &Integer{Value: it.current}
+	it.current += it.step
+	return value, true
+}
+
+func (r *Range) Iterator() Iterator {
+	return &rangeIterator{current: r.Start, end: r.End, step: r.Step}
+}
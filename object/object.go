@@ -2,7 +2,9 @@ package object
 
 import (
 	"WeekTwo/ast"
+	"WeekTwo/code"
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
 	"strings"
@@ -21,6 +23,18 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	ENUM_OBJ         = "ENUM"
+	ENUM_VALUE_OBJ   = "ENUM_VALUE"
+	MODULE_OBJ       = "MODULE"
+	RANGE_OBJ        = "RANGE"
+	GENERATOR_OBJ    = "GENERATOR"
+	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
+	TASK_OBJ         = "TASK"
+	CHANNEL_OBJ      = "CHANNEL"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CLOSURE_OBJ           = "CLOSURE"
 )
 
 type Object interface {
@@ -30,10 +44,41 @@ type Object interface {
 
 type BuiltinFunction func(args ...Object) Object
 
+// LimitedBuiltinFunction is a builtin function that is given the limiter of
+// the evaluation calling it, or nil if there is none.
+type LimitedBuiltinFunction func(limiter Limiter, args ...Object) Object
+
 type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Generator  bool
+	Name       string
+
+	// The annotations of the function literal, checked in strict mode.
+	ParameterTypes []ast.Type
+	ReturnType     ast.Type
+}
+
+type Quote struct {
+	Node ast.Node
+}
+
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+}
+
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
 }
 
 type Array struct {
@@ -60,10 +105,33 @@ type ReturnValue struct {
 
 type Error struct {
 	Message string
+	// Stack lists the function calls active when the error was raised,
+	// innermost first.
+	Stack []StackFrame
+}
+
+// StackFrame records one function call: the name the function was bound to
+// with let, if any, where it was called and with how many arguments.
+type StackFrame struct {
+	Function string
+	File     string
+	Line     int
+	Column   int
+	Args     int
+}
+
+// LimitError is returned when an evaluation exceeds one of its execution
+// limits. Its type is ERROR_OBJ, so it propagates like any other error.
+type LimitError struct {
+	Limit   string
+	Message string
 }
 
 type Builtin struct {
 	Fn BuiltinFunction
+	// Limited, if set, is called instead of Fn by evaluations that have a
+	// limiter.
+	Limited LimitedBuiltinFunction
 }
 
 type HashKey struct {
@@ -84,6 +152,24 @@ type Hashable interface {
 	HashKey() HashKey
 }
 
+type Enum struct {
+	Name     string
+	Variants []*ast.EnumVariant
+}
+
+type Module struct {
+	Name    string // the path as written in the import statement
+	Path    string // the resolved file path
+	Env     *Environment
+	Exports map[string]Object
+}
+
+type EnumValue struct {
+	Enum    *Enum
+	Variant string
+	Fields  []Object
+}
+
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
 func (h *Hash) Inspect() string {
 	var out bytes.Buffer
@@ -115,6 +201,54 @@ func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 
+// Traceback formats the stack of the error with the most recent call last.
+func (e *Error) Traceback() string {
+	var out bytes.Buffer
+
+	out.WriteString("Traceback (most recent call last):\n")
+	for i := len(e.Stack) - 1; i >= 0; i-- {
+		out.WriteString("  " + e.Stack[i].String() + "\n")
+
+		// Collapse runs of identical frames left by deep recursion.
+		repeated := 0
+		for i > 0 && e.Stack[i-1] == e.Stack[i] {
+			repeated++
+			i--
+		}
+		if repeated > 0 {
+			out.WriteString(fmt.Sprintf("  [previous frame repeated %d more times]\n", repeated))
+		}
+	}
+	out.WriteString(e.Inspect())
+
+	return out.String()
+}
+
+func (sf StackFrame) String() string {
+	name := sf.Function
+	if name == "" {
+		name = "<anonymous>"
+	}
+
+	arguments := "arguments"
+	if sf.Args == 1 {
+		arguments = "argument"
+	}
+
+	location := fmt.Sprintf("line %d, column %d", sf.Line, sf.Column)
+	if sf.File != "" {
+		location = fmt.Sprintf("%s:%d:%d", sf.File, sf.Line, sf.Column)
+	}
+	if sf.Line == 0 {
+		location = "unknown location"
+	}
+
+	return fmt.Sprintf("at %s, in %s with %d %s", location, name, sf.Args, arguments)
+}
+
+func (le *LimitError) Inspect() string  { return "ERROR: " + le.Message }
+func (le *LimitError) Type() ObjectType { return ERROR_OBJ }
+
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
@@ -134,6 +268,38 @@ func (f *Function) Inspect() string {
 	return out.String()
 }
 
+func (q *Quote) Type() ObjectType { return QUOTE_OBJ }
+func (q *Quote) Inspect() string  { return "QUOTE(" + q.Node.String() + ")" }
+
+func (m *Macro) Type() ObjectType { return MACRO_OBJ }
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("macro")
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+
+	return out.String()
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+func (c *Closure) Type() ObjectType { return CLOSURE_OBJ }
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
 func (s *String) Type() ObjectType { return STRING_OBJ }
 func (s *String) Inspect() string  { return s.Value }
 
@@ -156,6 +322,41 @@ func (a *Array) Inspect() string {
 	return out.String()
 }
 
+func (e *Enum) Type() ObjectType { return ENUM_OBJ }
+func (e *Enum) Inspect() string {
+	var out bytes.Buffer
+
+	variants := []string{}
+	for _, v := range e.Variants {
+		variants = append(variants, v.String())
+	}
+
+	out.WriteString("enum ")
+	out.WriteString(e.Name)
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(variants, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+func (ev *EnumValue) Type() ObjectType { return ENUM_VALUE_OBJ }
+func (ev *EnumValue) Inspect() string {
+	if len(ev.Fields) == 0 {
+		return ev.Variant
+	}
+
+	fields := []string{}
+	for _, f := range ev.Fields {
+		fields = append(fields, f.Inspect())
+	}
+
+	return ev.Variant + "(" + strings.Join(fields, ", ") + ")"
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return "module " + m.Name }
+
 func (b *Boolean) HashKey() HashKey {
 	var value uint64
 	if b.Value {
@@ -176,3 +377,23 @@ func (s *String) HashKey() HashKey {
 	h.Write([]byte(s.Value))
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
+
+// HashKey identifies the value the way == compares it: by the enum it
+// belongs to, not just that enum's name, its variant, and its fields, which
+// are compared by hash key when they have one and by identity otherwise.
+func (ev *EnumValue) HashKey() HashKey {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%p::%s", ev.Enum, ev.Variant)
+
+	for _, f := range ev.Fields {
+		if hashable, ok := f.(Hashable); ok {
+			key := hashable.HashKey()
+			h.Write([]byte(key.Type))
+			binary.Write(h, binary.LittleEndian, key.Value)
+		} else {
+			fmt.Fprintf(h, "%p", f)
+		}
+	}
+
+	return HashKey{Type: ev.Type(), Value: h.Sum64()}
+}
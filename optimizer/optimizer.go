@@ -0,0 +1,350 @@
+package optimizer
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/token"
+	"strconv"
+)
+
+// Options selects the passes Optimize runs.
+type Options struct {
+	InlineConstants       bool
+	FoldConstants         bool
+	EliminateDeadBranches bool
+}
+
+// AllPasses enables every pass.
+var AllPasses = Options{
+	InlineConstants:       true,
+	FoldConstants:         true,
+	EliminateDeadBranches: true,
+}
+
+// Optimize rewrites program in place and returns it. Each pass preserves
+// the result of evaluating the program. Inlining runs first so that the
+// inlined constants can be folded, and folding runs before dead branch
+// elimination so that conditions such as 1 < 2 are known.
+func Optimize(program *ast.Program, options Options) *ast.Program {
+	if options.InlineConstants {
+		InlineConstants(program)
+	}
+	if options.FoldConstants {
+		FoldConstants(program)
+	}
+	if options.EliminateDeadBranches {
+		EliminateDeadBranches(program)
+	}
+	return program
+}
+
+// FoldConstants replaces prefix and infix expressions whose operands are
+// literals with the literal they evaluate to. Expressions that would fail
+// at runtime, such as division by zero, are left alone.
+func FoldConstants(program *ast.Program) {
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case *ast.InfixExpression:
+			if folded := foldInfix(node); folded != nil {
+				return folded
+			}
+		case *ast.PrefixExpression:
+			if folded := foldPrefix(node); folded != nil {
+				return folded
+			}
+		}
+		return node
+	})
+}
+
+func foldInfix(node *ast.InfixExpression) ast.Expression {
+	tok := node.Token
+
+	switch left := node.Left.(type) {
+	case *ast.IntegerLiteral:
+		right, ok := node.Right.(*ast.IntegerLiteral)
+		if !ok {
+			return nil
+		}
+		l, r := left.Value, right.Value
+		switch node.Operator {
+		case "+":
+			return integerLiteral(tok, l+r)
+		case "-":
+			return integerLiteral(tok, l-r)
+		case "*":
+			return integerLiteral(tok, l*r)
+		case "/":
+			if r == 0 {
+				return nil
+			}
+			return integerLiteral(tok, l/r)
+		case "<":
+			return booleanLiteral(tok, l < r)
+		case ">":
+			return booleanLiteral(tok, l > r)
+		case "==":
+			return booleanLiteral(tok, l == r)
+		case "!=":
+			return booleanLiteral(tok, l != r)
+		}
+	case *ast.StringLiteral:
+		right, ok := node.Right.(*ast.StringLiteral)
+		if !ok || node.Operator != "+" {
+			return nil
+		}
+		return stringLiteral(tok, left.Value+right.Value)
+	case *ast.Boolean:
+		right, ok := node.Right.(*ast.Boolean)
+		if !ok {
+			return nil
+		}
+		switch node.Operator {
+		case "==":
+			return booleanLiteral(tok, left.Value == right.Value)
+		case "!=":
+			return booleanLiteral(tok, left.Value != right.Value)
+		}
+	}
+
+	return nil
+}
+
+func foldPrefix(node *ast.PrefixExpression) ast.Expression {
+	tok := node.Token
+
+	switch right := node.Right.(type) {
+	case *ast.IntegerLiteral:
+		switch node.Operator {
+		case "-":
+			return integerLiteral(tok, -right.Value)
+		case "!":
+			return booleanLiteral(tok, false)
+		}
+	case *ast.StringLiteral:
+		if node.Operator == "!" {
+			return booleanLiteral(tok, false)
+		}
+	case *ast.Boolean:
+		if node.Operator == "!" {
+			return booleanLiteral(tok, !right.Value)
+		}
+	}
+
+	return nil
+}
+
+// EliminateDeadBranches replaces if expressions whose condition is a
+// literal with the branch that would be taken.
+func EliminateDeadBranches(program *ast.Program) {
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case *ast.IfExpression:
+			if taken, ok := takenBranch(node); ok && taken != nil && len(taken.Statements) == 1 {
+				if stmt, ok := taken.Statements[0].(*ast.ExpressionStatement); ok {
+					return stmt.Expression
+				}
+			}
+		case *ast.BlockStatement:
+			node.Statements = spliceBranches(node.Statements)
+		case *ast.Program:
+			node.Statements = spliceBranches(node.Statements)
+		}
+		return node
+	})
+}
+
+// spliceBranches replaces if statements with constant conditions by the
+// statements of the branch taken. Blocks do not introduce scopes, so this
+// keeps every binding where it was. An if statement that produces no
+// statements is only removed when it is not the last statement, whose
+// value is the value of the whole block.
+func spliceBranches(statements []ast.Statement) []ast.Statement {
+	result := make([]ast.Statement, 0, len(statements))
+
+	for i, stmt := range statements {
+		last := i == len(statements)-1
+
+		exprStmt, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			result = append(result, stmt)
+			continue
+		}
+		ifExpr, ok := exprStmt.Expression.(*ast.IfExpression)
+		if !ok {
+			result = append(result, stmt)
+			continue
+		}
+		taken, ok := takenBranch(ifExpr)
+		if !ok {
+			result = append(result, stmt)
+			continue
+		}
+
+		if taken == nil || len(taken.Statements) == 0 {
+			if last {
+				result = append(result, stmt)
+			}
+			continue
+		}
+
+		result = append(result, taken.Statements...)
+	}
+
+	return result
+}
+
+// takenBranch returns the branch an if expression with a literal condition
+// takes, which is nil when the condition is false and there is no else.
+func takenBranch(node *ast.IfExpression) (*ast.BlockStatement, bool) {
+	var truthy bool
+
+	switch condition := node.Condition.(type) {
+	case *ast.Boolean:
+		truthy = condition.Value
+	case *ast.IntegerLiteral, *ast.StringLiteral:
+		truthy = true
+	default:
+		return nil, false
+	}
+
+	if truthy {
+		return node.Consequence, true
+	}
+	return node.Alternative, true
+}
+
+// InlineConstants replaces identifiers bound by a top-level let statement to
+// an integer, string or boolean literal with that literal. Only names that
+// are declared nowhere else in the program are inlined, and only where they
+// are used after their let statement. The let statements are kept, so the
+// names stay defined for later programs run in the same environment; but
+// the inlined uses do not follow if a later program binds a name again, so
+// programs such as REPL inputs that share an environment should not be
+// inlined.
+func InlineConstants(program *ast.Program) {
+	declarations := countDeclarations(program)
+	quoted := quotedIdentifiers(program)
+
+	constants := make(map[string]ast.Expression)
+
+	for _, stmt := range program.Statements {
+		if len(constants) > 0 {
+			ast.Modify(stmt, func(node ast.Node) ast.Node {
+				ident, ok := node.(*ast.Identifier)
+				if !ok || quoted[ident] {
+					return node
+				}
+				if value, ok := constants[ident.Value]; ok {
+					return copyLiteral(value, ident.Token)
+				}
+				return node
+			})
+		}
+
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok || declarations[let.Name.Value] != 1 {
+			continue
+		}
+		switch let.Value.(type) {
+		case *ast.IntegerLiteral, *ast.StringLiteral, *ast.Boolean:
+			constants[let.Name.Value] = let.Value
+		}
+	}
+}
+
+func countDeclarations(program *ast.Program) map[string]int {
+	declarations := make(map[string]int)
+
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			declarations[node.Name.Value]++
+		case *ast.FunctionLiteral:
+			for _, param := range node.Parameters {
+				declarations[param.Value]++
+			}
+		case *ast.MacroLiteral:
+			for _, param := range node.Parameters {
+				declarations[param.Value]++
+			}
+		case *ast.ForExpression:
+			declarations[node.Variable.Value]++
+		case *ast.MatchExpression:
+			for _, arm := range node.Arms {
+				for _, binding := range arm.Bindings {
+					declarations[binding.Value]++
+				}
+			}
+		case *ast.SelectExpression:
+			for _, c := range node.Cases {
+				if c.Binding != nil {
+					declarations[c.Binding.Value]++
+				}
+			}
+		case *ast.ImportStatement:
+			declarations[node.Alias.Value]++
+		case *ast.EnumStatement:
+			declarations[node.Name.Value]++
+			for _, variant := range node.Variants {
+				declarations[variant.Name.Value]++
+			}
+		}
+		return node
+	})
+
+	return declarations
+}
+
+// quotedIdentifiers returns the identifiers inside calls to quote, which
+// are code rather than references to values.
+func quotedIdentifiers(program *ast.Program) map[*ast.Identifier]bool {
+	quoted := make(map[*ast.Identifier]bool)
+
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || call.Function.TokenLiteral() != "quote" {
+			return node
+		}
+		for _, arg := range call.Arguments {
+			ast.Modify(arg, func(node ast.Node) ast.Node {
+				if ident, ok := node.(*ast.Identifier); ok {
+					quoted[ident] = true
+				}
+				return node
+			})
+		}
+		return node
+	})
+
+	return quoted
+}
+
+func copyLiteral(value ast.Expression, at token.Token) ast.Expression {
+	switch value := value.(type) {
+	case *ast.IntegerLiteral:
+		return integerLiteral(at, value.Value)
+	case *ast.StringLiteral:
+		return stringLiteral(at, value.Value)
+	case *ast.Boolean:
+		return booleanLiteral(at, value.Value)
+	}
+	return value
+}
+
+func integerLiteral(at token.Token, value int64) *ast.IntegerLiteral {
+	tok := token.Token{Type: token.INT, Literal: strconv.FormatInt(value, 10), Line: at.Line, Column: at.Column}
+	return &ast.IntegerLiteral{Token: tok, Value: value}
+}
+
+func stringLiteral(at token.Token, value string) *ast.StringLiteral {
+	tok := token.Token{Type: token.STRING, Literal: value, Line: at.Line, Column: at.Column}
+	return &ast.StringLiteral{Token: tok, Value: value}
+}
+
+func booleanLiteral(at token.Token, value bool) *ast.Boolean {
+	tok := token.Token{Type: token.FALSE, Literal: "false", Line: at.Line, Column: at.Column}
+	if value {
+		tok.Type, tok.Literal = token.TRUE, "true"
+	}
+	return &ast.Boolean{Token: tok, Value: value}
+}
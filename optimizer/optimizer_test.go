@@ -0,0 +1,161 @@
+package optimizer
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/evaluator"
+	"WeekTwo/lexer"
+	"WeekTwo/object"
+	"WeekTwo/parser"
+	"io"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func TestFoldConstants(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`60 * 60 * 24`, `86400`},
+		{`"prefix" + "suffix"`, `prefixsuffix`},
+		{`-(2 + 3)`, `-5`},
+		{`!true`, `false`},
+		{`!5`, `false`},
+		{`1 < 2 == true`, `true`},
+		{`10 / 0`, `(10 / 0)`},
+		{`x * (2 + 3)`, `(x * 5)`},
+		{`"a" - "b"`, `(a - b)`},
+		{`1 + true`, `(1 + true)`},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		FoldConstants(program)
+		if program.String() != tt.expected {
+			t.Errorf("%q: got=%q, want=%q", tt.input, program.String(), tt.expected)
+		}
+		testEquivalent(t, tt.input, program)
+	}
+}
+
+func TestEliminateDeadBranches(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`if (true) { 1 } else { 2 }`, `1`},
+		{`if (false) { 1 } else { 2 }`, `2`},
+		{`let x = if (1) { "a" } else { "b" };`, `let x = a;`},
+		{`if (false) { 1 }; 2`, `2`},
+		{`if (false) { 1 }`, `iffalse 1`},
+		{`if (true) { let a = 1; a }`, `let a = 1;a`},
+		{`if (x) { 1 } else { 2 }`, `ifx 1else 2`},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		EliminateDeadBranches(program)
+		if program.String() != tt.expected {
+			t.Errorf("%q: got=%q, want=%q", tt.input, program.String(), tt.expected)
+		}
+		testEquivalent(t, tt.input, program)
+	}
+}
+
+func TestInlineConstants(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let day = 86400; day * 2`, `let day = 86400;(86400 * 2)`},
+		{`let name = "m"; let f = fn() { name };`, `let name = m;let f = fn(m);`},
+		{`let x = 1; let x = 2; x`, `let x = 1;let x = 2;x`},
+		{`let x = 1; let f = fn(x) { x };`, `let x = 1;let f = fn(xx);`},
+		{`let f = fn() { x }; let x = 1;`, `let f = fn(x);let x = 1;`},
+		{`let x = [1]; x`, `let x = [1];x`},
+		{`let x = 1; quote(x)`, `let x = 1;quote(x)`},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		InlineConstants(program)
+		if program.String() != tt.expected {
+			t.Errorf("%q: got=%q, want=%q", tt.input, program.String(), tt.expected)
+		}
+		testEquivalent(t, tt.input, program)
+	}
+}
+
+// equivalenceInputs are evaluated with and without each pass; the results
+// must be identical.
+var equivalenceInputs = []string{
+	`60 * 60 * 24`,
+	`"prefix" + "suffix"`,
+	`let seconds = 60; let minutes = 60; seconds * minutes * 24`,
+	`if (1 < 2) { 10 } else { 20 }`,
+	`if (1 > 2) { 10 }`,
+	`if (1 > 2) { 10 }; 5`,
+	`let f = fn(n) { if (true) { return n * 2; } 0 }; f(4)`,
+	`let f = fn(n) { if (false) { return 1; }; n }; f(4)`,
+	`let x = 5; let f = fn(y) { x + y }; f(x)`,
+	`let x = 5; let x = x + 1; x`,
+	`let limit = 3; let count = fn(n) { if (n == limit) { n } else { count(n + 1) } }; count(0)`,
+	`let s = "a"; s.upper() + s`,
+	`let debug = false; let log = []; if (debug) { let log = push(log, 1); }; log`,
+	`let g = fn() { if (true) { let a = 2; }; a }; g()`,
+	`!(1 == 1) == false`,
+	`10 / (5 - 5)`,
+	`-"a"`,
+	`let k = "key"; {k: 1}[k]`,
+	`let one = 1; for (x in [1, 2]) { if (x == one) { puts(x) } }`,
+	`let n = 2; let f = fn() { yield n; yield n * 2; }; collect(f())`,
+}
+
+func TestSemanticEquivalence(t *testing.T) {
+	passes := map[string]Options{
+		"inline": {InlineConstants: true},
+		"fold":   {FoldConstants: true},
+		"dead":   {EliminateDeadBranches: true},
+		"all":    AllPasses,
+	}
+
+	for name, options := range passes {
+		for _, input := range equivalenceInputs {
+			want := evaluate(parse(t, input))
+			got := evaluate(Optimize(parse(t, input), options))
+			if got != want {
+				t.Errorf("%s: %q changed meaning. got=%q, want=%q", name, input, got, want)
+			}
+		}
+	}
+}
+
+// testEquivalent checks that the optimized program evaluates to what input
+// does.
+func testEquivalent(t *testing.T, input string, optimized *ast.Program) {
+	t.Helper()
+	if got, want := evaluate(optimized), evaluate(parse(t, input)); got != want {
+		t.Errorf("%q changed meaning. got=%q, want=%q", input, got, want)
+	}
+}
+
+func evaluate(program *ast.Program) string {
+	env := object.NewEnvironment()
+	env.SetIO(object.NewIO(strings.NewReader(""), io.Discard, io.Discard))
+
+	evaluated := evaluator.Eval(program, env)
+	if evaluated == nil {
+		return "<nil>"
+	}
+	return evaluated.Inspect()
+}
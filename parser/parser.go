@@ -5,7 +5,9 @@ import (
 	"WeekTwo/lexer"
 	"WeekTwo/token"
 	"fmt"
+	"path"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -31,15 +33,31 @@ var precedences = map[token.TokenType]int{
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
+}
+
+// Error is a parse error and the token it was found at.
+type Error struct {
+	Message string
+	Token   token.Token
 }
 
 type Parser struct {
 	lex            *lexer.Lexer
 	errors         []string
+	errorTokens    []token.Token
+	warnings       []string
 	curToken       token.Token
 	peekToken      token.Token
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	enums    map[string][]string // enum name -> variant names, in declaration order
+	variants map[string]string   // variant name -> enum name
+	matches  []*ast.MatchExpression
+
+	functionDepth int
+	yieldSeen     bool // a yield was parsed in the innermost function literal
 }
 
 type (
@@ -49,8 +67,11 @@ type (
 
 func New(lex *lexer.Lexer) *Parser {
 	p := &Parser{
-		lex:    lex,
-		errors: []string{},
+		lex:      lex,
+		errors:   []string{},
+		warnings: []string{},
+		enums:    make(map[string][]string),
+		variants: make(map[string]string),
 	}
 	p.nextToken()
 	p.nextToken()
@@ -67,6 +88,12 @@ func New(lex *lexer.Lexer) *Parser {
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
+	p.registerPrefix(token.YIELD, p.parseYieldExpression)
+	p.registerPrefix(token.FOR, p.parseForExpression)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
+	p.registerPrefix(token.SPAWN, p.parseSpawnExpression)
+	p.registerPrefix(token.SELECT, p.parseSelectExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -79,6 +106,7 @@ func New(lex *lexer.Lexer) *Parser {
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	return p
 }
@@ -95,14 +123,33 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// ErrorDetails returns the errors together with their positions, in the
+// order of Errors.
+func (p *Parser) ErrorDetails() []Error {
+	details := make([]Error, len(p.errors))
+	for i, msg := range p.errors {
+		details[i] = Error{Message: msg, Token: p.errorTokens[i]}
+	}
+	return details
+}
+
+func (p *Parser) addError(tok token.Token, msg string) {
+	p.errors = append(p.errors, msg)
+	p.errorTokens = append(p.errorTokens, tok)
+}
+
+func (p *Parser) Warnings() []string {
+	return p.warnings
+}
+
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(p.peekToken, msg)
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken, msg)
 }
 
 func (p *Parser) nextToken() {
@@ -128,18 +175,21 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
-func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
+// Precedence returns the precedence of t as an infix operator, or LOWEST
+// if it is not one.
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
 		return p
 	}
 	return LOWEST
 }
 
+func (p *Parser) peekPrecedence() int {
+	return Precedence(p.peekToken.Type)
+}
+
 func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
-		return p
-	}
-	return LOWEST
+	return Precedence(p.curToken.Type)
 }
 
 func (p *Parser) parseBoolean() ast.Expression {
@@ -156,7 +206,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, msg)
 		return nil
 	}
 
@@ -208,6 +258,14 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		if stmt.Type = p.parseType(); stmt.Type == nil {
+			return nil
+		}
+	}
+
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -216,6 +274,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(LOWEST)
 
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
 	for !p.curTokenIs(token.SEMICOLON) && !p.curTokenIs(token.EOF) {
 		p.nextToken()
 	}
@@ -236,6 +298,72 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.LPAREN) {
+		p.nextToken()
+		return &ast.MethodCallExpression{
+			Token:     exp.Token,
+			Receiver:  object,
+			Method:    exp.Property,
+			Arguments: p.parseExpressionList(token.RPAREN),
+		}
+	}
+
+	return exp
+}
+
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.AS) {
+		p.nextToken()
+
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		stmt.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	} else {
+		name := strings.TrimSuffix(path.Base(stmt.Path.Value), path.Ext(stmt.Path.Value))
+		stmt.Alias = &ast.Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseExportStatement() *ast.ExportStatement {
+	stmt := &ast.ExportStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+
+	stmt.Statement = p.parseLetStatement()
+	if stmt.Statement == nil {
+		return nil
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
@@ -243,7 +371,7 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	for !p.curTokenIs(token.SEMICOLON) {
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -385,13 +513,14 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 
 	p.nextToken()
 
-	for !p.curTokenIs(token.RBRACE) {
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
 		stmt := p.parseStatement()
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	block.Rbrace = p.curToken
 
 	return block
 }
@@ -423,6 +552,112 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	return identifiers
 }
 
+// parseTypedParameters parses the parameters of a function literal, each
+// of which may have a type annotation. The types are nil if none has one.
+func (p *Parser) parseTypedParameters() ([]*ast.Identifier, []ast.Type) {
+	identifiers := []*ast.Identifier{}
+	var types []ast.Type
+	annotated := false
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return identifiers, nil
+	}
+
+	for {
+		p.nextToken()
+		identifiers = append(identifiers, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+		var t ast.Type
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			if t = p.parseType(); t == nil {
+				return nil, nil
+			}
+			annotated = true
+		}
+		types = append(types, t)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil, nil
+	}
+
+	if !annotated {
+		types = nil
+	}
+	return identifiers, types
+}
+
+// parseType parses the type annotation that starts at the current token.
+func (p *Parser) parseType() ast.Type {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+	case token.LBRACKET:
+		t := &ast.ArrayType{Token: p.curToken}
+		p.nextToken()
+		if t.Element = p.parseType(); t.Element == nil {
+			return nil
+		}
+		if !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		return t
+	case token.LBRACE:
+		t := &ast.HashType{Token: p.curToken}
+		p.nextToken()
+		if t.Key = p.parseType(); t.Key == nil {
+			return nil
+		}
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		if t.Value = p.parseType(); t.Value == nil {
+			return nil
+		}
+		if !p.expectPeek(token.RBRACE) {
+			return nil
+		}
+		return t
+	case token.FUNCTION:
+		t := &ast.FunctionType{Token: p.curToken, Parameters: []ast.Type{}}
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		for !p.peekTokenIs(token.RPAREN) {
+			if len(t.Parameters) > 0 && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+			p.nextToken()
+			param := p.parseType()
+			if param == nil {
+				return nil
+			}
+			t.Parameters = append(t.Parameters, param)
+		}
+		p.nextToken()
+		if p.peekTokenIs(token.RARROW) {
+			p.nextToken()
+			p.nextToken()
+			if t.Return = p.parseType(); t.Return == nil {
+				return nil
+			}
+		}
+		return t
+	}
+
+	p.addError(p.curToken, fmt.Sprintf("expected a type, got %s", p.curToken.Type))
+	return nil
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
@@ -430,6 +665,43 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
+	lit.Parameters, lit.ParameterTypes = p.parseTypedParameters()
+	if lit.Parameters == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.RARROW) {
+		p.nextToken()
+		p.nextToken()
+		if lit.ReturnType = p.parseType(); lit.ReturnType == nil {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	outerYieldSeen := p.yieldSeen
+	p.yieldSeen = false
+	p.functionDepth++
+
+	lit.Body = p.parseBlockStatement()
+	lit.IsGenerator = p.yieldSeen
+
+	p.functionDepth--
+	p.yieldSeen = outerYieldSeen
+
+	return lit
+}
+
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
 	lit.Parameters = p.parseFunctionParameters()
 
 	if !p.expectPeek(token.LBRACE) {
@@ -441,6 +713,138 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
+func (p *Parser) parseYieldExpression() ast.Expression {
+	exp := &ast.YieldExpression{Token: p.curToken}
+
+	if p.functionDepth == 0 {
+		p.addError(exp.Token, "yield outside of function")
+	}
+	p.yieldSeen = true
+
+	p.nextToken()
+	exp.Value = p.parseExpression(LOWEST)
+
+	return exp
+}
+
+func (p *Parser) parseForExpression() ast.Expression {
+	exp := &ast.ForExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	exp.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	exp.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	exp.Body = p.parseBlockStatement()
+
+	return exp
+}
+
+func (p *Parser) parseSpawnExpression() ast.Expression {
+	exp := &ast.SpawnExpression{Token: p.curToken}
+
+	p.nextToken()
+	exp.Call = p.parseExpression(PREFIX)
+
+	return exp
+}
+
+func (p *Parser) parseSelectCase() *ast.SelectCase {
+	c := &ast.SelectCase{Token: p.curToken}
+
+	if p.curToken.Literal != "_" {
+		call, ok := p.parseExpression(LOWEST).(*ast.CallExpression)
+		if !ok {
+			p.addError(c.Token, "select case must be receive(channel), send(channel, value) or _")
+			return nil
+		}
+
+		switch {
+		case call.Function.String() == "receive" && len(call.Arguments) == 1:
+			c.Channel = call.Arguments[0]
+			if p.peekTokenIs(token.AS) {
+				p.nextToken()
+				if !p.expectPeek(token.IDENT) {
+					return nil
+				}
+				c.Binding = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			}
+		case call.Function.String() == "send" && len(call.Arguments) == 2:
+			c.Channel = call.Arguments[0]
+			c.Value = call.Arguments[1]
+		default:
+			p.addError(c.Token, "select case must be receive(channel), send(channel, value) or _")
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	if p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		c.Body = p.parseBlockStatement()
+		return c
+	}
+
+	p.nextToken()
+	stmt := &ast.ExpressionStatement{Token: p.curToken, Expression: p.parseExpression(LOWEST)}
+	c.Body = &ast.BlockStatement{Token: stmt.Token, Statements: []ast.Statement{stmt}}
+
+	return c
+}
+
+func (p *Parser) parseSelectExpression() ast.Expression {
+	exp := &ast.SelectExpression{Token: p.curToken, Cases: []*ast.SelectCase{}}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		c := p.parseSelectCase()
+		if c == nil {
+			return nil
+		}
+		exp.Cases = append(exp.Cases, c)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return exp
+}
+
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseExpressionList(token.RPAREN)
@@ -451,10 +855,201 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+func (p *Parser) parseEnumStatement() *ast.EnumStatement {
+	stmt := &ast.EnumStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Variants = []*ast.EnumVariant{}
+	names := []string{}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		variant := &ast.EnumVariant{
+			Name:   &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+			Fields: []*ast.Identifier{},
+		}
+
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			variant.Fields = p.parseFunctionParameters()
+		}
+
+		for _, name := range names {
+			if name == variant.Name.Value {
+				msg := fmt.Sprintf("duplicate variant %s in enum %s", name, stmt.Name.Value)
+				p.addError(variant.Name.Token, msg)
+			}
+		}
+
+		stmt.Variants = append(stmt.Variants, variant)
+		names = append(names, variant.Name.Value)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	p.DeclareEnum(stmt.Name.Value, names)
+
+	return stmt
+}
+
+// DeclareEnum makes the enum called name with the given variants known to
+// the exhaustiveness check, as if the program declared it. It lets a REPL
+// check matches on enums declared by earlier inputs.
+func (p *Parser) DeclareEnum(name string, variants []string) {
+	p.enums[name] = variants
+	for _, variant := range variants {
+		p.variants[variant] = name
+	}
+}
+
+// Enums returns the variants of the enums declared so far, by enum name.
+func (p *Parser) Enums() map[string][]string {
+	return p.enums
+}
+
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{
+		Variant:  &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+		Bindings: []*ast.Identifier{},
+	}
+
+	if p.peekTokenIs(token.LPAREN) {
+		p.nextToken()
+		arm.Bindings = p.parseFunctionParameters()
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	if p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		arm.Body = p.parseBlockStatement()
+		return arm
+	}
+
+	p.nextToken()
+	stmt := &ast.ExpressionStatement{Token: p.curToken, Expression: p.parseExpression(LOWEST)}
+	arm.Body = &ast.BlockStatement{Token: stmt.Token, Statements: []ast.Statement{stmt}}
+
+	return arm
+}
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	exp := &ast.MatchExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	exp.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	exp.Arms = []*ast.MatchArm{}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		exp.Arms = append(exp.Arms, arm)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	p.matches = append(p.matches, exp)
+
+	return exp
+}
+
+// checkMatchExhaustiveness warns about match expressions over an enum
+// declared in this program or with DeclareEnum that neither list every
+// variant nor have a catch-all arm.
+func (p *Parser) checkMatchExhaustiveness() {
+	for _, match := range p.matches {
+		enumName := ""
+		covered := make(map[string]bool)
+		wildcard := false
+
+		for _, arm := range match.Arms {
+			if arm.Variant.Value == "_" {
+				wildcard = true
+				continue
+			}
+			covered[arm.Variant.Value] = true
+			if name, ok := p.variants[arm.Variant.Value]; ok {
+				enumName = name
+			}
+		}
+
+		if wildcard || enumName == "" {
+			continue
+		}
+
+		missing := []string{}
+		for _, variant := range p.enums[enumName] {
+			if !covered[variant] {
+				missing = append(missing, variant)
+			}
+		}
+
+		if len(missing) > 0 {
+			msg := fmt.Sprintf("non-exhaustive match on %s: missing %s", enumName, strings.Join(missing, ", "))
+			p.warnings = append(p.warnings, msg)
+		}
+	}
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
 		return p.parseLetStatement()
+	case token.ENUM:
+		return p.parseEnumStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
 	default:
@@ -474,5 +1069,7 @@ func (p *Parser) ParseProgram() *ast.Program {
 		p.nextToken()
 	}
 
+	p.checkMatchExhaustiveness()
+
 	return program
 }
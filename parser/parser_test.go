@@ -211,6 +211,37 @@ func TestReturnStatements(testInput *testing.T) {
 	}
 }
 
+func TestReturnWithoutSemicolon(t *testing.T) {
+	lex := lexer.New("fn(x) { return x } (1); return 2")
+	p := New(lex)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+	if _, ok := program.Statements[0].(*ast.ExpressionStatement); !ok {
+		t.Errorf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	if _, ok := program.Statements[1].(*ast.ReturnStatement); !ok {
+		t.Errorf("program.Statements[1] is not ast.ReturnStatement. got=%T", program.Statements[1])
+	}
+}
+
+func TestUnterminatedBlock(t *testing.T) {
+	lex := lexer.New("if (x) { let y = 1;")
+	p := New(lex)
+	program := p.ParseProgram()
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+	exp := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+	if len(exp.Consequence.Statements) != 1 {
+		t.Errorf("consequence does not contain 1 statement. got=%d", len(exp.Consequence.Statements))
+	}
+}
+
 func TestIdentifierExpression(testInput *testing.T) {
 	input := "foobar;"
 
@@ -765,3 +796,457 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 		testFunc(value)
 	}
 }
+
+func TestEnumStatement(t *testing.T) {
+	input := "enum Shape { Circle(r), Rect(w, h), Empty };"
+
+	lex := lexer.New(input)
+	parser := New(lex)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.EnumStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.EnumStatement. got=%T", program.Statements[0])
+	}
+
+	if stmt.Name.Value != "Shape" {
+		t.Errorf("stmt.Name.Value not 'Shape'. got=%q", stmt.Name.Value)
+	}
+
+	expected := []struct {
+		name   string
+		fields []string
+	}{
+		{"Circle", []string{"r"}},
+		{"Rect", []string{"w", "h"}},
+		{"Empty", []string{}},
+	}
+
+	if len(stmt.Variants) != len(expected) {
+		t.Fatalf("wrong number of variants. got=%d", len(stmt.Variants))
+	}
+
+	for i, tt := range expected {
+		variant := stmt.Variants[i]
+		if variant.Name.Value != tt.name {
+			t.Errorf("variant[%d] name wrong. want=%q, got=%q", i, tt.name, variant.Name.Value)
+		}
+		if len(variant.Fields) != len(tt.fields) {
+			t.Fatalf("variant[%d] has wrong number of fields. got=%d", i, len(variant.Fields))
+		}
+		for j, field := range tt.fields {
+			testLiteralExpression(t, variant.Fields[j], field)
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match (s) { Circle(r) => r * r, Rect(w, h) => { w * h }, _ => 0 }`
+
+	lex := lexer.New(input)
+	parser := New(lex)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	match, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.MatchExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifierExpression(t, match.Subject, "s") {
+		return
+	}
+
+	if len(match.Arms) != 3 {
+		t.Fatalf("wrong number of arms. got=%d", len(match.Arms))
+	}
+
+	circle := match.Arms[0]
+	if circle.Variant.Value != "Circle" || len(circle.Bindings) != 1 {
+		t.Fatalf("arm[0] wrong. got=%q", circle.String())
+	}
+	body := circle.Body.Statements[0].(*ast.ExpressionStatement)
+	testInfixExpression(t, body.Expression, "r", "*", "r")
+
+	rect := match.Arms[1]
+	if rect.Variant.Value != "Rect" || len(rect.Bindings) != 2 {
+		t.Fatalf("arm[1] wrong. got=%q", rect.String())
+	}
+	body = rect.Body.Statements[0].(*ast.ExpressionStatement)
+	testInfixExpression(t, body.Expression, "w", "*", "h")
+
+	if match.Arms[2].Variant.Value != "_" {
+		t.Fatalf("arm[2] is not the catch-all arm. got=%q", match.Arms[2].String())
+	}
+}
+
+func TestMatchExhaustivenessWarnings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			"enum Shape { Circle(r), Rect(w, h) }; match (s) { Circle(r) => r }",
+			[]string{"non-exhaustive match on Shape: missing Rect"},
+		},
+		{
+			"enum Shape { Circle(r), Rect(w, h) }; match (s) { Circle(r) => r, Rect(w, h) => w }",
+			[]string{},
+		},
+		{
+			"enum Shape { Circle(r), Rect(w, h) }; match (s) { Circle(r) => r, _ => 0 }",
+			[]string{},
+		},
+		{
+			"match (s) { Circle(r) => r }",
+			[]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		lex := lexer.New(tt.input)
+		parser := New(lex)
+		parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		warnings := parser.Warnings()
+		if len(warnings) != len(tt.expected) {
+			t.Errorf("wrong number of warnings for %q. got=%v", tt.input, warnings)
+			continue
+		}
+		for i, msg := range tt.expected {
+			if warnings[i] != msg {
+				t.Errorf("warning[%d] wrong. want=%q, got=%q", i, msg, warnings[i])
+			}
+		}
+	}
+}
+
+func TestDeclareEnum(t *testing.T) {
+	first := New(lexer.New("enum Shape { Circle(r), Rect(w, h) };"))
+	first.ParseProgram()
+	checkParserErrors(t, first)
+
+	second := New(lexer.New("match (s) { Circle(r) => r }"))
+	for name, variants := range first.Enums() {
+		second.DeclareEnum(name, variants)
+	}
+	second.ParseProgram()
+	checkParserErrors(t, second)
+
+	warnings := second.Warnings()
+	if len(warnings) != 1 || warnings[0] != "non-exhaustive match on Shape: missing Rect" {
+		t.Errorf("wrong warnings. got=%q", warnings)
+	}
+}
+
+func TestImportAndExportStatements(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedPath  string
+		expectedAlias string
+	}{
+		{`import "lib/strings" as s;`, "lib/strings", "s"},
+		{`import "lib/strings.mk"`, "lib/strings.mk", "strings"},
+	}
+
+	for _, tt := range tests {
+		lex := lexer.New(tt.input)
+		parser := New(lex)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		stmt, ok := program.Statements[0].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ImportStatement. got=%T", program.Statements[0])
+		}
+		if stmt.Path.Value != tt.expectedPath {
+			t.Errorf("stmt.Path.Value not %q. got=%q", tt.expectedPath, stmt.Path.Value)
+		}
+		if stmt.Alias.Value != tt.expectedAlias {
+			t.Errorf("stmt.Alias.Value not %q. got=%q", tt.expectedAlias, stmt.Alias.Value)
+		}
+	}
+
+	lex := lexer.New("export let x = 5;")
+	parser := New(lex)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	stmt, ok := program.Statements[0].(*ast.ExportStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExportStatement. got=%T", program.Statements[0])
+	}
+	if !testLetStatement(t, stmt.Statement, "x") {
+		return
+	}
+	testLiteralExpression(t, stmt.Statement.Value, 5)
+}
+
+func TestMemberExpressionParsing(t *testing.T) {
+	input := "s.upper(x)[0]"
+
+	lex := lexer.New(input)
+	parser := New(lex)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	if stmt.Expression.String() != "(s.upper(x)[0])" {
+		t.Errorf("expression wrong. got=%q", stmt.Expression.String())
+	}
+
+	lex = lexer.New("s.name + 1")
+	parser = New(lex)
+	program = parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	stmt = program.Statements[0].(*ast.ExpressionStatement)
+	infix := stmt.Expression.(*ast.InfixExpression)
+	member, ok := infix.Left.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("infix.Left is not ast.MemberExpression. got=%T", infix.Left)
+	}
+	testIdentifierExpression(t, member.Object, "s")
+	testIdentifierExpression(t, member.Property, "name")
+}
+
+func TestMethodCallParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"arr.push(x).len()", "arr.push(x).len()"},
+		{"-arr.len()", "(-arr.len())"},
+		{"a.f(1, 2 * 3) + b", "(a.f(1, (2 * 3)) + b)"},
+		{"[1, 2].first()", "[1, 2].first()"},
+	}
+
+	for _, tt := range tests {
+		lex := lexer.New(tt.input)
+		parser := New(lex)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	lex := lexer.New("arr.push(1, 2)")
+	parser := New(lex)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	call, ok := stmt.Expression.(*ast.MethodCallExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.MethodCallExpression. got=%T", stmt.Expression)
+	}
+	testIdentifierExpression(t, call.Receiver, "arr")
+	testIdentifierExpression(t, call.Method, "push")
+	if len(call.Arguments) != 2 {
+		t.Fatalf("wrong length of arguments. got=%d", len(call.Arguments))
+	}
+	testLiteralExpression(t, call.Arguments[0], 1)
+	testLiteralExpression(t, call.Arguments[1], 2)
+}
+
+func TestGeneratorFunctionParsing(t *testing.T) {
+	tests := []struct {
+		input             string
+		expectedGenerator bool
+	}{
+		{"fn(n) { yield n; yield n + 1; }", true},
+		{"fn(n) { n }", false},
+		{"fn(n) { fn() { yield n } }", false},
+	}
+
+	for _, tt := range tests {
+		lex := lexer.New(tt.input)
+		parser := New(lex)
+		program := parser.ParseProgram()
+		checkParserErrors(t, parser)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+		if function.IsGenerator != tt.expectedGenerator {
+			t.Errorf("IsGenerator wrong for %q. want=%t, got=%t", tt.input, tt.expectedGenerator, function.IsGenerator)
+		}
+	}
+
+	lex := lexer.New("yield 1")
+	parser := New(lex)
+	parser.ParseProgram()
+	if len(parser.Errors()) != 1 || parser.Errors()[0] != "yield outside of function" {
+		t.Errorf("expected yield outside of function error. got=%v", parser.Errors())
+	}
+}
+
+func TestForExpressionParsing(t *testing.T) {
+	input := "for (x in range(10)) { puts(x) }"
+
+	lex := lexer.New(input)
+	parser := New(lex)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.ForExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.ForExpression. got=%T", stmt.Expression)
+	}
+
+	testIdentifierExpression(t, exp.Variable, "x")
+	if exp.Iterable.String() != "range(10)" {
+		t.Errorf("exp.Iterable wrong. got=%q", exp.Iterable.String())
+	}
+	if len(exp.Body.Statements) != 1 {
+		t.Errorf("exp.Body.Statements has wrong length. got=%d", len(exp.Body.Statements))
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { x + y; }`
+
+	lex := lexer.New(input)
+	parser := New(lex)
+	program := parser.ParseProgram()
+	checkParserErrors(t, parser)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MacroLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal parameters wrong. want 2, got=%d", len(macro.Parameters))
+	}
+
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements has not 1 statement. got=%d", len(macro.Body.Statements))
+	}
+
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement. got=%T", macro.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestSpawnAndSelectParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`spawn f(1, 2)`, `spawn f(1, 2)`},
+		{`spawn fn() { x }`, `spawn fn(x)`},
+		{`select { receive(ch) as v => v, send(out, 1) => { 2 }, _ => 3 }`,
+			`select { receive(ch) as v => v, send(out, 1) => 2, _ => 3 }`},
+		{`select { receive(ch) => 1 }`, `select { receive(ch) => 1 }`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestSelectCaseErrors(t *testing.T) {
+	p := New(lexer.New(`select { first(ch) => 1 }`))
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+	if errors[0] != "select case must be receive(channel), send(channel, value) or _" {
+		t.Errorf("wrong error. got=%q", errors[0])
+	}
+}
+
+func TestTypeAnnotationParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let x: int = 5;`, `let x: int = 5;`},
+		{`let xs: [string] = [];`, `let xs: [string] = [];`},
+		{`let h: {string: [int]} = {};`, `let h: {string: [int]} = {};`},
+		{`let f: fn(int, int) -> bool = g;`, `let f: fn(int, int) -> bool = g;`},
+		{`let f: fn() = g;`, `let f: fn() = g;`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New(`fn(a: int, b, c: fn(int) -> [int]) -> {string: bool} { a }`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	function := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if len(function.ParameterTypes) != 3 {
+		t.Fatalf("wrong number of parameter types. got=%d", len(function.ParameterTypes))
+	}
+	if function.ParameterTypes[0].String() != "int" || function.ParameterTypes[1] != nil ||
+		function.ParameterTypes[2].String() != "fn(int) -> [int]" {
+		t.Errorf("wrong parameter types. got=%v", function.ParameterTypes)
+	}
+	if function.ReturnType == nil || function.ReturnType.String() != "{string: bool}" {
+		t.Errorf("wrong return type. got=%v", function.ReturnType)
+	}
+
+	p = New(lexer.New(`fn(a, b) { a }`))
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	function = program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if function.ParameterTypes != nil || function.ReturnType != nil {
+		t.Errorf("expected no annotations. got=%v, %v", function.ParameterTypes, function.ReturnType)
+	}
+}
+
+func TestTypeAnnotationErrors(t *testing.T) {
+	p := New(lexer.New(`let x: 5 = 5;`))
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors")
+	}
+	if errors[0] != "expected a type, got INT" {
+		t.Errorf("wrong error. got=%q", errors[0])
+	}
+}
@@ -0,0 +1,211 @@
+package repl
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/lexer"
+	"WeekTwo/token"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const HELP = `:help                  show this help
+:env                   list the bindings of the session with their types
+:reset                 forget all bindings, macros and the transcript
+:load FILE             evaluate the program in FILE
+:save FILE             write the inputs evaluated so far to FILE
+:type EXPR             show the type the checker infers for EXPR
+:ast EXPR              show the syntax tree of EXPR
+:tokens EXPR           show the tokens of EXPR
+:time EXPR             evaluate EXPR and show how long it took
+
+Debugger commands:
+:break [FILE:]LINE     set a breakpoint at a line, or list the breakpoints
+:break FUNCTION        set a breakpoint at the calls of a function
+:clear [FILE:]LINE     clear a breakpoint, also :clear FUNCTION
+:watch [EXPR]          watch an expression, or show the watch expressions
+:unwatch N             stop watching the Nth watch expression
+:locals                show the variables in scope
+:step                  pause at the next statement
+While paused, also :next, :out, :continue and :stack.
+`
+
+// command runs line if it is a command, which starts with ':', and
+// reports whether it was one.
+func (s *session) command(line string) bool {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, ":") {
+		return false
+	}
+	if s.debug.command(line, s.env) {
+		return true
+	}
+
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case ":help":
+		io.WriteString(s.out, HELP)
+	case ":env":
+		s.printEnv()
+	case ":reset":
+		s.reset()
+	case ":load":
+		content, err := os.ReadFile(arg)
+		if err != nil {
+			fmt.Fprintln(s.out, err)
+			break
+		}
+		s.eval(string(content))
+	case ":save":
+		if err := os.WriteFile(arg, []byte(s.transcriptSource()), 0644); err != nil {
+			fmt.Fprintln(s.out, err)
+		}
+	case ":type":
+		s.printType(arg)
+	case ":ast":
+		if program, ok := s.parse(arg); ok {
+			printTree(s.out, "", reflect.ValueOf(program), 0)
+		}
+	case ":tokens":
+		lex := lexer.New(arg)
+		for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
+			fmt.Fprintf(s.out, "%d:%d\t%s\t%q\n", tok.Line, tok.Column, tok.Type, tok.Literal)
+		}
+	case ":time":
+		start := time.Now()
+		s.eval(arg)
+		fmt.Fprintf(s.out, "time: %s\n", time.Since(start))
+	default:
+		fmt.Fprintf(s.out, "unknown command %s, see :help\n", name)
+	}
+	return true
+}
+
+// printEnv prints the bindings of the session, sorted by name.
+func (s *session) printEnv() {
+	bindings := s.env.Bindings()
+	names := make([]string, 0, len(bindings))
+	for name := range bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := bindings[name]
+		fmt.Fprintf(s.out, "%s: %s = %s\n", name, value.Type(), value.Inspect())
+	}
+}
+
+// printType prints the type of the expression source, or the type errors
+// in it.
+func (s *session) printType(source string) {
+	program, ok := s.parse(source)
+	if !ok {
+		return
+	}
+	if len(program.Statements) != 1 {
+		io.WriteString(s.out, "usage: :type EXPR\n")
+		return
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		io.WriteString(s.out, "usage: :type EXPR\n")
+		return
+	}
+
+	if errors := s.types.Check(program); len(errors) != 0 {
+		for _, err := range errors {
+			io.WriteString(s.out, "type error: "+err.String()+"\n")
+		}
+		return
+	}
+	io.WriteString(s.out, s.types.TypeOf(stmt.Expression).String()+"\n")
+}
+
+// transcriptSource joins the inputs of the transcript into a program,
+// ending each with a semicolon so that it does not run into the next.
+func (s *session) transcriptSource() string {
+	var out strings.Builder
+	for _, input := range s.transcript {
+		out.WriteString(terminate(input) + "\n")
+	}
+	return out.String()
+}
+
+// terminate adds a semicolon after the last token of input unless it is
+// one already.
+func terminate(input string) string {
+	lex := lexer.New(input)
+	var last token.Token
+	for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
+		last = tok
+	}
+	if last.Type == "" || last.Type == token.SEMICOLON {
+		return input
+	}
+	// A string left open runs to the end of the input.
+	if lex.Unterminated() {
+		return input + "\";"
+	}
+
+	lines := strings.SplitAfter(input, "\n")
+	end := last.Column - 1 + len(last.Literal)
+	for _, line := range lines[:last.Line-1] {
+		end += len(line)
+	}
+	if last.Type == token.STRING {
+		end += 2 // the quotes
+	}
+	return input[:end] + ";" + input[end:]
+}
+
+// printTree prints the syntax tree node with its fields indented below it.
+// Tokens are left out, and so are fields with zero values, except the
+// values of literals.
+func printTree(out io.Writer, label string, node reflect.Value, depth int) {
+	indent := strings.Repeat("  ", depth)
+	for node.Kind() == reflect.Pointer || node.Kind() == reflect.Interface {
+		if node.IsNil() {
+			return
+		}
+		node = node.Elem()
+	}
+
+	switch node.Kind() {
+	case reflect.Struct:
+		fmt.Fprintf(out, "%s%s%s\n", indent, label, node.Type().Name())
+		for i := 0; i < node.NumField(); i++ {
+			field := node.Type().Field(i)
+			value := node.Field(i)
+			if !field.IsExported() || field.Type == reflect.TypeOf(token.Token{}) || value.IsZero() && field.Name != "Value" {
+				continue
+			}
+			printTree(out, field.Name+": ", value, depth+1)
+		}
+	case reflect.Slice:
+		name := strings.TrimSuffix(label, ": ")
+		for i := 0; i < node.Len(); i++ {
+			printTree(out, fmt.Sprintf("%s[%d]: ", name, i), node.Index(i), depth)
+		}
+	case reflect.Map:
+		keys := node.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].Interface().(ast.Node).String() < keys[j].Interface().(ast.Node).String()
+		})
+		name := strings.TrimSuffix(label, ": ")
+		for i, key := range keys {
+			printTree(out, fmt.Sprintf("%s[%d].Key: ", name, i), key, depth)
+			printTree(out, fmt.Sprintf("%s[%d].Value: ", name, i), node.MapIndex(key), depth)
+		}
+	case reflect.String:
+		fmt.Fprintf(out, "%s%s%q\n", indent, label, node.String())
+	default:
+		fmt.Fprintf(out, "%s%s%v\n", indent, label, node.Interface())
+	}
+}
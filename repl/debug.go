@@ -0,0 +1,154 @@
+package repl
+
+import (
+	"WeekTwo/debugger"
+	"WeekTwo/object"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const DEBUG_PROMPT = "(debug) "
+
+// debugSession connects a debugger to the REPL's input and output.
+type debugSession struct {
+	dbg     *debugger.Debugger
+	streams *object.IO
+	out     io.Writer
+}
+
+func newDebugSession(streams *object.IO, out io.Writer, env *object.Environment) *debugSession {
+	s := &debugSession{streams: streams, out: out}
+	s.dbg = debugger.New(s.pause)
+	s.dbg.Attach(env)
+	return s
+}
+
+// command runs a debugger command with the variables of env in view, and
+// reports whether line was one.
+func (s *debugSession) command(line string, env *object.Environment) bool {
+	name, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case ":break":
+		s.breakCommand(arg, true)
+	case ":clear":
+		s.breakCommand(arg, false)
+	case ":watch":
+		if arg == "" {
+			s.printWatches(env)
+			break
+		}
+		s.dbg.Watch(arg)
+	case ":unwatch":
+		n, err := strconv.Atoi(arg)
+		if err != nil || !s.dbg.Unwatch(n-1) {
+			fmt.Fprintf(s.out, "no watch expression %q\n", arg)
+		}
+	case ":locals":
+		s.printScopes(env)
+	case ":step":
+		s.dbg.Step()
+		io.WriteString(s.out, "pausing at the next statement\n")
+	case ":stack", ":next", ":out", ":continue":
+		io.WriteString(s.out, "not paused\n")
+	default:
+		return false
+	}
+	return true
+}
+
+func (s *debugSession) breakCommand(arg string, set bool) {
+	if arg == "" {
+		for _, b := range s.dbg.Breakpoints() {
+			fmt.Fprintf(s.out, "breakpoint at %s\n", b)
+		}
+		return
+	}
+
+	file, lineArg := "", arg
+	if i := strings.LastIndex(arg, ":"); i >= 0 {
+		file, lineArg = arg[:i], arg[i+1:]
+	}
+
+	line, err := strconv.Atoi(lineArg)
+	switch {
+	case err != nil && file == "" && set:
+		s.dbg.SetFunctionBreakpoint(arg)
+	case err != nil && file == "":
+		s.dbg.ClearFunctionBreakpoint(arg)
+	case err != nil:
+		fmt.Fprintf(s.out, "invalid breakpoint %q\n", arg)
+	case set:
+		s.dbg.SetBreakpoint(file, line)
+	default:
+		s.dbg.ClearBreakpoint(file, line)
+	}
+}
+
+// pause reads debugger commands until one of them resumes the evaluation.
+// Any other input is evaluated in the paused frame.
+func (s *debugSession) pause(stop *debugger.Stop) debugger.Action {
+	frame := stop.Stack[0]
+	fmt.Fprintf(s.out, "paused (%s) in %s\n", stop.Reason, frame)
+	fmt.Fprintf(s.out, "    %s\n", stop.Statement.String())
+	s.printWatches(frame.Env)
+
+	for {
+		io.WriteString(s.out, DEBUG_PROMPT)
+		line, err := s.streams.Stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return debugger.Continue
+		}
+		line = strings.TrimSpace(line)
+
+		switch name, _, _ := strings.Cut(line, " "); name {
+		case "":
+		case ":step":
+			return debugger.StepIn
+		case ":next":
+			return debugger.StepOver
+		case ":out":
+			return debugger.StepOut
+		case ":continue":
+			return debugger.Continue
+		case ":stack":
+			for _, f := range stop.Stack {
+				fmt.Fprintf(s.out, "  %s\n", f)
+			}
+		default:
+			if strings.HasPrefix(line, ":") {
+				if !s.command(line, frame.Env) {
+					fmt.Fprintf(s.out, "unknown command %s\n", name)
+				}
+				continue
+			}
+			io.WriteString(s.out, s.dbg.Evaluate(line, frame.Env).Inspect()+"\n")
+		}
+	}
+}
+
+func (s *debugSession) printWatches(env *object.Environment) {
+	for i, w := range s.dbg.Watches(env) {
+		fmt.Fprintf(s.out, "  %d: %s = %s\n", i+1, w.Expression, w.Value.Inspect())
+	}
+}
+
+// printScopes prints the variables of env and of every scope it is
+// enclosed in except the global one, which is only printed at the top
+// level.
+func (s *debugSession) printScopes(env *object.Environment) {
+	scopes := debugger.Scopes(env)
+	if len(scopes) > 1 {
+		scopes = scopes[:len(scopes)-1]
+	}
+
+	for _, scope := range scopes {
+		fmt.Fprintf(s.out, "%s:\n", scope.Name)
+		for _, v := range scope.Variables {
+			fmt.Fprintf(s.out, "  %s = %s\n", v.Name, v.Value.Inspect())
+		}
+	}
+}
@@ -1,17 +1,33 @@
 package repl
 
 import (
+	"WeekTwo/ast"
+	"WeekTwo/checker"
 	"WeekTwo/evaluator"
 	"WeekTwo/lexer"
 	"WeekTwo/object"
+	"WeekTwo/optimizer"
 	"WeekTwo/parser"
-	"bufio"
-	"fmt"
+	"WeekTwo/resolver"
+	"WeekTwo/token"
 	"io"
+	"strings"
 )
 
 const PROMPT = ">> "
 
+// CONTINUATION_PROMPT asks for the next line of input that is not complete
+// yet.
+const CONTINUATION_PROMPT = ".. "
+
+// optimizerPasses are run on each input. Constants are not inlined: a later
+// input may bind the name again, and the functions of earlier inputs must
+// see the new value.
+var optimizerPasses = optimizer.Options{
+	FoldConstants:         true,
+	EliminateDeadBranches: true,
+}
+
 func printParserErrors(out io.Writer, errors []string) {
 	for _, msg := range errors {
 		io.WriteString(out, "parser error: \n")
@@ -19,33 +35,168 @@ func printParserErrors(out io.Writer, errors []string) {
 	}
 }
 
+func printParserWarnings(out io.Writer, warnings []string) {
+	for _, msg := range warnings {
+		io.WriteString(out, "warning: "+msg+"\n")
+	}
+}
+
+// printDiagnostics prints the resolver's diagnostics and reports whether
+// any of them is an error.
+func printDiagnostics(out io.Writer, diagnostics []resolver.Diagnostic) bool {
+	for _, d := range diagnostics {
+		io.WriteString(out, d.Severity.String()+": "+d.String()+"\n")
+	}
+	return resolver.HasErrors(diagnostics)
+}
+
+// session is the state a REPL keeps between inputs.
+type session struct {
+	out      io.Writer
+	streams  *object.IO
+	env      *object.Environment
+	macroEnv *object.Environment
+	scopes   *resolver.Resolver
+	types    *checker.Checker
+	debug    *debugSession
+
+	// The enums declared by earlier inputs, for the exhaustiveness check of
+	// matches in later ones.
+	enums map[string][]string
+
+	// The inputs evaluated so far, which :save writes out.
+	transcript []string
+}
+
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	// Programs read their input from the same reader as the REPL, so that
+	// readline consumes the lines that follow the one being evaluated.
+	s := &session{out: out, streams: object.NewIO(in, out, out)}
+	s.reset()
 
 	for {
-		fmt.Print(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		io.WriteString(out, PROMPT)
+		line, ok := readLine(s.streams)
+		if !ok {
 			return
 		}
 
-		line := scanner.Text()
-		lex := lexer.New(line)
-		parser := parser.New(lex)
-
-		program := parser.ParseProgram()
-
-		if len(parser.Errors()) != 0 {
-			printParserErrors(out, parser.Errors())
+		if s.command(line) {
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
+		for incomplete(line) {
+			io.WriteString(out, CONTINUATION_PROMPT)
+			next, ok := readLine(s.streams)
+			if !ok {
+				break
+			}
+			line += "\n" + next
+		}
+
+		s.eval(line)
+	}
+}
+
+// reset starts the session over with no bindings, macros or transcript.
+// Breakpoints and watch expressions are kept.
+func (s *session) reset() {
+	s.env = object.NewEnvironment()
+	s.env.SetIO(s.streams)
+	s.macroEnv = object.NewEnvironment()
+	s.scopes = resolver.New()
+	s.types = checker.New()
+	s.enums = make(map[string][]string)
+	s.transcript = nil
+
+	if s.debug == nil {
+		s.debug = newDebugSession(s.streams, s.out, s.env)
+	} else {
+		s.debug.dbg.Attach(s.env)
+	}
+}
+
+// eval evaluates input and prints its value.
+func (s *session) eval(input string) {
+	program, ok := s.parse(input)
+	if !ok {
+		return
+	}
+
+	evaluator.DefineMacros(program, s.macroEnv)
+	expanded, err := evaluator.ExpandMacros(program, s.macroEnv)
+	if err != nil {
+		io.WriteString(s.out, err.Inspect()+"\n")
+		return
+	}
+
+	if printDiagnostics(s.out, s.scopes.Resolve(expanded.(*ast.Program))) {
+		return
+	}
+	// Type errors are left to the evaluator; the checker only keeps track
+	// of the types of the bindings for :type.
+	s.types.Check(expanded.(*ast.Program))
+	s.transcript = append(s.transcript, input)
+
+	optimized := optimizer.Optimize(expanded.(*ast.Program), optimizerPasses)
+
+	evaluated := s.debug.dbg.Eval(optimized, s.env)
+
+	if err, ok := evaluated.(*object.Error); ok && len(err.Stack) > 0 {
+		io.WriteString(s.out, err.Traceback())
+		io.WriteString(s.out, "\n")
+		return
+	}
+
+	if evaluated != nil {
+		io.WriteString(s.out, evaluated.Inspect())
+		io.WriteString(s.out, "\n")
+	}
+}
+
+// parse parses input, printing the errors and warnings found.
+func (s *session) parse(input string) (*ast.Program, bool) {
+	p := parser.New(lexer.New(input))
+	for name, variants := range s.enums {
+		p.DeclareEnum(name, variants)
+	}
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		printParserErrors(s.out, p.Errors())
+		return nil, false
+	}
+
+	printParserWarnings(s.out, p.Warnings())
+	for name, variants := range p.Enums() {
+		s.enums[name] = variants
+	}
+	return program, true
+}
+
+// readLine reads a line of input without its line ending. It returns false
+// at the end of the input.
+func readLine(streams *object.IO) (string, bool) {
+	line, err := streams.Stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", false
+	}
+	return strings.TrimRight(line, "\r\n"), true
+}
 
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+// incomplete reports whether input ends inside a string or with brackets,
+// braces or parentheses left open, so that more lines have to be read
+// before it can be parsed.
+func incomplete(input string) bool {
+	lex := lexer.New(input)
+	depth := 0
+	for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
 		}
 	}
+	return depth > 0 || lex.Unterminated()
 }
@@ -0,0 +1,141 @@
+package repl
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`let x = 1;`, false},
+		{`let f = fn(x) {`, true},
+		{"let f = fn(x) {\n  x\n};", false},
+		{`puts(1, [2,`, true},
+		{`let s = "abc`, true},
+		{`"{"`, false},
+		{`// (`, false},
+		{`)}`, false},
+	}
+
+	for _, tt := range tests {
+		if got := incomplete(tt.input); got != tt.expected {
+			t.Errorf("incomplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestMultiLineInput(t *testing.T) {
+	input := `let add = fn(a, b) {
+  a + b
+};
+add(1,
+  2)
+let s = "two
+lines";
+s
+`
+	var out strings.Builder
+	Start(strings.NewReader(input), &out)
+
+	expected := `>> .. .. >> .. 3
+>> .. >> two
+lines
+>> `
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestRebindingAcrossInputs(t *testing.T) {
+	input := "let x = 5; let f = fn() { x };\nlet x = 10;\nf()\n"
+	var out strings.Builder
+	Start(strings.NewReader(input), &out)
+
+	if expected := ">> >> >> 10\n>> "; out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestWarningsForEarlierEnums(t *testing.T) {
+	input := "enum Light { Red, Green };\nmatch (Red) { Red => 1 }\n"
+	var out strings.Builder
+	Start(strings.NewReader(input), &out)
+
+	if expected := ">> >> warning: non-exhaustive match on Light: missing Green\n1\n>> "; out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.mk")
+	input := `let add = fn(a, b) { a + b }
+let s = "x" // a string
+:env
+:type add
+:type add(1, "a")
+:type
+:ast -1
+:tokens let x = "s";
+:save ` + path + `
+:reset
+:env
+:load ` + path + `
+add(2, 3)
+:time add(1, 1)
+:bogus
+`
+	var out strings.Builder
+	Start(strings.NewReader(input), &out)
+
+	expected := []string{
+		">> >> >> add: FUNCTION = fn(a, b) {\n(a + b)\n}\ns: STRING = x\n",
+		">> fn(a, a) -> a\n",
+		">> type error: 1:8: cannot use string as int in argument 2 to add\n",
+		">> usage: :type EXPR\n",
+		">> Program\n  Statements[0]: ExpressionStatement\n    Expression: PrefixExpression\n      Operator: \"-\"\n      Right: IntegerLiteral\n        Value: 1\n",
+		">> 1:1\tLET\t\"let\"\n1:5\tIDENT\t\"x\"\n1:7\t=\t\"=\"\n1:9\tSTRING\t\"s\"\n1:12\t;\t\";\"\n",
+		">> >> >> >> >> 5\n>> 2\ntime: ",
+		">> unknown command :bogus, see :help\n>> ",
+	}
+	got := out.String()
+	for _, e := range expected {
+		i := strings.Index(got, e)
+		if i < 0 {
+			t.Fatalf("missing output %q in\n%s", e, out.String())
+		}
+		got = got[i+len(e):]
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if string(saved) != "let add = fn(a, b) { a + b };\nlet s = \"x\"; // a string\n" {
+		t.Errorf("wrong transcript. got=%q", saved)
+	}
+}
+
+func TestTerminate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let x = 1`, `let x = 1;`},
+		{`let x = 1;`, `let x = 1;`},
+		{"puts(\"a\") // done", "puts(\"a\"); // done"},
+		{"if (x) {\n  1\n}", "if (x) {\n  1\n};"},
+		{`"open`, `"open";`},
+		{`// only a comment`, `// only a comment`},
+	}
+
+	for _, tt := range tests {
+		if got := terminate(tt.input); got != tt.expected {
+			t.Errorf("terminate(%q) wrong. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package resolver
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/evaluator"
+	"WeekTwo/token"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	if s == Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Diagnostic is a problem found by the resolver at the position of Token.
+type Diagnostic struct {
+	Severity Severity
+	Message  string
+	Token    token.Token
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s", d.Token.Line, d.Token.Column, d.Message)
+}
+
+type binding struct {
+	slot   int
+	ident  *ast.Identifier // nil for predeclared names
+	token  token.Token
+	used   bool
+	unused bool // whether to report the binding if it is never used
+}
+
+// scope mirrors one object.Environment that the evaluator creates: the
+// program, a function call, a for loop iteration, a match arm or a select
+// case with a binding.
+type scope struct {
+	parent   *scope
+	bindings map[string]*binding
+	global   bool
+	// Function bodies are resolved when their defining scope ends, because
+	// they run after the rest of that scope has been declared.
+	pending []*ast.FunctionLiteral
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{parent: parent, bindings: make(map[string]*binding)}
+}
+
+// Resolver checks that every identifier in a program refers to a binding in
+// scope, warns about unused and shadowing bindings, and annotates each
+// identifier with the depth and slot of its declaration. The global scope
+// is kept between calls to Resolve, so that a REPL can resolve one line at
+// a time.
+type Resolver struct {
+	globals     *scope
+	current     *scope
+	builtins    map[string]bool
+	methods     map[string]bool
+	diagnostics []Diagnostic
+	definitions map[*ast.Identifier]*ast.Identifier
+}
+
+// New returns a resolver whose global scope already holds the names in
+// predeclared, such as globals registered by a host program.
+func New(predeclared ...string) *Resolver {
+	r := &Resolver{
+		globals:  newScope(nil),
+		builtins: make(map[string]bool),
+		methods:  make(map[string]bool),
+	}
+	r.globals.global = true
+
+	for _, name := range evaluator.BuiltinNames() {
+		r.builtins[name] = true
+	}
+	for _, name := range evaluator.MethodNames() {
+		r.methods[name] = true
+	}
+	for _, name := range predeclared {
+		r.globals.bindings[name] = &binding{slot: len(r.globals.bindings), used: true}
+	}
+
+	return r
+}
+
+// Declare adds names to the global scope, such as globals a host program
+// defined after the resolver was made. Names already declared are kept.
+func (r *Resolver) Declare(names ...string) {
+	for _, name := range names {
+		if _, ok := r.globals.bindings[name]; !ok {
+			r.globals.bindings[name] = &binding{slot: len(r.globals.bindings), used: true}
+		}
+	}
+}
+
+// Resolve resolves program and returns its diagnostics sorted by position.
+// The globals declared by a program with errors are discarded.
+func Resolve(program *ast.Program) []Diagnostic {
+	return New().Resolve(program)
+}
+
+func (r *Resolver) Resolve(program *ast.Program) []Diagnostic {
+	saved := make(map[string]*binding, len(r.globals.bindings))
+	for name, b := range r.globals.bindings {
+		saved[name] = b
+	}
+
+	r.diagnostics = nil
+	r.definitions = make(map[*ast.Identifier]*ast.Identifier)
+	r.current = r.globals
+
+	for _, stmt := range program.Statements {
+		r.resolve(stmt)
+	}
+	r.endScope(r.globals)
+
+	sort.SliceStable(r.diagnostics, func(i, j int) bool {
+		a, b := r.diagnostics[i].Token, r.diagnostics[j].Token
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	if HasErrors(r.diagnostics) {
+		r.globals.bindings = saved
+	}
+
+	return r.diagnostics
+}
+
+// Definitions maps the identifiers of the program last resolved to the
+// identifiers that declared them. Declarations map to themselves, and
+// identifiers that refer to builtins or predeclared names are left out.
+func (r *Resolver) Definitions() map[*ast.Identifier]*ast.Identifier {
+	return r.definitions
+}
+
+// HasErrors reports whether any of diagnostics is an error.
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Resolver) resolve(node ast.Node) {
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		r.resolve(node.Expression)
+	case *ast.LetStatement:
+		r.resolve(node.Value)
+		r.declare(node.Name, !r.current.global)
+	case *ast.ExportStatement:
+		r.resolve(node.Statement)
+	case *ast.ReturnStatement:
+		r.resolve(node.ReturnValue)
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			r.resolve(stmt)
+		}
+	case *ast.EnumStatement:
+		r.declare(node.Name, false)
+		for _, variant := range node.Variants {
+			r.declare(variant.Name, false)
+		}
+	case *ast.ImportStatement:
+		r.declare(node.Alias, !r.current.global)
+	case *ast.Identifier:
+		r.lookup(node)
+	case *ast.PrefixExpression:
+		r.resolve(node.Right)
+	case *ast.InfixExpression:
+		r.resolve(node.Left)
+		r.resolve(node.Right)
+	case *ast.IfExpression:
+		r.resolve(node.Condition)
+		r.resolve(node.Consequence)
+		if node.Alternative != nil {
+			r.resolve(node.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		r.current.pending = append(r.current.pending, node)
+	case *ast.CallExpression:
+		// The argument of quote is code, not an expression to evaluate.
+		if ident, ok := node.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+			return
+		}
+		r.resolve(node.Function)
+		for _, arg := range node.Arguments {
+			r.resolve(arg)
+		}
+	case *ast.MethodCallExpression:
+		r.resolve(node.Receiver)
+		for _, arg := range node.Arguments {
+			r.resolve(arg)
+		}
+		// Unless it names a method of some type, the evaluator calls the
+		// function in scope with the receiver as its first argument. A name
+		// that is not in scope is not reported, since a module receiver
+		// may export it.
+		if !r.methods[node.Method.Value] {
+			r.find(node.Method)
+		}
+	case *ast.MemberExpression:
+		r.resolve(node.Object)
+	case *ast.IndexExpression:
+		r.resolve(node.Left)
+		r.resolve(node.Index)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			r.resolve(el)
+		}
+	case *ast.HashLiteral:
+		for key, value := range node.Pairs {
+			r.resolve(key)
+			r.resolve(value)
+		}
+	case *ast.YieldExpression:
+		r.resolve(node.Value)
+	case *ast.SpawnExpression:
+		r.resolve(node.Call)
+	case *ast.SelectExpression:
+		for _, c := range node.Cases {
+			if c.Channel != nil {
+				r.resolve(c.Channel)
+			}
+			if c.Value != nil {
+				r.resolve(c.Value)
+			}
+			if c.Binding == nil {
+				r.resolve(c.Body)
+				continue
+			}
+			r.current = newScope(r.current)
+			r.declare(c.Binding, true)
+			r.resolve(c.Body)
+			r.endScope(r.current)
+			r.current = r.current.parent
+		}
+	case *ast.ForExpression:
+		r.resolve(node.Iterable)
+		r.current = newScope(r.current)
+		r.declare(node.Variable, true)
+		r.resolve(node.Body)
+		r.endScope(r.current)
+		r.current = r.current.parent
+	case *ast.MatchExpression:
+		r.resolve(node.Subject)
+		for _, arm := range node.Arms {
+			if arm.Variant.Value == "_" {
+				r.resolve(arm.Body)
+				continue
+			}
+			r.current = newScope(r.current)
+			for _, b := range arm.Bindings {
+				if b.Value != "_" {
+					r.declare(b, true)
+				}
+			}
+			r.resolve(arm.Body)
+			r.endScope(r.current)
+			r.current = r.current.parent
+		}
+	}
+}
+
+func (r *Resolver) resolveFunction(fn *ast.FunctionLiteral, parent *scope) {
+	previous := r.current
+	r.current = newScope(parent)
+
+	for _, param := range fn.Parameters {
+		r.declare(param, false)
+	}
+	r.resolve(fn.Body)
+	r.endScope(r.current)
+
+	r.current = previous
+}
+
+// endScope resolves the functions defined in s and reports its unused
+// bindings.
+func (r *Resolver) endScope(s *scope) {
+	for len(s.pending) > 0 {
+		fn := s.pending[0]
+		s.pending = s.pending[1:]
+		r.resolveFunction(fn, s)
+	}
+
+	for name, b := range s.bindings {
+		if b.unused && !b.used && !strings.HasPrefix(name, "_") {
+			r.report(Warning, b.token, "%s declared and not used", name)
+		}
+	}
+}
+
+func (r *Resolver) declare(ident *ast.Identifier, reportUnused bool) {
+	s := r.current
+
+	if b, ok := s.bindings[ident.Value]; ok {
+		b.token = ident.Token
+		b.ident = ident
+		r.definitions[ident] = ident
+		r.annotate(ident, 0, b.slot)
+		return
+	}
+
+	for outer := s.parent; outer != nil; outer = outer.parent {
+		if b, ok := outer.bindings[ident.Value]; ok {
+			if b.token.Line > 0 {
+				r.report(Warning, ident.Token, "%s shadows declaration at %d:%d", ident.Value, b.token.Line, b.token.Column)
+			} else {
+				r.report(Warning, ident.Token, "%s shadows an outer declaration", ident.Value)
+			}
+			break
+		}
+	}
+
+	b := &binding{slot: len(s.bindings), ident: ident, token: ident.Token, unused: reportUnused}
+	s.bindings[ident.Value] = b
+	r.definitions[ident] = ident
+	r.annotate(ident, 0, b.slot)
+}
+
+func (r *Resolver) lookup(ident *ast.Identifier) {
+	if r.find(ident) || r.builtins[ident.Value] {
+		return
+	}
+
+	r.report(Error, ident.Token, "identifier not found: %s", ident.Value)
+}
+
+// find resolves ident to the innermost binding of its name in scope, if
+// there is one, and reports whether there was.
+func (r *Resolver) find(ident *ast.Identifier) bool {
+	depth := 0
+	for s := r.current; s != nil; s = s.parent {
+		if b, ok := s.bindings[ident.Value]; ok {
+			b.used = true
+			if b.ident != nil {
+				r.definitions[ident] = b.ident
+			}
+			r.annotate(ident, depth, b.slot)
+			return true
+		}
+		depth++
+	}
+	return false
+}
+
+func (r *Resolver) annotate(ident *ast.Identifier, depth, slot int) {
+	ident.Resolved = true
+	ident.Depth = depth
+	ident.Slot = slot
+}
+
+func (r *Resolver) report(severity Severity, tok token.Token, format string, a ...interface{}) {
+	r.diagnostics = append(r.diagnostics, Diagnostic{
+		Severity: severity,
+		Message:  fmt.Sprintf(format, a...),
+		Token:    tok,
+	})
+}
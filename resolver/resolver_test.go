@@ -0,0 +1,174 @@
+package resolver
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/evaluator"
+	"WeekTwo/lexer"
+	"WeekTwo/object"
+	"WeekTwo/parser"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let x = 1; x + len("a")`, nil},
+		{`let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(3)`, nil},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };`, nil},
+		{`y + 1`, []string{"error 1:1: identifier not found: y"}},
+		{`let f = fn() { if (false) { typo } };`, []string{"error 1:29: identifier not found: typo"}},
+		{`let a = b; let b = 1;`, []string{"error 1:9: identifier not found: b"}},
+		{`let f = fn() { let unused = 1; 2 };`, []string{"warning 1:20: unused declared and not used"}},
+		{`let f = fn() { let _ignored = 1; 2 };`, nil},
+		{`for (i in [1, 2]) { 3 }`, []string{"warning 1:6: i declared and not used"}},
+		{`let x = 1; let f = fn(x) { x };`, []string{"warning 1:23: x shadows declaration at 1:5"}},
+		{`let x = 1; let x = 2; x`, nil},
+		{`enum Shape { Circle(r), Square(s) }
+let area = fn(shape) { match (shape) { Circle(r) => r * r, Square(s) => s * s } };
+area(Circle(2))`, nil},
+		{`quote(anything + goes)`, nil},
+		{`let s = "a"; s.upper()`, nil},
+		{`let f = fn() { let double = fn(x) { x * 2 }; 3.double() };`, nil},
+		{`let m = 1; m.exported()`, nil},
+	}
+
+	for _, tt := range tests {
+		diagnostics := Resolve(parse(t, tt.input))
+
+		if len(diagnostics) != len(tt.expected) {
+			t.Errorf("%q: wrong number of diagnostics. got=%v, want=%v", tt.input, diagnostics, tt.expected)
+			continue
+		}
+		for i, d := range diagnostics {
+			got := d.Severity.String() + " " + d.String()
+			if got != tt.expected[i] {
+				t.Errorf("%q: diagnostic %d wrong. got=%q, want=%q", tt.input, i, got, tt.expected[i])
+			}
+		}
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	program := parse(t, `let a = 1; let b = 2; let f = fn(x) { let y = x; fn() { y + b } };`)
+	Resolve(program)
+
+	var idents []*ast.Identifier
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		if ident, ok := node.(*ast.Identifier); ok {
+			idents = append(idents, ident)
+		}
+		return node
+	})
+
+	expected := map[string][2]int{
+		"a": {0, 0},
+		"b": {2, 1},
+		"x": {0, 0},
+		"y": {1, 1},
+	}
+
+	// ast.Modify does not visit let statement names, so only uses and
+	// parameters are checked.
+	for _, ident := range idents {
+		want, ok := expected[ident.Value]
+		if !ok {
+			continue
+		}
+		if !ident.Resolved {
+			t.Errorf("identifier %s not resolved", ident.Value)
+			continue
+		}
+		if ident.Depth != want[0] || ident.Slot != want[1] {
+			t.Errorf("identifier %s wrong. got depth=%d slot=%d, want depth=%d slot=%d",
+				ident.Value, ident.Depth, ident.Slot, want[0], want[1])
+		}
+	}
+}
+
+func TestMethodCallDefinitions(t *testing.T) {
+	program := parse(t, `let double = fn(x) { x * 2 }; 3.double()`)
+	r := New()
+	r.Resolve(program)
+
+	call := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.MethodCallExpression)
+	def, ok := r.Definitions()[call.Method]
+	if !ok {
+		t.Fatalf("no definition for the method %s", call.Method.Value)
+	}
+	if def != program.Statements[0].(*ast.LetStatement).Name {
+		t.Errorf("wrong definition. got=%v", def)
+	}
+}
+
+func TestResolverKeepsGlobals(t *testing.T) {
+	r := New("host")
+
+	if d := r.Resolve(parse(t, `let a = b;`)); !HasErrors(d) {
+		t.Fatalf("expected an error for b")
+	}
+	if d := r.Resolve(parse(t, `let x = host;`)); len(d) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", d)
+	}
+	if d := r.Resolve(parse(t, `x + 1`)); len(d) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", d)
+	}
+	if d := r.Resolve(parse(t, `a`)); !HasErrors(d) {
+		t.Errorf("a was declared by a program that failed to resolve")
+	}
+}
+
+func TestResolvedProgramsEvaluate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let x = 10; let f = fn(y) { let z = y * 2; fn(w) { x + z + w } }; f(3)(1)`, "17"},
+		{`let y = 1; let f = fn(c) { if (c) { let y = 2; }; y }; [f(true), f(false)]`, "[2, 1]"},
+		{`let total = 0; let sum = fn(xs) { let acc = 0; for (x in xs) { let acc = acc + x; }; acc }; sum([1, 2, 3])`, "0"},
+		{`enum Opt { Some(v), None } match (Some(4)) { Some(v) => v + 1, None => 0 }`, "5"},
+		{`let x = 1; let x = x + 1; let f = fn(a, b) { [b, a, x] }; f(3, 4)`, "[4, 3, 2]"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+		if d := Resolve(program); HasErrors(d) {
+			t.Fatalf("%q: unexpected errors: %v", tt.input, d)
+		}
+
+		evaluated := evaluator.Eval(program, object.NewEnvironment())
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: got=%s, want=%s", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestEvaluatorUsesSlots(t *testing.T) {
+	program := parse(t, `let a = 1; let b = 2;`)
+	if d := Resolve(program); HasErrors(d) {
+		t.Fatalf("unexpected errors: %v", d)
+	}
+
+	env := object.NewEnvironment()
+	evaluator.Eval(program, env)
+
+	b, ok := env.GetSlot(0, 1, "b")
+	if !ok || b.Inspect() != "2" {
+		t.Errorf("b is not in slot 1. got=%v, %t", b, ok)
+	}
+	if _, ok := env.GetSlot(0, 1, "a"); ok {
+		t.Errorf("slot 1 should not hold a")
+	}
+}
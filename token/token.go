@@ -1,15 +1,20 @@
 package token
 
+import "sort"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int
+	Column  int
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // // to the end of the line
 
 	// Identifiers + literals
 	IDENT = "IDENT" // add, foobar, x, y, ...
@@ -26,11 +31,14 @@ const (
 	GT       = ">"
 	EQ       = "=="
 	NOT_EQ   = "!="
+	ARROW    = "=>"
+	RARROW   = "->"
 
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	DOT       = "."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -48,6 +56,17 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	ENUM     = "ENUM"
+	MATCH    = "MATCH"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+	AS       = "AS"
+	YIELD    = "YIELD"
+	FOR      = "FOR"
+	IN       = "IN"
+	MACRO    = "MACRO"
+	SPAWN    = "SPAWN"
+	SELECT   = "SELECT"
 )
 
 var keywords = map[string]TokenType{
@@ -58,6 +77,27 @@ var keywords = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+	"enum":   ENUM,
+	"match":  MATCH,
+	"import": IMPORT,
+	"export": EXPORT,
+	"as":     AS,
+	"yield":  YIELD,
+	"for":    FOR,
+	"in":     IN,
+	"macro":  MACRO,
+	"spawn":  SPAWN,
+	"select": SELECT,
+}
+
+// Keywords returns the keywords of the language in sorted order.
+func Keywords() []string {
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func LookupIdent(ident string) TokenType {
@@ -0,0 +1,117 @@
+package vm
+
+import (
+	"WeekTwo/code"
+	"WeekTwo/object"
+)
+
+// operatorMethods names the hash entries consulted when an operator is
+// applied to a hash, as in the evaluator: {"__add__": fn(self, other) { ... }}.
+var operatorMethods = map[code.Opcode]string{
+	code.OpAdd:         "__add__",
+	code.OpSub:         "__sub__",
+	code.OpMul:         "__mul__",
+	code.OpDiv:         "__div__",
+	code.OpLessThan:    "__lt__",
+	code.OpGreaterThan: "__gt__",
+	code.OpEqual:       "__eq__",
+	code.OpNotEqual:    "__ne__",
+}
+
+// reflectedMethods names the entries consulted on the right operand when
+// the left one does not overload the operator. They are called with the
+// right operand first.
+var reflectedMethods = map[code.Opcode]string{
+	code.OpAdd:         "__radd__",
+	code.OpSub:         "__rsub__",
+	code.OpMul:         "__rmul__",
+	code.OpDiv:         "__rdiv__",
+	code.OpLessThan:    "__gt__",
+	code.OpGreaterThan: "__lt__",
+	code.OpEqual:       "__eq__",
+	code.OpNotEqual:    "__ne__",
+}
+
+func lookupOperatorMethod(obj object.Object, name string) (object.Object, bool) {
+	hash, ok := obj.(*object.Hash)
+	if !ok {
+		return nil, false
+	}
+
+	pair, ok := hash.Pairs[(&object.String{Value: name}).HashKey()]
+	if !ok {
+		return nil, false
+	}
+
+	switch pair.Value.(type) {
+	case *object.Closure, *object.Builtin:
+		return pair.Value, true
+	default:
+		return nil, false
+	}
+}
+
+// executeOverloadedOperator applies op to left and right with the method
+// of one of them that overloads it, and reports whether there was one.
+// != falls back to negating __eq__.
+func (vm *VM) executeOverloadedOperator(op code.Opcode, left, right object.Object) (bool, error) {
+	if fn, ok := lookupOperatorMethod(left, operatorMethods[op]); ok {
+		return true, vm.callAndPush(fn, left, right)
+	}
+	if fn, ok := lookupOperatorMethod(right, reflectedMethods[op]); ok {
+		return true, vm.callAndPush(fn, right, left)
+	}
+
+	if op == code.OpNotEqual {
+		fn, ok := lookupOperatorMethod(left, operatorMethods[code.OpEqual])
+		args := []object.Object{left, right}
+		if !ok {
+			fn, ok = lookupOperatorMethod(right, operatorMethods[code.OpEqual])
+			args = []object.Object{right, left}
+		}
+		if ok {
+			equal, err := vm.call(fn, args...)
+			if err != nil {
+				return true, err
+			}
+			return true, vm.push(nativeBoolToBooleanObject(!isTruthy(equal)))
+		}
+	}
+
+	return false, nil
+}
+
+// call calls fn with args and returns its result, running a closure to
+// completion before it returns.
+func (vm *VM) call(fn object.Object, args ...object.Object) (object.Object, error) {
+	err := vm.push(fn)
+	if err != nil {
+		return nil, err
+	}
+	for _, arg := range args {
+		err := vm.push(arg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	depth := vm.framesIndex
+	err = vm.executeCall(len(args))
+	if err != nil {
+		return nil, err
+	}
+	err = vm.run(depth)
+	if err != nil {
+		return nil, err
+	}
+
+	return vm.pop(), nil
+}
+
+func (vm *VM) callAndPush(fn object.Object, args ...object.Object) error {
+	result, err := vm.call(fn, args...)
+	if err != nil {
+		return err
+	}
+	return vm.push(result)
+}
@@ -0,0 +1,109 @@
+package vm
+
+import (
+	"WeekTwo/ast"
+	"WeekTwo/compiler"
+	"WeekTwo/lexer"
+	"WeekTwo/object"
+	"WeekTwo/parser"
+	"testing"
+)
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+// runVM compiles and runs input, returning the result the way the REPL
+// prints it: the value's Inspect output or "ERROR: " and the message.
+func runVM(t *testing.T, input string) string {
+	t.Helper()
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		return "ERROR: " + err.Error()
+	}
+
+	machine := New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		return "ERROR: " + err.Error()
+	}
+
+	if last := machine.LastPoppedStackElem(); last != nil {
+		return last.Inspect()
+	}
+	return ""
+}
+
+func TestVMErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fn(a) { a }()`, "ERROR: wrong number of arguments. got=0, want=1"},
+		{`1(2)`, "ERROR: not a function: INTEGER"},
+		{`1 / 0`, "ERROR: division by zero"},
+		{`let f = fn() { 1 + f() }; f()`, "ERROR: maximum recursion depth exceeded"},
+		{`{[1]: 2}`, "ERROR: unusable as hash key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		got := runVM(t, tt.input)
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestLetHasNoValue(t *testing.T) {
+	for _, input := range []string{
+		`let x = 1;`,
+		`1; let x = 2;`,
+		`let f = fn() { let x = 1; }; f()`,
+		`let f = fn() { }; f()`,
+	} {
+		if got := runVM(t, input); got != "" {
+			t.Errorf("wrong result for %q. want no value, got=%q", input, got)
+		}
+	}
+}
+
+func TestDeepRecursion(t *testing.T) {
+	input := `let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(5000)`
+	if got := runVM(t, input); got != "5000" {
+		t.Errorf("wrong result for %q. want=%q, got=%q", input, "5000", got)
+	}
+}
+
+func TestGlobalsAcrossRuns(t *testing.T) {
+	symbolTable := compiler.NewGlobalSymbolTable()
+	constants := []object.Object{}
+	globals := NewGlobalsStore()
+
+	for _, input := range []string{"let a = 40;", "let b = a + 2;"} {
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		constants = comp.Bytecode().Constants
+
+		machine := NewWithGlobalsStore(comp.Bytecode(), globals)
+		if err := machine.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+	}
+
+	comp := compiler.NewWithState(symbolTable, constants)
+	if err := comp.Compile(parse("b")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	machine := NewWithGlobalsStore(comp.Bytecode(), globals)
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	if machine.LastPoppedStackElem().Inspect() != "42" {
+		t.Errorf("wrong result. got=%s", machine.LastPoppedStackElem().Inspect())
+	}
+}